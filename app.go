@@ -5,13 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"os"
 	"strconv"
 	"time"
 	"tuxiaocao/pkg/logger"
 	"tuxiaocao/pkg/platform/database"
+	"tuxiaocao/pkg/platform/health"
+	"tuxiaocao/pkg/platform/migrations"
 	redis2 "tuxiaocao/pkg/platform/redis"
+	"tuxiaocao/pkg/platform/storage"
+	"tuxiaocao/routes/controllers"
 	models2 "tuxiaocao/routes/models"
 )
 
@@ -22,11 +27,61 @@ func ReadyDatabase() {
 		logger.Log.Panicf("mysql is error %v", err)
 	}
 
-	err = database.DB.AutoMigrate(models2.Product{}, models2.User{}, models2.LogRecord{})
-	if err != nil {
-		logger.Log.Errorf("mysql migrate is error %v", err)
+	// Refuse to serve with an outdated schema, unless DB_AUTO_MIGRATE applies the migrations.
+	ReadyMigrations()
+
+	// Scope all queries to the tenant of the request, rows of earlier versions go to the default tenant.
+	if err = database.DB.Use(models2.TenantPlugin{}); err != nil {
+		logger.Log.Panicf("tenant plugin is error %v", err)
 	}
+	if err = models2.BackfillTenant(context.Background(), models2.DefaultTenant()); err != nil {
+		logger.Log.Errorf("tenant backfill is error %v", err)
+	}
+
+	// Record every create, update and delete in the audit trail.
+	if err = database.DB.Use(models2.AuditPlugin{}); err != nil {
+		logger.Log.Errorf("audit plugin is error %v", err)
+	}
+
+	// Ping the primary for readiness.
+	health.Register(health.Check{
+		Name:     "database",
+		Critical: health.IsCritical("database"),
+		Probe: func(ctx context.Context) error {
+			sqlDB, err := database.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	})
+}
 
+// ReadyMigrations checks, that all migrations of pkg/platform/migrations are applied.
+// Pending migrations are applied, if DB_AUTO_MIGRATE is true, otherwise the server doesn't start.
+func ReadyMigrations() {
+	migrator, err := migrations.New(database.DB)
+	if err != nil {
+		logger.Log.Fatalf("migrations are error %v", err)
+	}
+	status, err := migrator.Status(context.Background())
+	if err != nil {
+		logger.Log.Fatalf("migration status is error %v", err)
+	}
+	if status.Dirty {
+		logger.Log.Fatalf("database schema is dirty at version %d, fix it and run `migrate force <version>`", status.Version)
+	}
+	if !status.Behind() {
+		return
+	}
+	if os.Getenv("DB_AUTO_MIGRATE") != "true" {
+		logger.Log.Fatalf("database schema is at version %d, but %d is required, run `migrate up` or set DB_AUTO_MIGRATE=true", status.Version, status.Latest)
+	}
+	applied, err := migrator.Up(context.Background(), 0)
+	if err != nil {
+		logger.Log.Fatalf("migrate up is error %v", err)
+	}
+	logger.Log.Infof("applied %d migrations, database schema is at version %d", len(applied), status.Latest)
 }
 
 func ReadyRedisConnection() {
@@ -39,10 +94,17 @@ func ReadyRedisConnection() {
 		DB:       *da,
 	})
 	redis2.Rds = rds
+	health.Register(health.Check{
+		Name:     "redis",
+		Critical: health.IsCritical("redis"),
+		Probe: func(ctx context.Context) error {
+			return rds.Ping(ctx).Err()
+		},
+	})
 	result, err := redis2.Rds.Ping(context.Background()).Result()
 	if err != nil {
-		logger.Log.Errorf("redis pong!", err)
-		fmt.Errorf("redis pong!", err)
+		logger.Log.Errorf("redis pong! %v", err)
+		return
 	}
 	logger.Log.Info("redis result ", result)
 	rds.Set(context.Background(), "asdfa", time.Now().Format(time.DateTime), 0)
@@ -60,38 +122,144 @@ func ReadyLogger() {
 }
 
 func ReadyEtcd() {
-	cli, _ := clientv3.New(clientv3.Config{
+	cli, err := clientv3.New(clientv3.Config{
 		Endpoints:   []string{"localhost:2379"},
 		DialTimeout: 5 * time.Second,
 	})
-	_, err := cli.Put(context.Background(), "julone", "lee")
 	if err != nil {
-		fmt.Printf(err.Error())
+		logger.Log.Errorf("etcd is error %v", err)
+		return
+	}
+	health.Register(health.Check{
+		Name:     "etcd",
+		Critical: health.IsCritical("etcd"),
+		Probe: func(ctx context.Context) error {
+			_, err := cli.Get(ctx, "health")
+			return err
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = cli.Put(ctx, "julone", "lee"); err != nil {
+		logger.Log.Errorf("etcd put is error %v", err)
+		return
+	}
+	result, err := cli.Get(ctx, "julone")
+	if err != nil || len(result.Kvs) == 0 {
+		logger.Log.Errorf("etcd get is error %v", err)
+		return
+	}
+	logger.Log.Infof("etcd result %s", result.Kvs[0].Value)
+}
+
+// ReadyKafka registers the readiness check of the Kafka broker, which HookupFromKafka consumes.
+func ReadyKafka() {
+	health.Register(health.Check{
+		Name:     "kafka",
+		Critical: health.IsCritical("kafka"),
+		Probe: func(ctx context.Context) error {
+			conn, err := kafka.DialContext(ctx, "tcp", "localhost:29092")
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+	})
+}
+
+// ReadyPurgeJob starts the job, which hard-deletes soft-deleted rows after the retention period.
+func ReadyPurgeJob() {
+	retentionHours, err := strconv.Atoi(os.Getenv("SOFT_DELETE_RETENTION_HOURS"))
+	if err != nil || retentionHours <= 0 {
+		retentionHours = 720
+	}
+	go models2.RunPurgeJob(context.Background(), time.Duration(retentionHours)*time.Hour, time.Hour)
+}
+
+// ReadyCache enables the Redis read cache of products (CACHE_TTL_PRODUCT_SECONDS, 0 disables it).
+func ReadyCache() {
+	if err := database.DB.Use(models2.CachePlugin{}); err != nil {
+		logger.Log.Errorf("cache plugin is error %v", err)
+		return
+	}
+	ttl, err := strconv.Atoi(os.Getenv("CACHE_TTL_PRODUCT_SECONDS"))
+	if err != nil {
+		ttl = 60
+	}
+	models2.SetCacheTTL[models2.Product](time.Duration(ttl) * time.Second)
+}
+
+// ReadyStorage opens the file storage of product images (STORAGE_TYPE local or s3).
+func ReadyStorage() {
+	store, err := storage.OpenStorage()
+	if err != nil {
+		logger.Log.Panicf("storage is error %v", err)
 	}
-	result, _ := cli.Get(context.Background(), "julone")
-	fmt.Printf("%v", result.Kvs[0].Value)
+	health.Register(health.Check{
+		Name:     "storage",
+		Critical: health.IsCritical("storage"),
+		Probe:    store.Ping,
+	})
+}
+
+// ReadyMemoryStores switches handlers to in-memory stores, data is lost on restart.
+func ReadyMemoryStores() {
+	products := models2.NewMemoryProductStore()
+	controllers.UseStores(products, models2.NewMemoryUserStore(), models2.NewMemoryCategoryStore(), models2.NewMemoryReviewStore(products), models2.NewMemoryCommentStore(products))
+	logger.Log.Info("demo mode, products, users, categories, reviews and comments are kept in memory")
 }
+
+// criticalComponents can't fail, the server doesn't start without them.
+var criticalComponents = map[string]bool{"logger": true, "mysql": true, "memory": true}
+
 func InitAll(Components ...string) {
 	if len(Components) == 0 {
-		Components = []string{"logger", "mysql", "redis", "etcd"}
+		Components = []string{"logger", "mysql", "redis", "cache", "etcd", "kafka", "purge", "storage"}
+		// Demo mode keeps the stores in memory and doesn't connect to a database,
+		// the audit trail and the purge job need the database and aren't started.
+		if os.Getenv("DEMO_MODE") == "true" {
+			Components = []string{"logger", "memory", "redis", "storage"}
+		}
 	}
+	for _, val := range Components {
+		ReadyComponent(val)
+	}
+}
+
+// ReadyComponent starts one component. A panic of a critical component stops the start,
+// other components are logged and reported by /readyz.
+func ReadyComponent(val string) {
 	defer func() {
-		if d := recover(); d != nil {
-			fmt.Printf("recover error", d)
+		d := recover()
+		if d == nil {
+			return
 		}
-	}()
-	for _, val := range Components {
-		switch val {
-		case "logger":
-			ReadyLogger()
-		case "mysql":
-			ReadyDatabase()
-		case "redis":
-			ReadyRedisConnection()
-		case "etcd":
-			ReadyEtcd()
-		default:
-			fmt.Printf("all ready")
+		if criticalComponents[val] || logger.Log == nil {
+			panic(d)
 		}
+		logger.Log.Errorf("%s is error %v", val, d)
+	}()
+	switch val {
+	case "logger":
+		ReadyLogger()
+	case "mysql":
+		ReadyDatabase()
+	case "redis":
+		ReadyRedisConnection()
+	case "etcd":
+		ReadyEtcd()
+	case "kafka":
+		ReadyKafka()
+	case "purge":
+		ReadyPurgeJob()
+	case "cache":
+		ReadyCache()
+	case "memory":
+		ReadyMemoryStores()
+	case "storage":
+		ReadyStorage()
+	default:
+		logger.Log.Errorf("component %s is not known", val)
 	}
 }
@@ -32,6 +32,10 @@ func main() {
 	if err != nil {
 		panic("config is not load" + err.Error())
 	}
+	// Run `migrate up|down|status|force` instead of the server.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(RunMigrate(os.Args[2:]))
+	}
 	// Define Fiber config.
 	config := configs.FiberConfig()
 	InitAll()
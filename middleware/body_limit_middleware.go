@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// RouteBodyLimit func for limiting the request body of one route to limit bytes.
+// The server checks the limit, when the headers are received, so larger bodies are
+// answered with status 413 before they are read into memory. The BodyLimit of the
+// Fiber config still applies to all other routes.
+//
+// The path is the full path of the route and may contain parameters, e.g.
+// /api/v1/product/:id/images. Paths are matched case-insensitive, like the router does.
+func RouteBodyLimit(app *fiber.App, method, path string, limit int) {
+	segments := pathSegments(path)
+	server := app.Server()
+	previous := server.HeaderReceived
+	server.HeaderReceived = func(header *fasthttp.RequestHeader) fasthttp.RequestConfig {
+		if string(header.Method()) == method && matchSegments(segments, pathSegments(string(header.RequestURI()))) {
+			return fasthttp.RequestConfig{MaxRequestBodySize: limit}
+		}
+		if previous != nil {
+			return previous(header)
+		}
+		return fasthttp.RequestConfig{}
+	}
+}
+
+// pathSegments func for splitting the path of a route or request URI, the query is dropped.
+func pathSegments(path string) []string {
+	path, _, _ = strings.Cut(path, "?")
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchSegments func for matching the segments of a request to the segments of a route,
+// parameters (":id") match any segment.
+func matchSegments(route, request []string) bool {
+	if len(route) != len(request) {
+		return false
+	}
+	for i, segment := range route {
+		if strings.HasPrefix(segment, ":") {
+			if request[i] == "" {
+				return false
+			}
+		} else if !strings.EqualFold(segment, request[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -15,5 +15,7 @@ func FiberMiddleware(a *fiber.App) {
 		// Add simple logger.
 		logger.New(),
 		Maintenance,
+		// Pin reads after own writes to the primary database and set the audit actor.
+		UserContext,
 	)
 }
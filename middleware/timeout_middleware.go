@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestTimeout func for attaching a deadline to the request context.
+// Handlers pass c.UserContext() to the repositories, so slow queries are
+// aborted once the deadline is reached. A zero timeout falls back to
+// REQUEST_TIMEOUT_SECONDS from .env file (10 seconds if not set).
+//
+// fasthttp doesn't notice a client, which hangs up while the handler runs,
+// so a disconnect doesn't cancel the queries, they end at the deadline.
+func RequestTimeout(timeout time.Duration) fiber.Handler {
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout()
+	}
+
+	return func(c *fiber.Ctx) error {
+		// Keep values of the user context (e.g. read-your-writes session).
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		// The fasthttp request context is done when the server shuts down,
+		// so in-flight queries are cancelled as well.
+		stop := context.AfterFunc(c.Context(), cancel)
+		defer stop()
+
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
+
+func defaultRequestTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("REQUEST_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
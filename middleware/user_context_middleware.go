@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net"
+	"tuxiaocao/pkg/platform/database"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UserContext func for tagging database queries with the user ID and tenant of the JWT, if given.
+// Reads of a user go to the primary for a while after the user's own write,
+// so the user doesn't see stale data of a lagging read replica,
+// and writes of the user are recorded with the user as actor in the audit trail.
+// All queries are scoped to the tenant of the token; requests without a token
+// (e.g. sign up, sign in, public reads) use the tenant of the host from TENANT_HOSTS or DEFAULT_TENANT_ID.
+// The tenant is never taken from a header, and a request with an invalid token is rejected.
+func UserContext(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	tenant := models.TenantOfHost(hostname(c))
+	if c.Get(fiber.HeaderAuthorization) != "" {
+		claims, err := utils.ExtractTokenMetadata(c)
+		if err != nil {
+			// Return status 401 and JWT parse error.
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": true,
+				"msg":   "unauthorized, invalid token: " + err.Error(),
+			})
+		}
+		ctx = database.WithSession(ctx, claims.UserID)
+		ctx = models.WithActor(ctx, claims.UserID)
+		if claims.Credentials[repository.TenantAllCredential] {
+			ctx = models.WithSuperAdmin(ctx)
+		}
+		// Tokens issued before tenants belong to the default tenant.
+		tenant = claims.TenantID
+		if tenant == "" {
+			tenant = models.DefaultTenant()
+		}
+	}
+	c.SetUserContext(models.WithTenant(ctx, tenant))
+	return c.Next()
+}
+
+// hostname func for getting the host of the request without port.
+// X-Forwarded-Host is ignored, it's set by the client unless a proxy overwrites it.
+func hostname(c *fiber.Ctx) string {
+	host := string(c.Request().Host())
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return host
+}
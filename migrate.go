@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"tuxiaocao/pkg/platform/database"
+	"tuxiaocao/pkg/platform/migrations"
+	models2 "tuxiaocao/routes/models"
+)
+
+const migrateUsage = `usage: apiserver migrate <command>
+
+commands:
+  up [n]           apply all or the next n pending migrations
+  down [n|all]     revert the last n (default 1) or all applied migrations
+  status           print the applied version and pending migrations
+  force <version>  set the version without running migrations and clear the dirty flag`
+
+// RunMigrate runs the `migrate up|down|status|force` subcommand and returns the exit code.
+// Only one process migrates at a time, others wait for the lock up to MIGRATE_LOCK_TIMEOUT_SECONDS.
+func RunMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ReadyLogger()
+	if _, err := database.OpenDBConnection(); err != nil {
+		fmt.Fprintf(os.Stderr, "database is error %v\n", err)
+		return 1
+	}
+	migrator, err := migrations.New(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrations are error %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	var done []migrations.Migration
+	switch args[0] {
+	case "up":
+		steps, ok := migrateSteps(args, 0)
+		if !ok {
+			return 2
+		}
+		done, err = migrator.Up(ctx, steps)
+		printMigrations("applied", done)
+		// Rows of tables taken over from earlier versions have no tenant yet.
+		if err == nil && len(done) > 0 {
+			err = models2.BackfillTenant(ctx, models2.DefaultTenant())
+		}
+	case "down":
+		steps, ok := migrateSteps(args, 1)
+		if !ok {
+			return 2
+		}
+		done, err = migrator.Down(ctx, steps)
+		printMigrations("reverted", done)
+	case "force":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		err = migrator.Force(ctx, version)
+	case "status":
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s is error %v\n", args[0], err)
+		return 1
+	}
+
+	// Print status after every command.
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migration status is error %v\n", err)
+		return 1
+	}
+	fmt.Printf("version %d of %d, dirty %t\n", status.Version, status.Latest, status.Dirty)
+	printMigrations("pending", status.Pending)
+	return 0
+}
+
+// migrateSteps parses the optional number of steps, `all` means all migrations.
+func migrateSteps(args []string, steps int) (int, bool) {
+	if len(args) < 2 {
+		return steps, true
+	}
+	if args[1] == "all" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n <= 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 0, false
+	}
+	return n, true
+}
+
+func printMigrations(action string, list []migrations.Migration) {
+	for _, migration := range list {
+		fmt.Printf("%s %06d_%s\n", action, migration.Version, migration.Name)
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"database/sql"
+	"fmt"
 	"gorm.io/gorm"
 	"os"
 )
@@ -20,11 +22,41 @@ func OpenDBConnection() (db *gorm.DB, err error) {
 		db, err = PgSqlConnection()
 	case "mysql":
 		db, err = MysqlConnection()
+	case "sqlite":
+		db, err = SqliteConnection()
+	default:
+		err = fmt.Errorf("database type '%v' is not supported, use pgx, mysql or sqlite", dbType)
 	}
 	if err != nil {
 
 		return nil, err
 	}
+	// SQLite sizes its own pool, the connections of a database server are limited from .env file.
+	if dbType != "sqlite" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("error, not connected to database, %w", err)
+		}
+		configurePool(sqlDB)
+	}
+	// Route reads to replicas, if DB_REPLICA_HOSTS is set.
+	if err = OpenReplicas(db, dbType); err != nil {
+		return nil, err
+	}
 	DB = db
 	return
 }
+
+// configurePool func for applying DB_MAX_CONNECTIONS, DB_MAX_IDLE_CONNECTIONS and
+// DB_MAX_LIFETIME_CONNECTIONS (seconds) from .env file, unset values keep the defaults of database/sql.
+func configurePool(db *sql.DB) {
+	if maxConn := envInt("DB_MAX_CONNECTIONS", 0); maxConn > 0 {
+		db.SetMaxOpenConns(maxConn)
+	}
+	if maxIdleConn := envInt("DB_MAX_IDLE_CONNECTIONS", 0); maxIdleConn > 0 {
+		db.SetMaxIdleConns(maxIdleConn)
+	}
+	if maxLifetimeConn := envSeconds("DB_MAX_LIFETIME_CONNECTIONS", 0); maxLifetimeConn > 0 {
+		db.SetConnMaxLifetime(maxLifetimeConn)
+	}
+}
@@ -10,10 +10,7 @@ import (
 
 // MysqlConnection func for connection to Mysql database.
 func MysqlConnection() (*gorm.DB, error) {
-	// Define database connection settings.
-	//maxConn, _ := strconv.Atoi(os.Getenv("DB_MAX_CONNECTIONS"))
-	//maxIdleConn, _ := strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNECTIONS"))
-	//maxLifetimeConn, _ := strconv.Atoi(os.Getenv("DB_MAX_LIFETIME_CONNECTIONS"))
+	// Connection pool settings are applied by OpenDBConnection.
 
 	// Build Mysql connection URL.
 	mysqlConnURL, err := utils.ConnectionURLBuilder("mysql")
@@ -24,7 +21,8 @@ func MysqlConnection() (*gorm.DB, error) {
 
 	// Define database connection for Mysql.
 	config := mysql.Config{DSN: mysqlConnURL}
-	db, err := gorm.Open(mysql.New(config))
+	// Unique violations are returned as gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(mysql.New(config), &gorm.Config{TranslateError: true})
 	db = db.Debug()
 	if err != nil {
 		return nil, fmt.Errorf("error, not connected to database, %w", err)
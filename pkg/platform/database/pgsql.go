@@ -10,10 +10,7 @@ import (
 
 // MysqlConnection func for connection to Mysql database.
 func PgSqlConnection() (*gorm.DB, error) {
-	// Define database connection settings.
-	//maxConn, _ := strconv.Atoi(os.Getenv("DB_MAX_CONNECTIONS"))
-	//maxIdleConn, _ := strconv.Atoi(os.Getenv("DB_MAX_IDLE_CONNECTIONS"))
-	//maxLifetimeConn, _ := strconv.Atoi(os.Getenv("DB_MAX_LIFETIME_CONNECTIONS"))
+	// Connection pool settings are applied by OpenDBConnection.
 
 	// Build Mysql connection URL.
 	mysqlConnURL, err := utils.ConnectionURLBuilder("postgres")
@@ -23,7 +20,8 @@ func PgSqlConnection() (*gorm.DB, error) {
 	}
 
 	config := postgres.Config{DSN: mysqlConnURL}
-	db, err := gorm.Open(postgres.New(config))
+	// Unique violations are returned as gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(postgres.New(config), &gorm.Config{TranslateError: true})
 
 	// Define database connection for Mysql.
 	//config := mysql.Config{DSN: mysqlConnURL}
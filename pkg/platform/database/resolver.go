@@ -0,0 +1,348 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tuxiaocao/pkg/logger"
+	"tuxiaocao/utils"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Replicas is the read replica resolver of DB, nil if DB_REPLICA_HOSTS is empty.
+var Replicas *Resolver
+
+type contextKey int
+
+const (
+	sessionKey contextKey = iota
+	primaryKey
+)
+
+const replicaRouteKey = "replica:route"
+
+// WithSession func for marking queries of ctx as made by the given session (e.g. user ID).
+// After a write of the session its reads go to the primary for the read-your-writes window.
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey, session)
+}
+
+// WithPrimary func for pinning all reads of ctx to the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey, true)
+}
+
+// Resolver is a GORM plugin, which routes reads (Find, Take, Scan, Count) to healthy read replicas.
+// Writes, reads inside transactions, locking reads and reads of sessions with a recent write stay on the primary.
+type Resolver struct {
+	replicas    []*replica
+	next        atomic.Uint64
+	window      time.Duration // read-your-writes window
+	maxFailures int32         // consecutive failures before a replica is evicted
+	writes      sync.Map      // session => time.Time of the last write
+}
+
+type replica struct {
+	host     string
+	db       *sql.DB
+	failures atomic.Int32
+	evicted  atomic.Bool
+}
+
+// replicaRoute remembers the replica of a statement and the connection to restore afterwards.
+type replicaRoute struct {
+	replica  *replica
+	original gorm.ConnPool
+}
+
+// ReplicaStatus struct to describe health of a read replica.
+type ReplicaStatus struct {
+	Host     string `json:"host"`
+	Healthy  bool   `json:"healthy"`
+	Failures int32  `json:"failures"`
+}
+
+// NewResolver func for creating a resolver without replicas.
+func NewResolver(window time.Duration, maxFailures int) *Resolver {
+	if maxFailures <= 0 {
+		maxFailures = 1
+	}
+	return &Resolver{window: window, maxFailures: int32(maxFailures)}
+}
+
+// AddReplica func for adding a read replica connection.
+func (r *Resolver) AddReplica(host string, db *sql.DB) {
+	r.replicas = append(r.replicas, &replica{host: host, db: db})
+}
+
+// Name implements gorm.Plugin.
+func (r *Resolver) Name() string {
+	return "replica_resolver"
+}
+
+// Initialize implements gorm.Plugin and registers the routing callbacks.
+func (r *Resolver) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Query().Before("gorm:query").Register("replica:route", r.route),
+		callbacks.Query().After("gorm:query").Register("replica:release", r.release("gorm:query")),
+		callbacks.Row().Before("gorm:row").Register("replica:route", r.route),
+		callbacks.Row().After("gorm:row").Register("replica:release", r.release("gorm:row")),
+		callbacks.Create().After("gorm:create").Register("replica:track", r.track),
+		callbacks.Update().After("gorm:update").Register("replica:track", r.track),
+		callbacks.Delete().After("gorm:delete").Register("replica:track", r.track),
+		callbacks.Raw().After("gorm:raw").Register("replica:track", r.track),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// route func for switching the statement to a replica, if the read may go there.
+func (r *Resolver) route(db *gorm.DB) {
+	stmt := db.Statement
+	// Reads inside a transaction see its own writes only on the primary.
+	if _, ok := stmt.ConnPool.(gorm.TxCommitter); ok {
+		return
+	}
+	// SELECT ... FOR UPDATE takes locks on the primary.
+	if _, ok := stmt.Clauses["FOR"]; ok {
+		return
+	}
+	// Raw SQL is routed only if it's a SELECT.
+	if stmt.SQL.Len() > 0 && !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(stmt.SQL.String())), "SELECT") {
+		return
+	}
+	if r.pinned(stmt.Context) {
+		return
+	}
+
+	if rep := r.pick(); rep != nil {
+		stmt.Settings.Store(replicaRouteKey, &replicaRoute{replica: rep, original: stmt.ConnPool})
+		stmt.ConnPool = rep.db
+	}
+}
+
+// release func for restoring the primary connection and counting connection errors of the replica.
+// A read, which failed because the replica is unreachable, is run once more on the primary by the callback of the given name.
+func (r *Resolver) release(name string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.Statement.Settings.LoadAndDelete(replicaRouteKey)
+		if !ok {
+			return
+		}
+		route := value.(*replicaRoute)
+		db.Statement.ConnPool = route.original
+
+		err := db.Error
+		// Row keeps the error of the query in *sql.Row until Scan.
+		if row, ok := db.Statement.Dest.(*sql.Row); ok && err == nil {
+			err = row.Err()
+		}
+		if !isConnError(err) {
+			return
+		}
+		r.fail(route.replica, err)
+		if db.Statement.Context != nil && db.Statement.Context.Err() != nil {
+			return
+		}
+		var retry func(*gorm.DB)
+		if name == "gorm:row" {
+			retry = db.Callback().Row().Get(name)
+		} else {
+			retry = db.Callback().Query().Get(name)
+		}
+		db.Error, db.RowsAffected = nil, 0
+		retry(db)
+	}
+}
+
+// track func for remembering the time of the last write of the session.
+func (r *Resolver) track(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Context == nil || r.window <= 0 {
+		return
+	}
+	if session, ok := db.Statement.Context.Value(sessionKey).(string); ok && session != "" {
+		r.writes.Store(session, time.Now())
+	}
+}
+
+// pinned func for checking, if reads of ctx have to go to the primary.
+func (r *Resolver) pinned(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if primary, _ := ctx.Value(primaryKey).(bool); primary {
+		return true
+	}
+	session, _ := ctx.Value(sessionKey).(string)
+	if session == "" {
+		return false
+	}
+	written, ok := r.writes.Load(session)
+	return ok && time.Since(written.(time.Time)) < r.window
+}
+
+// pick func for choosing the next healthy replica (round robin), nil if all are evicted.
+func (r *Resolver) pick() *replica {
+	n := uint64(len(r.replicas))
+	if n == 0 {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; !rep.evicted.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// fail func for counting a failure, the replica is evicted after maxFailures in a row.
+func (r *Resolver) fail(rep *replica, err error) {
+	if rep.failures.Add(1) >= r.maxFailures && !rep.evicted.Swap(true) {
+		logger.Log.Warnf("replica %s is evicted: %v", rep.host, err)
+	}
+}
+
+// heal func for resetting failures, an evicted replica is taken back.
+func (r *Resolver) heal(rep *replica) {
+	rep.failures.Store(0)
+	if rep.evicted.Swap(false) {
+		logger.Log.Infof("replica %s is healthy again", rep.host)
+	}
+}
+
+// Check func for pinging all replicas once.
+func (r *Resolver) Check(ctx context.Context, timeout time.Duration) {
+	for _, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := rep.db.PingContext(pingCtx)
+		cancel()
+		if err != nil {
+			r.fail(rep, err)
+		} else {
+			r.heal(rep)
+		}
+	}
+
+	// Forget sessions, which are out of the read-your-writes window.
+	r.writes.Range(func(session, written any) bool {
+		if time.Since(written.(time.Time)) >= r.window {
+			r.writes.Delete(session)
+		}
+		return true
+	})
+}
+
+// Run func for checking replicas every interval, until ctx is done.
+func (r *Resolver) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Check(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status func for getting health of all replicas.
+func (r *Resolver) Status() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, len(r.replicas))
+	for i, rep := range r.replicas {
+		statuses[i] = ReplicaStatus{Host: rep.host, Healthy: !rep.evicted.Load(), Failures: rep.failures.Load()}
+	}
+	return statuses
+}
+
+func isConnError(err error) bool {
+	var netErr net.Error
+	return err != nil && (errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr))
+}
+
+// OpenReplicas func for connecting read replicas from DB_REPLICA_HOSTS (comma-separated host or host:port).
+func OpenReplicas(db *gorm.DB, dbType string) error {
+	hosts := strings.TrimSpace(os.Getenv("DB_REPLICA_HOSTS"))
+	if hosts == "" {
+		return nil
+	}
+
+	name := dbType
+	if dbType == "pgx" {
+		name = "postgres"
+	}
+	resolver := NewResolver(envSeconds("DB_READ_YOUR_WRITES_SECONDS", 5), envInt("DB_REPLICA_MAX_FAILURES", 3))
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		hostname, port := host, os.Getenv("DB_PORT")
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			hostname, port = h, p
+		}
+
+		// Build replica connection URL.
+		url, err := utils.DatabaseURLBuilder(name, hostname, port)
+		if err != nil {
+			return err
+		}
+		var dialector gorm.Dialector
+		if name == "postgres" {
+			dialector = postgres.New(postgres.Config{DSN: url})
+		} else {
+			dialector = mysql.New(mysql.Config{DSN: url, SkipInitializeWithVersion: true})
+		}
+
+		// A replica, which is down on start, is evicted by the health check instead of failing the start.
+		replicaDB, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
+		if err != nil {
+			return fmt.Errorf("error, not connected to replica %s, %w", host, err)
+		}
+		sqlDB, err := replicaDB.DB()
+		if err != nil {
+			return fmt.Errorf("error, not connected to replica %s, %w", host, err)
+		}
+		configurePool(sqlDB)
+		resolver.AddReplica(host, sqlDB)
+	}
+
+	if err := db.Use(resolver); err != nil {
+		return err
+	}
+	Replicas = resolver
+	go resolver.Run(context.Background(), envSeconds("DB_REPLICA_CHECK_SECONDS", 5))
+	return nil
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+func envSeconds(key string, fallback int) time.Duration {
+	return time.Duration(envInt(key, fallback)) * time.Second
+}
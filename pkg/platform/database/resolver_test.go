@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+	"tuxiaocao/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	// Log to stdout instead of files.
+	logger.Replace(logger.New("", "", "debug", time.Hour, time.Hour, 100))
+	os.Exit(m.Run())
+}
+
+// downConnector is a replica, which refuses every connection.
+type downConnector struct{}
+
+func (downConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (downConnector) Driver() driver.Driver {
+	return nil
+}
+
+// unreachableConnector is a replica, which can't be dialed.
+type unreachableConnector struct{}
+
+func (unreachableConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+}
+
+func (unreachableConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestResolverEviction(t *testing.T) {
+	resolver := NewResolver(time.Minute, 2)
+	resolver.AddReplica("replica1", sql.OpenDB(downConnector{}))
+	resolver.AddReplica("replica2", sql.OpenDB(downConnector{}))
+
+	// Both replicas are used in turn.
+	assert.NotEqual(t, resolver.pick().host, resolver.pick().host)
+
+	// Failing replicas are evicted after two checks.
+	resolver.Check(context.Background(), time.Second)
+	assert.NotNil(t, resolver.pick())
+	resolver.Check(context.Background(), time.Second)
+	assert.Nil(t, resolver.pick())
+	assert.Equal(t, []ReplicaStatus{
+		{Host: "replica1", Healthy: false, Failures: 2},
+		{Host: "replica2", Healthy: false, Failures: 2},
+	}, resolver.Status())
+
+	// A healthy replica is taken back.
+	resolver.heal(resolver.replicas[1])
+	assert.Equal(t, "replica2", resolver.pick().host)
+}
+
+func TestResolverReadYourWrites(t *testing.T) {
+	resolver := NewResolver(time.Minute, 1)
+	ctx := WithSession(context.Background(), "1")
+
+	assert.False(t, resolver.pinned(ctx))
+	assert.True(t, resolver.pinned(WithPrimary(context.Background())))
+
+	// After a write of the session its reads stay on the primary.
+	resolver.track(&gorm.DB{Statement: &gorm.Statement{Context: ctx}})
+	assert.True(t, resolver.pinned(ctx))
+	assert.False(t, resolver.pinned(WithSession(context.Background(), "2")))
+}
+
+func TestResolverFallback(t *testing.T) {
+	t.Setenv("DB_NAME", ":memory:")
+	db, err := SqliteConnection()
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)").Error)
+	require.NoError(t, db.Exec("INSERT INTO items (name) VALUES (?), (?)", "a", "b").Error)
+	resolver := NewResolver(time.Minute, 3)
+	resolver.AddReplica("replica", sql.OpenDB(unreachableConnector{}))
+	require.NoError(t, db.Use(resolver))
+
+	// Reads, which fail on the unreachable replica, are answered by the primary.
+	var names []string
+	require.NoError(t, db.Table("items").Order("id").Pluck("name", &names).Error)
+	assert.Equal(t, []string{"a", "b"}, names)
+	var count int64
+	require.NoError(t, db.Table("items").Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	var name string
+	require.NoError(t, db.Table("items").Select("name").Where("id = ?", 2).Row().Scan(&name))
+	assert.Equal(t, "b", name)
+
+	// Every failed read counts, so the replica is evicted.
+	assert.Equal(t, []ReplicaStatus{{Host: "replica", Healthy: false, Failures: 3}}, resolver.Status())
+	assert.Nil(t, resolver.pick())
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SqliteParams are the connection parameters of a SQLite database file.
+const SqliteParams = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+// SqliteConnection func for connection to an embedded SQLite database (pure Go, no CGO needed).
+// DB_NAME is the path of the database file, ":memory:" keeps the database in memory until the process ends.
+func SqliteConnection() (*gorm.DB, error) {
+	name := os.Getenv("DB_NAME")
+	memory := name == "" || name == ":memory:"
+
+	dsn := ":memory:"
+	if !memory {
+		// Create folder of the database file.
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return nil, fmt.Errorf("error, not connected to database, %w", err)
+		}
+		// Wait for locks of other connections, readers don't block the writer.
+		// Transactions take the write lock up front, a read that is upgraded to a write
+		// fails with SQLITE_BUSY without waiting when another transaction is writing.
+		dsn = name + "?" + SqliteParams
+		if strings.Contains(name, "?") {
+			dsn = name + "&" + SqliteParams
+		}
+	}
+
+	// Missing rows are answered with 404, they are not worth a log line.
+	// Unique violations are returned as gorm.ErrDuplicatedKey.
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  true,
+		}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error, not connected to database, %w", err)
+	}
+
+	// Every connection gets its own in-memory database,
+	// so keep exactly one connection open for the lifetime of the process.
+	if memory {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("error, not connected to database, %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+	return db, nil
+}
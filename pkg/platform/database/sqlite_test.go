@@ -0,0 +1,36 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqliteConnection(t *testing.T) {
+	tests := []struct {
+		description string
+		name        string
+	}{
+		{description: "in-memory database", name: ":memory:"},
+		{description: "database file in a new folder", name: filepath.Join(t.TempDir(), "data", "test.db")},
+	}
+
+	for _, test := range tests {
+		t.Setenv("DB_NAME", test.name)
+		db, err := SqliteConnection()
+		require.NoError(t, err, test.description)
+
+		// Tables are kept between queries, also in memory.
+		require.NoError(t, db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT)").Error, test.description)
+		require.NoError(t, db.Exec("INSERT INTO items (name) VALUES (?), (?)", "a", "b").Error, test.description)
+		var count int64
+		require.NoError(t, db.Table("items").Count(&count).Error, test.description)
+		assert.Equal(t, int64(2), count, test.description)
+
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDB.Close())
+	}
+}
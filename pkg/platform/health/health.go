@@ -0,0 +1,205 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// StatusUp and StatusDown describe the result of one check.
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	// StatusOK means all checks are up, StatusDegraded means only optional checks are down.
+	// Both are ready, StatusFail (a critical check is down) and StatusShuttingDown are not.
+	StatusOK           = "ok"
+	StatusDegraded     = "degraded"
+	StatusFail         = "fail"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Probe func to check one dependency, it should return when ctx is done.
+type Probe func(ctx context.Context) error
+
+// Check struct to describe a dependency of the service.
+// A critical check, which is down, makes the service not ready, an optional one only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	Timeout  time.Duration // zero means READY_CHECK_TIMEOUT_SECONDS
+	Probe    Probe
+}
+
+// Result struct to describe the outcome of one check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report struct to describe the readiness of the service.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Ready func for checking, if the service can take traffic.
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+// Registry struct to hold the checks of the service.
+type Registry struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry func for creating a registry without checks.
+func NewRegistry() *Registry {
+	return &Registry{checks: map[string]Check{}}
+}
+
+// Default is the registry of the running service.
+var Default = NewRegistry()
+
+// Register func for adding a check to the default registry.
+func Register(check Check) {
+	Default.Register(check)
+}
+
+// SetShuttingDown func for marking the service as not ready during graceful shutdown.
+func SetShuttingDown() {
+	Default.SetShuttingDown()
+}
+
+// Ready func for running all checks of the default registry.
+func Ready(ctx context.Context) *Report {
+	return Default.Ready(ctx)
+}
+
+// Register func for adding a check, a check with the same name is replaced.
+func (r *Registry) Register(check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[check.Name] = check
+}
+
+// SetShuttingDown func for failing readiness from now on, so no new traffic is routed to the service.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Ready func for running all checks in parallel, each with its own timeout.
+func (r *Registry) Ready(ctx context.Context) *Report {
+	r.mu.RLock()
+	checks := make([]Check, 0, len(r.checks))
+	for _, check := range r.checks {
+		checks = append(checks, check)
+	}
+	r.mu.RUnlock()
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+
+	report := &Report{Status: StatusOK, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusFail
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+	if r.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// run func for running one check, a probe ignoring ctx is abandoned after the timeout.
+func run(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = CheckTimeout()
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if d := recover(); d != nil {
+				done <- fmt.Errorf("check panicked: %v", d)
+			}
+		}()
+		done <- check.Probe(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timeout after %s", timeout)
+	}
+
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// CheckTimeout func for getting READY_CHECK_TIMEOUT_SECONDS from .env file (2 seconds if not set).
+func CheckTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("READY_CHECK_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return 2 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// IsCritical func for checking, if the dependency name is listed in READY_CRITICAL_CHECKS
+// (comma-separated, "database,redis" if not set). Other dependencies are optional.
+func IsCritical(name string) bool {
+	critical, ok := os.LookupEnv("READY_CRITICAL_CHECKS")
+	if !ok {
+		critical = "database,redis"
+	}
+	for _, item := range strings.Split(critical, ",") {
+		if strings.TrimSpace(item) == name {
+			return true
+		}
+	}
+	return false
+}
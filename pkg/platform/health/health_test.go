@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryReady(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	// Ignores ctx, so the check has to be abandoned.
+	hanging := func(ctx context.Context) error { time.Sleep(time.Second); return nil }
+
+	tests := []struct {
+		description string
+		checks      []Check
+		status      string
+		ready       bool
+	}{
+		{
+			description: "all checks up",
+			checks:      []Check{{Name: "database", Critical: true, Probe: up}, {Name: "kafka", Probe: up}},
+			status:      StatusOK,
+			ready:       true,
+		},
+		{
+			description: "optional check down",
+			checks:      []Check{{Name: "database", Critical: true, Probe: up}, {Name: "kafka", Probe: down}},
+			status:      StatusDegraded,
+			ready:       true,
+		},
+		{
+			description: "critical check down",
+			checks:      []Check{{Name: "database", Critical: true, Probe: down}, {Name: "kafka", Probe: up}},
+			status:      StatusFail,
+			ready:       false,
+		},
+		{
+			description: "critical check times out",
+			checks:      []Check{{Name: "database", Critical: true, Timeout: 20 * time.Millisecond, Probe: hanging}},
+			status:      StatusFail,
+			ready:       false,
+		},
+		{
+			description: "no checks",
+			status:      StatusOK,
+			ready:       true,
+		},
+	}
+
+	for _, test := range tests {
+		registry := NewRegistry()
+		for _, check := range test.checks {
+			registry.Register(check)
+		}
+		report := registry.Ready(context.Background())
+		assert.Equal(t, test.status, report.Status, test.description)
+		assert.Equal(t, test.ready, report.Ready(), test.description)
+		assert.Len(t, report.Checks, len(test.checks), test.description)
+	}
+}
+
+func TestRegistryShuttingDown(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Check{Name: "redis", Critical: true, Probe: func(ctx context.Context) error { return nil }})
+	require.True(t, registry.Ready(context.Background()).Ready())
+
+	registry.SetShuttingDown()
+	report := registry.Ready(context.Background())
+	assert.Equal(t, StatusShuttingDown, report.Status)
+	assert.False(t, report.Ready())
+	// Checks are still reported.
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, StatusUp, report.Checks[0].Status)
+}
+
+func TestIsCritical(t *testing.T) {
+	t.Setenv("READY_CRITICAL_CHECKS", "database, etcd")
+	assert.True(t, IsCritical("database"))
+	assert.True(t, IsCritical("etcd"))
+	assert.False(t, IsCritical("redis"))
+}
@@ -0,0 +1,382 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tuxiaocao/pkg/platform/database"
+
+	"gorm.io/gorm"
+)
+
+// Migrations per dialect, named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed postgres mysql sqlite
+var embedded embed.FS
+
+const (
+	// versionTable is compatible with golang-migrate/migrate, so the CLI can still be used.
+	versionTable = "schema_migrations"
+	// lockName identifies the migration lock (GET_LOCK name on MySQL, advisory lock key on Postgres).
+	lockName = "tuxiaocao_migrate"
+	lockKey  = 7359127380
+)
+
+var (
+	// ErrDirty is returned, if a migration failed halfway and the schema has to be fixed by hand.
+	ErrDirty = errors.New("database is dirty, fix the schema and run `migrate force <version>`")
+	// ErrLocked is returned, if another process holds the migration lock longer than the lock timeout.
+	ErrLocked = errors.New("migration lock is held by another process")
+)
+
+// Migration struct to describe one version of the schema.
+type Migration struct {
+	Version uint64 `json:"version"`
+	Name    string `json:"name"`
+	up      string
+	down    string
+}
+
+// Status struct to describe the applied version of the schema and the pending migrations.
+type Status struct {
+	Version uint64      `json:"version"`
+	Dirty   bool        `json:"dirty"`
+	Latest  uint64      `json:"latest"`
+	Pending []Migration `json:"pending"`
+}
+
+// Behind func for checking, if migrations are pending or the last one failed.
+func (s *Status) Behind() bool {
+	return s.Dirty || len(s.Pending) > 0
+}
+
+// Migrator struct to apply migrations with a lock, so only one process migrates at a time.
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+// New func for creating a migrator with the embedded migrations of the database dialect.
+func New(db *gorm.DB) (*Migrator, error) {
+	dialect := db.Dialector.Name()
+	fsys, err := fs.Sub(embedded, dialect)
+	if err == nil {
+		_, err = fs.Stat(fsys, ".")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database %s", dialect)
+	}
+	return NewWithFS(db, fsys)
+}
+
+// NewWithFS func for creating a migrator with the migrations in fsys.
+func NewWithFS(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, LockTimeout: lockTimeout()}, nil
+}
+
+// lockTimeout func for getting MIGRATE_LOCK_TIMEOUT_SECONDS from .env file (60 seconds if not set).
+func lockTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("MIGRATE_LOCK_TIMEOUT_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return time.Minute
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Load func for reading migrations from fsys, sorted by version.
+// Every version needs an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		number, title, found := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(number, 10, 64)
+		if !found || err != nil || version == 0 || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("migration file %s is not named <version>_<name>.up|down.sql", name)
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: title}
+			byVersion[version] = migration
+		}
+		if migration.Name != title {
+			return nil, fmt.Errorf("migration %d has different names %s and %s", version, migration.Name, title)
+		}
+		if direction == ".up" {
+			migration.up = string(data)
+		} else {
+			migration.down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status func for getting the applied version and the pending migrations.
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	db := m.session(ctx)
+	status := &Status{}
+	if len(m.migrations) > 0 {
+		status.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+	if db.Migrator().HasTable(versionTable) {
+		var err error
+		if status.Version, status.Dirty, err = readVersion(db); err != nil {
+			return nil, err
+		}
+	}
+	for _, migration := range m.migrations {
+		if migration.Version > status.Version {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status, nil
+}
+
+// Up func for applying the next steps pending migrations, all of them if steps <= 0.
+func (m *Migrator) Up(ctx context.Context, steps int) (applied []Migration, err error) {
+	err = m.locked(ctx, func(db *gorm.DB) error {
+		version, dirty, err := readVersion(db)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w (version %d)", ErrDirty, version)
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if steps > 0 && len(applied) == steps {
+				break
+			}
+			if err = m.apply(db, migration.up, migration.Version, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down func for reverting the last steps applied migrations, all of them if steps <= 0.
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []Migration, err error) {
+	err = m.locked(ctx, func(db *gorm.DB) error {
+		version, dirty, err := readVersion(db)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("%w (version %d)", ErrDirty, version)
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			if steps > 0 && len(reverted) == steps {
+				break
+			}
+			previous := uint64(0)
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err = m.apply(db, migration.down, migration.Version, previous); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force func for setting the version without running migrations and clearing the dirty flag,
+// e.g. after fixing a failed migration by hand. Version 0 means no migration is applied.
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("migration %d is not known", version)
+	}
+	return m.locked(ctx, func(db *gorm.DB) error {
+		return writeVersion(db, version, false)
+	})
+}
+
+func (m *Migrator) known(version uint64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// session func for pinning the migrator to the primary, reads of a replica may be stale.
+func (m *Migrator) session(ctx context.Context) *gorm.DB {
+	return m.db.WithContext(database.WithPrimary(ctx))
+}
+
+// locked func for running fn on one connection, while holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(db *gorm.DB) error) error {
+	return m.session(ctx).Connection(func(db *gorm.DB) error {
+		unlock, err := m.lock(db)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		err = db.Exec("CREATE TABLE IF NOT EXISTS " + versionTable + " (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)").Error
+		if err != nil {
+			return err
+		}
+		return fn(db)
+	})
+}
+
+// lock func for taking the migration lock of the connection, other dialects (e.g. SQLite) lock the whole file on write.
+func (m *Migrator) lock(db *gorm.DB) (unlock func(), err error) {
+	switch db.Dialector.Name() {
+	case "postgres":
+		deadline := time.Now().Add(m.LockTimeout)
+		for {
+			var locked bool
+			if err = db.Raw("SELECT pg_try_advisory_lock(?)", lockKey).Scan(&locked).Error; err != nil {
+				return nil, err
+			}
+			if locked {
+				return func() { db.Exec("SELECT pg_advisory_unlock(?)", lockKey) }, nil
+			}
+			if time.Now().After(deadline) {
+				return nil, ErrLocked
+			}
+			time.Sleep(time.Second)
+		}
+	case "mysql":
+		// GET_LOCK returns 1 if locked, 0 on timeout and NULL on error.
+		var locked sql.NullInt64
+		if err = db.Raw("SELECT GET_LOCK(?, ?)", lockName, int(m.LockTimeout.Seconds())).Scan(&locked).Error; err != nil {
+			return nil, err
+		}
+		if locked.Int64 != 1 {
+			return nil, ErrLocked
+		}
+		return func() { db.Exec("SELECT RELEASE_LOCK(?)", lockName) }, nil
+	}
+	return func() {}, nil
+}
+
+// apply func for running the statements of a migration.
+// The schema is marked dirty at version before and clean at target after the statements,
+// so a migration failing halfway is visible. DDL of MySQL can't be rolled back,
+// other dialects run the statements in a transaction.
+func (m *Migrator) apply(db *gorm.DB, script string, version, target uint64) error {
+	if err := writeVersion(db, version, true); err != nil {
+		return err
+	}
+	run := func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var err error
+	if db.Dialector.Name() == "mysql" {
+		err = run(db)
+	} else {
+		err = db.Transaction(run)
+	}
+	if err != nil {
+		return err
+	}
+	return writeVersion(db, target, false)
+}
+
+func readVersion(db *gorm.DB) (version uint64, dirty bool, err error) {
+	var rows []struct {
+		Version uint64
+		Dirty   bool
+	}
+	err = db.Raw("SELECT version, dirty FROM " + versionTable + " LIMIT 1").Scan(&rows).Error
+	if err != nil || len(rows) == 0 {
+		return 0, false, err
+	}
+	return rows[0].Version, rows[0].Dirty, nil
+}
+
+func writeVersion(db *gorm.DB, version uint64, dirty bool) error {
+	if err := db.Exec("DELETE FROM " + versionTable).Error; err != nil {
+		return err
+	}
+	if version == 0 && !dirty {
+		return nil
+	}
+	return db.Exec("INSERT INTO "+versionTable+" (version, dirty) VALUES (?, ?)", version, dirty).Error
+}
+
+// splitStatements func for splitting a migration into statements, which end with `;` at the end of a line.
+// Comment lines are dropped, dollar-quoted bodies (e.g. Postgres functions) are kept together.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	dollarQuoted := false
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !dollarQuoted && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		if strings.Count(line, "$$")%2 == 1 {
+			dollarQuoted = !dollarQuoted
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if !dollarQuoted && strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
@@ -0,0 +1,179 @@
+package migrations
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	require.NoError(t, err)
+	return db
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := fstest.MapFS{
+		"000001_create_a.up.sql":   {Data: []byte("-- Create table a\nCREATE TABLE a (id INT);\nCREATE INDEX idx_a ON a (id);\n")},
+		"000001_create_a.down.sql": {Data: []byte("DROP TABLE a;\n")},
+		"000002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);\n")},
+		"000002_create_b.down.sql": {Data: []byte("DROP TABLE b;\n")},
+		"000003_broken.up.sql":     {Data: []byte("CREATE TABLE c (id INT);\nCREATE TABLE c (id INT);\n")},
+		"000003_broken.down.sql":   {Data: []byte("DROP TABLE c;\n")},
+	}
+	migrator, err := NewWithFS(db, fsys)
+	require.NoError(t, err)
+
+	status, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), status.Version)
+	assert.Equal(t, uint64(3), status.Latest)
+	assert.Len(t, status.Pending, 3)
+	assert.True(t, status.Behind())
+
+	// Apply two steps.
+	applied, err := migrator.Up(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.True(t, db.Migrator().HasTable("a"))
+	assert.True(t, db.Migrator().HasTable("b"))
+
+	// The broken migration is rolled back and leaves the schema dirty.
+	_, err = migrator.Up(ctx, 0)
+	assert.Error(t, err)
+	assert.False(t, db.Migrator().HasTable("c"))
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), status.Version)
+	assert.True(t, status.Dirty)
+	_, err = migrator.Down(ctx, 1)
+	assert.ErrorIs(t, err, ErrDirty)
+
+	// Force clears the dirty flag, then migrations can be reverted.
+	require.NoError(t, migrator.Force(ctx, 2))
+	assert.Error(t, migrator.Force(ctx, 9))
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, uint64(2), reverted[0].Version)
+	assert.False(t, db.Migrator().HasTable("b"))
+
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), status.Version)
+	assert.False(t, status.Dirty)
+	assert.Len(t, status.Pending, 2)
+
+	// Revert all.
+	_, err = migrator.Down(ctx, 0)
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("a"))
+	status, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), status.Version)
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		description string
+		fsys        fstest.MapFS
+		expectError bool
+	}{
+		{
+			description: "missing down file",
+			fsys:        fstest.MapFS{"000001_a.up.sql": {Data: []byte("SELECT 1;")}},
+			expectError: true,
+		},
+		{
+			description: "bad file name",
+			fsys:        fstest.MapFS{"a.up.sql": {Data: []byte("SELECT 1;")}},
+			expectError: true,
+		},
+		{
+			description: "other files are ignored",
+			fsys: fstest.MapFS{
+				"README.md":         {Data: []byte("# migrations")},
+				"000001_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"000001_a.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Load(test.fsys)
+		assert.Equal(t, test.expectError, err != nil, test.description)
+	}
+
+	// Embedded migrations of all dialects can be loaded.
+	for _, dialect := range []string{"postgres", "mysql", "sqlite"} {
+		fsys, err := fs.Sub(embedded, dialect)
+		require.NoError(t, err, dialect)
+		migrations, err := Load(fsys)
+		require.NoError(t, err, dialect)
+		assert.NotEmpty(t, migrations, dialect)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- Comment
+CREATE TABLE a (
+    id INT
+);
+
+CREATE FUNCTION f() RETURNS trigger AS $$
+BEGIN
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+SELECT 1`
+	statements := splitStatements(script)
+	require.Len(t, statements, 3)
+	assert.Equal(t, "CREATE TABLE a (\n    id INT\n);", statements[0])
+	assert.Contains(t, statements[1], "END;")
+	assert.Equal(t, "SELECT 1", statements[2])
+}
+
+func TestUpgradeStatements(t *testing.T) {
+	// The upgrade of AutoMigrate tables on Postgres is one DO block.
+	fsys, err := fs.Sub(embedded, "postgres")
+	require.NoError(t, err)
+	migrations, err := Load(fsys)
+	require.NoError(t, err)
+	var blocks []string
+	for _, statement := range splitStatements(migrations[0].up) {
+		if strings.HasPrefix(statement, "DO $$") {
+			blocks = append(blocks, statement)
+		}
+	}
+	require.Len(t, blocks, 1)
+	assert.Contains(t, blocks[0], "ALTER TABLE log_records ALTER COLUMN id TYPE CHAR (36) USING id::text;")
+	assert.True(t, strings.HasSuffix(blocks[0], "$$;"))
+
+	// Every conditional DDL of MySQL is prepared and executed.
+	fsys, err = fs.Sub(embedded, "mysql")
+	require.NoError(t, err)
+	migrations, err = Load(fsys)
+	require.NoError(t, err)
+	prepared, executed := 0, 0
+	for _, statement := range splitStatements(migrations[0].up) {
+		switch {
+		case strings.HasPrefix(statement, "SET @ddl"):
+			assert.Equal(t, prepared, executed, statement)
+			prepared++
+		case statement == "EXECUTE ddl;":
+			executed++
+		}
+	}
+	assert.Equal(t, 14, prepared)
+	assert.Equal(t, prepared, executed)
+}
@@ -0,0 +1,112 @@
+-- Tables are created only if missing, tables of earlier versions (created by GORM AutoMigrate)
+-- are upgraded below and taken over as version 1. Rows without tenant get DEFAULT_TENANT_ID on start.
+
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    tenant_id VARCHAR (64),
+    username VARCHAR (255),
+    password_hash VARCHAR (255),
+    user_status BIGINT,
+    user_role VARCHAR (25),
+    created_at DATETIME (3) NULL,
+    updated_at DATETIME (3) NULL,
+    delete_at DATETIME (3) NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    INDEX idx_users_tenant_id (tenant_id),
+    INDEX idx_users_delete_at (delete_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Create products table with full-text search of title, author and description
+CREATE TABLE IF NOT EXISTS products (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    user_id VARCHAR (64),
+    title VARCHAR (255),
+    author VARCHAR (255),
+    product_status BIGINT,
+    product_attrs JSON,
+    search_description TEXT GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(product_attrs, '$.description'))) STORED,
+    created_at DATETIME (3) NULL,
+    updated_at DATETIME (3) NULL,
+    delete_at DATETIME (3) NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    INDEX idx_products_tenant_id (tenant_id),
+    INDEX idx_products_delete_at (delete_at),
+    FULLTEXT INDEX idx_products_search (title, author, search_description)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Create audit trail table
+CREATE TABLE IF NOT EXISTS log_records (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    user_id VARCHAR (64),
+    entity VARCHAR (64),
+    entity_id VARCHAR (64),
+    action VARCHAR (16),
+    changes JSON,
+    created_at DATETIME (3) NULL,
+    updated_at DATETIME (3) NULL,
+    delete_at DATETIME (3) NULL,
+    version BIGINT NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    INDEX idx_log_records_tenant_id (tenant_id),
+    INDEX idx_log_records_entity (entity, entity_id),
+    INDEX idx_log_records_delete_at (delete_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Upgrade tables of earlier versions. MySQL has no ADD COLUMN IF NOT EXISTS,
+-- so every step looks the column or index up and runs its DDL, or DO 0 if it's there.
+
+-- Keys of products and audit records are UUIDs, earlier versions declared them as BIGINT
+SET @ddl = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND COLUMN_NAME = 'id') = 'char', 'DO 0', 'ALTER TABLE products MODIFY id CHAR (36) NOT NULL');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'log_records' AND COLUMN_NAME = 'id') = 'char', 'DO 0', 'ALTER TABLE log_records MODIFY id CHAR (36) NOT NULL');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+
+-- Add tenant and row version to users
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'tenant_id') > 0, 'DO 0', 'ALTER TABLE users ADD COLUMN tenant_id VARCHAR (64) AFTER id, ADD INDEX idx_users_tenant_id (tenant_id)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND COLUMN_NAME = 'version') > 0, 'DO 0', 'ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND INDEX_NAME = 'idx_users_delete_at') > 0, 'DO 0', 'ALTER TABLE users ADD INDEX idx_users_delete_at (delete_at)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+
+-- Add tenant, row version and full-text search to products
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND COLUMN_NAME = 'tenant_id') > 0, 'DO 0', 'ALTER TABLE products ADD COLUMN tenant_id VARCHAR (64) AFTER id, ADD INDEX idx_products_tenant_id (tenant_id)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND COLUMN_NAME = 'version') > 0, 'DO 0', 'ALTER TABLE products ADD COLUMN version BIGINT NOT NULL DEFAULT 1');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND COLUMN_NAME = 'search_description') > 0, 'DO 0', 'ALTER TABLE products ADD COLUMN search_description TEXT GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(product_attrs, ''$.description''))) STORED');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND INDEX_NAME = 'idx_products_search') > 0, 'DO 0', 'ALTER TABLE products ADD FULLTEXT INDEX idx_products_search (title, author, search_description)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'products' AND INDEX_NAME = 'idx_products_delete_at') > 0, 'DO 0', 'ALTER TABLE products ADD INDEX idx_products_delete_at (delete_at)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+
+-- Add tenant, entity and changes to audit records
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'log_records' AND COLUMN_NAME = 'tenant_id') > 0, 'DO 0', 'ALTER TABLE log_records ADD COLUMN tenant_id VARCHAR (64) AFTER id, ADD INDEX idx_log_records_tenant_id (tenant_id)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'log_records' AND COLUMN_NAME = 'entity') > 0, 'DO 0', 'ALTER TABLE log_records ADD COLUMN entity VARCHAR (64), ADD COLUMN entity_id VARCHAR (64), ADD COLUMN action VARCHAR (16), ADD COLUMN changes JSON, ADD INDEX idx_log_records_entity (entity, entity_id)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'log_records' AND COLUMN_NAME = 'version') > 0, 'DO 0', 'ALTER TABLE log_records ADD COLUMN version BIGINT NOT NULL DEFAULT 1');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'log_records' AND INDEX_NAME = 'idx_log_records_delete_at') > 0, 'DO 0', 'ALTER TABLE log_records ADD INDEX idx_log_records_delete_at (delete_at)');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
@@ -0,0 +1,13 @@
+-- Create product revisions table, one snapshot per version of a product
+CREATE TABLE IF NOT EXISTS product_revisions (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    revision BIGINT NOT NULL,
+    user_id VARCHAR (64),
+    snapshot JSON,
+    created_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_product_revisions_tenant_id (tenant_id),
+    UNIQUE INDEX idx_product_revisions_revision (product_id, revision)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
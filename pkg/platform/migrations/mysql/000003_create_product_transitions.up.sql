@@ -0,0 +1,15 @@
+-- Create product transitions table, one row per state change of a product
+CREATE TABLE IF NOT EXISTS product_transitions (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    name VARCHAR (32),
+    from_state INT,
+    to_state INT,
+    user_id VARCHAR (64),
+    comment TEXT,
+    created_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_product_transitions_tenant_id (tenant_id),
+    INDEX idx_product_transitions_product_id (product_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
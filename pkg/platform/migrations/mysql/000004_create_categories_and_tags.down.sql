@@ -0,0 +1,5 @@
+-- Delete tables
+DROP TABLE IF EXISTS product_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS product_categories;
+DROP TABLE IF EXISTS categories;
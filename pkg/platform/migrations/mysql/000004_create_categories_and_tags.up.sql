@@ -0,0 +1,54 @@
+-- Create categories table, path is the materialized path of ids from the root category
+CREATE TABLE IF NOT EXISTS categories (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    parent_id CHAR (36),
+    name VARCHAR (255) NOT NULL,
+    slug VARCHAR (255) NOT NULL,
+    path VARCHAR (512) NOT NULL,
+    depth INT NOT NULL DEFAULT 0,
+    created_at DATETIME (3) NULL,
+    updated_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_categories_tenant_id (tenant_id),
+    INDEX idx_categories_parent_id (parent_id),
+    INDEX idx_categories_path (path),
+    UNIQUE INDEX idx_categories_slug (tenant_id, slug)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Create links of products to categories
+CREATE TABLE IF NOT EXISTS product_categories (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    category_id CHAR (36) NOT NULL,
+    created_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_product_categories_tenant_id (tenant_id),
+    UNIQUE INDEX idx_product_categories_link (product_id, category_id),
+    INDEX idx_product_categories_category_id (category_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Create tags table, names are unique in a tenant
+CREATE TABLE IF NOT EXISTS tags (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    name VARCHAR (64) NOT NULL,
+    created_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_tags_tenant_id (tenant_id),
+    UNIQUE INDEX idx_tags_name (tenant_id, name)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Create links of products to tags
+CREATE TABLE IF NOT EXISTS product_tags (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    tag_id CHAR (36) NOT NULL,
+    created_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_product_tags_tenant_id (tenant_id),
+    UNIQUE INDEX idx_product_tags_link (product_id, tag_id),
+    INDEX idx_product_tags_tag_id (tag_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,7 @@
+-- Delete rating summary and tables
+ALTER TABLE products
+    DROP COLUMN rating_sum,
+    DROP COLUMN review_count,
+    DROP COLUMN rating_avg;
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS reviews;
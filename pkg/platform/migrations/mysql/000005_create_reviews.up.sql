@@ -0,0 +1,34 @@
+-- Create reviews table, one review per user and product
+CREATE TABLE IF NOT EXISTS reviews (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    user_id VARCHAR (64) NOT NULL,
+    score INT NOT NULL,
+    text TEXT,
+    helpful BIGINT NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at DATETIME (3) NULL,
+    updated_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_reviews_tenant_id (tenant_id),
+    UNIQUE INDEX idx_reviews_author (product_id, user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Create helpful votes of reviews, one vote per user and review
+CREATE TABLE IF NOT EXISTS review_votes (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    review_id CHAR (36) NOT NULL,
+    user_id VARCHAR (64) NOT NULL,
+    created_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_review_votes_tenant_id (tenant_id),
+    UNIQUE INDEX idx_review_votes_voter (review_id, user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
+
+-- Add rating summary of reviews to products, the static product_attrs.rating is no longer used
+ALTER TABLE products
+    ADD COLUMN rating_avg DOUBLE NOT NULL DEFAULT 0,
+    ADD COLUMN review_count BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN rating_sum BIGINT NOT NULL DEFAULT 0;
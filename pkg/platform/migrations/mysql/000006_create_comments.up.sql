@@ -0,0 +1,22 @@
+-- Create comments table, replies point to their parent and the top-level comment of the thread
+CREATE TABLE IF NOT EXISTS comments (
+    id CHAR (36) NOT NULL,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    parent_id CHAR (36) NULL,
+    root_id CHAR (36) NOT NULL,
+    user_id VARCHAR (64) NOT NULL,
+    text TEXT,
+    status VARCHAR (16) NOT NULL DEFAULT 'pending',
+    moderated_by VARCHAR (64),
+    moderated_at DATETIME (3) NULL,
+    edited_at DATETIME (3) NULL,
+    deleted_at DATETIME (3) NULL,
+    created_at DATETIME (3) NULL,
+    updated_at DATETIME (3) NULL,
+    PRIMARY KEY (id),
+    INDEX idx_comments_tenant_id (tenant_id),
+    INDEX idx_comments_product_id (product_id),
+    INDEX idx_comments_root_id (root_id),
+    INDEX idx_comments_status (status)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,4 @@
+-- Delete tables
+DROP TABLE IF EXISTS log_records;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,85 @@
+-- Tables are created only if missing, tables of earlier versions (created by GORM AutoMigrate)
+-- are upgraded below and taken over as version 1. Rows without tenant get DEFAULT_TENANT_ID on start.
+
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    username TEXT,
+    password_hash TEXT,
+    user_status BIGINT,
+    user_role TEXT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    delete_at TIMESTAMP WITH TIME ZONE,
+    version BIGINT NOT NULL DEFAULT 1
+);
+
+-- Create products table
+CREATE TABLE IF NOT EXISTS products (
+    id UUID PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    user_id TEXT,
+    title TEXT,
+    author TEXT,
+    product_status BIGINT,
+    product_attrs JSON,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    delete_at TIMESTAMP WITH TIME ZONE,
+    version BIGINT NOT NULL DEFAULT 1
+);
+
+-- Create audit trail table
+CREATE TABLE IF NOT EXISTS log_records (
+    id CHAR (36) PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    user_id TEXT,
+    entity VARCHAR (64),
+    entity_id VARCHAR (64),
+    action VARCHAR (16),
+    changes JSON,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    delete_at TIMESTAMP WITH TIME ZONE,
+    version BIGINT NOT NULL DEFAULT 1
+);
+
+-- Upgrade tables of earlier versions, keys of products and audit records were declared as BIGINT
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'products' AND column_name = 'id') <> 'uuid' THEN
+        ALTER TABLE products ALTER COLUMN id DROP DEFAULT;
+        ALTER TABLE products ALTER COLUMN id TYPE UUID USING id::text::uuid;
+    END IF;
+    IF (SELECT data_type FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'log_records' AND column_name = 'id') <> 'character' THEN
+        ALTER TABLE log_records ALTER COLUMN id DROP DEFAULT;
+        ALTER TABLE log_records ALTER COLUMN id TYPE CHAR (36) USING id::text;
+    END IF;
+END
+$$;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id VARCHAR (64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS tenant_id VARCHAR (64);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS tenant_id VARCHAR (64);
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS entity VARCHAR (64);
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS entity_id VARCHAR (64);
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS action VARCHAR (16);
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS changes JSON;
+ALTER TABLE log_records ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_users_delete_at ON users (delete_at);
+CREATE INDEX IF NOT EXISTS idx_products_tenant_id ON products (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_products_delete_at ON products (delete_at);
+CREATE INDEX IF NOT EXISTS idx_log_records_tenant_id ON log_records (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_log_records_entity ON log_records (entity, entity_id);
+CREATE INDEX IF NOT EXISTS idx_log_records_delete_at ON log_records (delete_at);
+
+-- Add full-text search of products (title, author and description)
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(author, '') || ' ' || coalesce(product_attrs->>'description', ''))
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_products_search ON products USING GIN (search_vector);
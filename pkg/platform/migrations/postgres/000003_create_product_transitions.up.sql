@@ -0,0 +1,16 @@
+-- Create product transitions table, one row per state change of a product
+CREATE TABLE IF NOT EXISTS product_transitions (
+    id CHAR (36) PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    product_id UUID NOT NULL,
+    name VARCHAR (32),
+    from_state INT,
+    to_state INT,
+    user_id TEXT,
+    comment TEXT,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_product_transitions_tenant_id ON product_transitions (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_product_transitions_product_id ON product_transitions (product_id);
@@ -0,0 +1,6 @@
+-- Delete rating summary and tables
+ALTER TABLE products DROP COLUMN IF EXISTS rating_sum;
+ALTER TABLE products DROP COLUMN IF EXISTS review_count;
+ALTER TABLE products DROP COLUMN IF EXISTS rating_avg;
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS reviews;
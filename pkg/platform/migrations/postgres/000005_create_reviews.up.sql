@@ -0,0 +1,33 @@
+-- Create reviews table, one review per user and product
+CREATE TABLE IF NOT EXISTS reviews (
+    id CHAR (36) PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    product_id UUID NOT NULL,
+    user_id VARCHAR (64) NOT NULL,
+    score INT NOT NULL,
+    text TEXT,
+    helpful BIGINT NOT NULL DEFAULT 0,
+    version BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create helpful votes of reviews, one vote per user and review
+CREATE TABLE IF NOT EXISTS review_votes (
+    id CHAR (36) PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    review_id CHAR (36) NOT NULL,
+    user_id VARCHAR (64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Add rating summary of reviews to products, the static product_attrs.rating is no longer used
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_avg DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS review_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_sum BIGINT NOT NULL DEFAULT 0;
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_reviews_tenant_id ON reviews (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_author ON reviews (product_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_review_votes_tenant_id ON review_votes (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_review_votes_voter ON review_votes (review_id, user_id);
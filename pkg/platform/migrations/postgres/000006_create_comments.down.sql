@@ -0,0 +1,2 @@
+-- Delete comments table
+DROP TABLE IF EXISTS comments;
@@ -0,0 +1,52 @@
+-- Create users table
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id VARCHAR (64),
+    username TEXT,
+    password_hash TEXT,
+    user_status INTEGER,
+    user_role TEXT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    delete_at DATETIME,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Create products table, search uses LIKE on SQLite
+CREATE TABLE IF NOT EXISTS products (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    user_id TEXT,
+    title TEXT,
+    author TEXT,
+    product_status INTEGER,
+    product_attrs JSON,
+    created_at DATETIME,
+    updated_at DATETIME,
+    delete_at DATETIME,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Create audit trail table
+CREATE TABLE IF NOT EXISTS log_records (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    user_id TEXT,
+    entity VARCHAR (64),
+    entity_id VARCHAR (64),
+    action VARCHAR (16),
+    changes JSON,
+    created_at DATETIME,
+    updated_at DATETIME,
+    delete_at DATETIME,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_users_tenant_id ON users (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_users_delete_at ON users (delete_at);
+CREATE INDEX IF NOT EXISTS idx_products_tenant_id ON products (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_products_delete_at ON products (delete_at);
+CREATE INDEX IF NOT EXISTS idx_log_records_tenant_id ON log_records (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_log_records_entity ON log_records (entity, entity_id);
+CREATE INDEX IF NOT EXISTS idx_log_records_delete_at ON log_records (delete_at);
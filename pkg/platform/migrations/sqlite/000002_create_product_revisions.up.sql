@@ -0,0 +1,14 @@
+-- Create product revisions table, one snapshot per version of a product
+CREATE TABLE IF NOT EXISTS product_revisions (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    revision INTEGER NOT NULL,
+    user_id TEXT,
+    snapshot JSON,
+    created_at DATETIME
+);
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_product_revisions_tenant_id ON product_revisions (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_revisions_revision ON product_revisions (product_id, revision);
@@ -0,0 +1,52 @@
+-- Create categories table, path is the materialized path of ids from the root category
+CREATE TABLE IF NOT EXISTS categories (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    parent_id CHAR (36),
+    name VARCHAR (255) NOT NULL,
+    slug VARCHAR (255) NOT NULL,
+    path VARCHAR (512) NOT NULL,
+    depth INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+-- Create links of products to categories
+CREATE TABLE IF NOT EXISTS product_categories (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    category_id CHAR (36) NOT NULL,
+    created_at DATETIME
+);
+
+-- Create tags table, names are unique in a tenant
+CREATE TABLE IF NOT EXISTS tags (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    name VARCHAR (64) NOT NULL,
+    created_at DATETIME
+);
+
+-- Create links of products to tags
+CREATE TABLE IF NOT EXISTS product_tags (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    tag_id CHAR (36) NOT NULL,
+    created_at DATETIME
+);
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_categories_tenant_id ON categories (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+CREATE INDEX IF NOT EXISTS idx_categories_path ON categories (path);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (tenant_id, slug);
+CREATE INDEX IF NOT EXISTS idx_product_categories_tenant_id ON product_categories (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_categories_link ON product_categories (product_id, category_id);
+CREATE INDEX IF NOT EXISTS idx_product_categories_category_id ON product_categories (category_id);
+CREATE INDEX IF NOT EXISTS idx_tags_tenant_id ON tags (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (tenant_id, name);
+CREATE INDEX IF NOT EXISTS idx_product_tags_tenant_id ON product_tags (tenant_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_tags_link ON product_tags (product_id, tag_id);
+CREATE INDEX IF NOT EXISTS idx_product_tags_tag_id ON product_tags (tag_id);
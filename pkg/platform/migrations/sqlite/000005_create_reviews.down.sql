@@ -0,0 +1,6 @@
+-- Delete rating summary and tables
+ALTER TABLE products DROP COLUMN rating_sum;
+ALTER TABLE products DROP COLUMN review_count;
+ALTER TABLE products DROP COLUMN rating_avg;
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS reviews;
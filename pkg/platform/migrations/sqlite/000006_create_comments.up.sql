@@ -0,0 +1,23 @@
+-- Create comments table, replies point to their parent and the top-level comment of the thread
+CREATE TABLE IF NOT EXISTS comments (
+    id CHAR (36) NOT NULL PRIMARY KEY,
+    tenant_id VARCHAR (64),
+    product_id CHAR (36) NOT NULL,
+    parent_id CHAR (36),
+    root_id CHAR (36) NOT NULL,
+    user_id VARCHAR (64) NOT NULL,
+    text TEXT,
+    status VARCHAR (16) NOT NULL DEFAULT 'pending',
+    moderated_by VARCHAR (64),
+    moderated_at DATETIME,
+    edited_at DATETIME,
+    deleted_at DATETIME,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+-- Add indexes
+CREATE INDEX IF NOT EXISTS idx_comments_tenant_id ON comments (tenant_id);
+CREATE INDEX IF NOT EXISTS idx_comments_product_id ON comments (product_id);
+CREATE INDEX IF NOT EXISTS idx_comments_root_id ON comments (root_id);
+CREATE INDEX IF NOT EXISTS idx_comments_status ON comments (status);
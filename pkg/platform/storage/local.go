@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local struct to describe a storage in a folder of the local filesystem.
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal func for a storage in dir (temp directory if empty).
+// Objects are served by the API under baseURL, "/api/v1/files" if empty.
+func NewLocal(dir, baseURL string) (*Local, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "tuxiaocao-files")
+	}
+	if baseURL == "" {
+		baseURL = "/api/v1/files"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// file func for the path of the object with given key.
+func (l *Local) file(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes a temporary file first, so readers never see a half-written object.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, contextReader{ctx: ctx, r: r}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	name, err := l.file(key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, Object{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Object{}, err
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return nil, Object{}, ErrNotFound
+	}
+	object := Object{Key: key, ContentType: mime.TypeByExtension(path.Ext(key)), Size: info.Size()}
+	return file, object, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.baseURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+func (l *Local) Ping(ctx context.Context) error {
+	info, err := os.Stat(l.dir)
+	if err == nil && !info.IsDir() {
+		err = errors.New(l.dir + " is not a directory")
+	}
+	return err
+}
+
+// contextReader stops copying, when the context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body, used for GET and DELETE.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config struct to describe the connection to an S3-compatible server (AWS S3, MinIO, ...).
+type S3Config struct {
+	Endpoint  string // e.g. http://localhost:9000, AWS S3 of Region if empty
+	Region    string // us-east-1 if empty
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicURL string // base URL of objects for clients, Endpoint/Bucket if empty
+	Client    *http.Client
+}
+
+// S3 struct to describe a storage in a bucket, objects are addressed path-style (Endpoint/Bucket/Key),
+// which works with AWS S3 and with MinIO without DNS setup. Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+}
+
+// NewS3 func for a storage in the bucket of config.
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("S3_BUCKET is required for storage type s3")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: time.Minute}
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT, %w", err)
+	}
+	if config.PublicURL == "" {
+		config.PublicURL = endpoint.String() + "/" + config.Bucket
+	}
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	return &S3{config: config, endpoint: endpoint}, nil
+}
+
+// request func for a signed request of the object with given key.
+func (s *S3) request(ctx context.Context, method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.config.Bucket + "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	return req, nil
+}
+
+// do func for sending a signed request, status codes of errors are converted to errors.
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, req.Header.Get("X-Amz-Content-Sha256"), time.Now())
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 %s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+}
+
+// Put streams the body, the payload is not hashed (UNSIGNED-PAYLOAD).
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, r, "UNSIGNED-PAYLOAD")
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	object := Object{Key: key, ContentType: resp.Header.Get("Content-Type"), Size: resp.ContentLength}
+	return resp.Body, object, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3) URL(key string) string {
+	return s.config.PublicURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+// Ping func for checking, that the bucket is reachable with the credentials.
+func (s *S3) Ping(ctx context.Context) error {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.config.Bucket
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// sign func for adding the Authorization header of AWS Signature Version 4.
+// All headers of the request are signed, except the ones proxies or the transport may change.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		switch name {
+		case "authorization", "user-agent", "content-length", "accept-encoding":
+			continue
+		}
+		headers[name] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	for _, part := range []string{s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// escapePath func for URI-encoding every segment of the path like S3 expects.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(c)|0x100, 16)[1:]))
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned, if the object with the given key doesn't exist.
+var ErrNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned for empty keys and keys leaving the storage (e.g. "../x").
+var ErrInvalidKey = errors.New("invalid object key")
+
+// Object struct to describe a stored file.
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+}
+
+// Storage interface for storing files by key, e.g. "products/<id>/<name>.jpg".
+type Storage interface {
+	// Put stores the content of r under key, an existing object is replaced.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object, the caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	// Delete removes the object, a missing object is no error.
+	Delete(ctx context.Context, key string) error
+	// URL of the object for clients.
+	URL(key string) string
+	// Ping checks, that the storage is reachable, for readiness.
+	Ping(ctx context.Context) error
+}
+
+// Store is the storage used by the handlers, set by OpenStorage.
+var Store Storage
+
+// OpenStorage func for opening the storage of STORAGE_TYPE (local or s3).
+func OpenStorage() (s Storage, err error) {
+	storageType := os.Getenv("STORAGE_TYPE")
+	switch storageType {
+	case "", "local":
+		s, err = NewLocal(os.Getenv("STORAGE_DIR"), os.Getenv("STORAGE_PUBLIC_URL"))
+	case "s3":
+		s, err = NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("STORAGE_PUBLIC_URL"),
+		})
+	default:
+		err = fmt.Errorf("storage type '%v' is not supported, use local or s3", storageType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	Store = s
+	return s, nil
+}
+
+// CleanKey func for checking a key, it returns the key without leading slash.
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(key, "/")
+	if key == "" || strings.Contains(key, "\\") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
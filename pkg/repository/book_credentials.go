@@ -9,4 +9,39 @@ const (
 
 	// ProductCreateCredential const for delete product.
 	ProductDeleteCredential string = "product:delete"
+
+	// ProductApproveCredential const for approve or reject products in review.
+	ProductApproveCredential string = "product:approve"
+
+	// ProductRestoreCredential const for restore deleted products and browse the trash.
+	ProductRestoreCredential string = "product:restore"
+
+	// CategoryManageCredential const for create, update and delete categories.
+	CategoryManageCredential string = "category:manage"
+
+	// ReviewModerateCredential const for remove reviews of other users.
+	ReviewModerateCredential string = "review:moderate"
+
+	// CommentModerateCredential const for browse the moderation queue, hide, approve and delete comments of other users.
+	CommentModerateCredential string = "comment:moderate"
+
+	// AuditReadCredential const for browse the audit trail.
+	AuditReadCredential string = "audit:read"
+
+	// TenantAllCredential const for opting out of the tenant scope (super-admin only).
+	TenantAllCredential string = "tenant:all"
 )
+
+// Credentials var for all credentials, which are set in the access token.
+var Credentials = []string{
+	ProductCreateCredential,
+	ProductUpdateCredential,
+	ProductDeleteCredential,
+	ProductApproveCredential,
+	ProductRestoreCredential,
+	CategoryManageCredential,
+	ReviewModerateCredential,
+	CommentModerateCredential,
+	AuditReadCredential,
+	TenantAllCredential,
+}
@@ -9,4 +9,7 @@ const (
 
 	// UserRoleName const for user role.
 	UserRoleName string = "user"
+
+	// SuperAdminRoleName const for super-admin role, which may access all tenants.
+	SuperAdminRoleName string = "superadmin"
 )
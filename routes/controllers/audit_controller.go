@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"time"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetAuditRecords func gets the audit trail of an entity.
+// @Description Get create, update and delete records of an entity with the acting user and changed fields, newest first.
+// @Summary get audit trail of an entity
+// @Tags Audit
+// @Accept json
+// @Produce json
+// @Param entity query string true "Entity type, e.g. product or user"
+// @Param id query string false "Entity ID (all entities of the type, if empty)"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.LogRecord
+// @Security ApiKeyAuth
+// @Router /v1/audit [get]
+func GetAuditRecords(c *fiber.Ctx) error {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > claims.Expires {
+		// Return status 401 and unauthorized error message.
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+
+	// Only user with `audit:read` credential can browse the audit trail.
+	if !claims.Credentials[repository.AuditReadCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+
+	// Entity type is required.
+	entity := c.Query("entity")
+	if entity == "" {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "entity is required, e.g. entity=product",
+		})
+	}
+
+	// Parse pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get audit records.
+	records, total, err := models.AuditTrail(c.UserContext(), entity, c.Query("id"), query.PageNo, query.PageSize)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"count":   total,
+		"records": records,
+	})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"strconv"
 	"time"
 	"tuxiaocao/pkg/platform/cache"
 	"tuxiaocao/routes/models"
@@ -77,7 +78,12 @@ func UserSignUp(c *fiber.Ctx) error {
 	}
 
 	// Create a new user with validated data.
-	if err := models.NewUserRepo().Create(user); err != nil {
+	if err := userStore.Create(c.UserContext(), user); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
 		// Return status 500 and create user process error.
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": true,
@@ -120,9 +126,13 @@ func UserSignIn(c *fiber.Ctx) error {
 	}
 
 	// Get user by username.
-	foundedUser := &models.User{}
-	err := models.NewUserRepo().Where("username = ?", signIn.Username).Scan(foundedUser)
+	foundedUser, err := userStore.GetByUsername(c.UserContext(), signIn.Username)
 	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
 		// Return, if user not found.
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": true,
@@ -151,7 +161,7 @@ func UserSignIn(c *fiber.Ctx) error {
 	}
 
 	// Generate a new pair of access and refresh tokens.
-	tokens, err := utils2.GenerateNewTokens(string(foundedUser.ID), credentials)
+	tokens, err := utils2.GenerateNewTokens(strconv.Itoa(foundedUser.ID), foundedUser.TenantID, credentials)
 	if err != nil {
 		// Return status 500 and token generation error.
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -161,7 +171,7 @@ func UserSignIn(c *fiber.Ctx) error {
 	}
 
 	// Define user ID.
-	userID := string(foundedUser.ID)
+	userID := strconv.Itoa(foundedUser.ID)
 
 	// Create a new Redis connection.
 	connRedis, err := cache.RedisConnection()
@@ -0,0 +1,410 @@
+package controllers
+
+import (
+	"errors"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Maximal and default number of tags for autocomplete.
+const (
+	defaultTagLimit = 10
+	maxTagLimit     = 50
+)
+
+// categoryError func for answering errors of the category store.
+func categoryError(c *fiber.Ctx, err error) error {
+	// Return status 504/503, if query was aborted.
+	if aborted, err := abortedQuery(c, err); aborted {
+		return err
+	}
+
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Return status 404, if category with given ID or slug is not found.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "category with this ID or slug not found",
+		})
+	case errors.Is(err, models.ErrCategoryNotFound), errors.Is(err, models.ErrCategoryTooDeep), errors.Is(err, models.ErrInvalidTag):
+		// Return status 400, if parent or linked categories are unknown or tags are invalid.
+		status = fiber.StatusBadRequest
+	case errors.Is(err, models.ErrSlugTaken), errors.Is(err, models.ErrCategoryCycle), errors.Is(err, models.ErrCategoryNotEmpty):
+		// Return status 409, if the change conflicts with the category tree.
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// canManageCategories func for checking, that the token has the `category:manage` credential.
+func canManageCategories(c *fiber.Ctx) (bool, error) {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return false, err
+	}
+
+	// Only user with `category:manage` credential can change categories.
+	if !claims.Credentials[repository.CategoryManageCredential] {
+		// Return status 403 and permission denied error message.
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+	return true, nil
+}
+
+// categoryBody func for parsing and validating the body of a created or updated category.
+func categoryBody(c *fiber.Ctx) (*queries.CategoryBody, bool, error) {
+	body := &queries.CategoryBody{}
+
+	// Check, if received JSON data is valid.
+	if err := c.BodyParser(body); err != nil {
+		// Return status 400 and error message.
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Validate category fields.
+	if err := utils2.NewValidator().Struct(body); err != nil {
+		// Return, if some fields are not valid.
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils2.ValidatorErrors(err),
+		})
+	}
+	return body, true, nil
+}
+
+// Getcategories func gets the category tree.
+// @Description Get all categories as tree, children are ordered by path.
+// @Summary get category tree
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.CategoryNode
+// @Router /v1/categories [get]
+func Getcategories(c *fiber.Ctx) error {
+	// Get all categories.
+	categories, err := categoryStore.Categories(c.UserContext())
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":      false,
+		"msg":        nil,
+		"count":      len(categories),
+		"categories": models.CategoryTree(categories),
+	})
+}
+
+// Getcategory func gets one category by ID or slug.
+// @Description Get one category by ID or slug with its ancestors, root first.
+// @Summary get category by ID or slug
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID or slug"
+// @Success 200 {object} models.Category
+// @Router /v1/category/{id} [get]
+func Getcategory(c *fiber.Ctx) error {
+	// Get category by ID or slug.
+	category, err := categoryStore.Category(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Get ancestors from the path of the category.
+	ancestors := []models.Category{}
+	for _, id := range category.Ancestors() {
+		ancestor, err := categoryStore.Category(c.UserContext(), id.String())
+		if err != nil {
+			return categoryError(c, err)
+		}
+		ancestors = append(ancestors, ancestor)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"category":  category,
+		"ancestors": ancestors,
+	})
+}
+
+// Createcategory func for creates a new category.
+// @Description Create a new category, below the parent category, if given. The slug is derived from the name, if empty.
+// @Summary create a new category
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param category body queries.CategoryBody true "Category"
+// @Success 201 {object} models.Category
+// @Failure 409 {string} status "slug is already used"
+// @Security ApiKeyAuth
+// @Router /v1/category [post]
+func Createcategory(c *fiber.Ctx) error {
+	if ok, err := canManageCategories(c); !ok {
+		return err
+	}
+	body, ok, err := categoryBody(c)
+	if !ok {
+		return err
+	}
+
+	// Create category by given body.
+	category := &models.Category{
+		ID:       uuid.New(),
+		TenantID: models.TenantFrom(c.UserContext()),
+		ParentID: body.ParentID,
+		Name:     body.Name,
+		Slug:     body.Slug,
+	}
+	if err := categoryStore.CreateCategory(c.UserContext(), category); err != nil {
+		return categoryError(c, err)
+	}
+
+	// Return status 201 Created.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"category": category,
+	})
+}
+
+// Updatecategory func for renames or moves a category.
+// @Description Update name and slug of a category. A changed parent moves the category with all its descendants,
+// @Description a category can't be moved below itself.
+// @Summary update category
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID or slug"
+// @Param category body queries.CategoryBody true "Category"
+// @Success 200 {object} models.Category
+// @Failure 409 {string} status "slug is already used or category can't be moved below itself"
+// @Security ApiKeyAuth
+// @Router /v1/category/{id} [put]
+func Updatecategory(c *fiber.Ctx) error {
+	if ok, err := canManageCategories(c); !ok {
+		return err
+	}
+	body, ok, err := categoryBody(c)
+	if !ok {
+		return err
+	}
+
+	// Checking, if category with given ID or slug is exists.
+	current, err := categoryStore.Category(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Update category, the store moves the subtree.
+	category := &models.Category{ID: current.ID, ParentID: body.ParentID, Name: body.Name, Slug: body.Slug}
+	if err := categoryStore.UpdateCategory(c.UserContext(), category); err != nil {
+		return categoryError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"category": category,
+	})
+}
+
+// Deletecategory func for deletes a category.
+// @Description Delete a category without subcategories, products are unlinked from it.
+// @Summary delete category
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param id path string true "Category ID or slug"
+// @Success 204 {string} status "ok"
+// @Failure 409 {string} status "category has subcategories"
+// @Security ApiKeyAuth
+// @Router /v1/category/{id} [delete]
+func Deletecategory(c *fiber.Ctx) error {
+	if ok, err := canManageCategories(c); !ok {
+		return err
+	}
+
+	// Checking, if category with given ID or slug is exists.
+	category, err := categoryStore.Category(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Delete category.
+	if err := categoryStore.DeleteCategory(c.UserContext(), category.ID); err != nil {
+		return categoryError(c, err)
+	}
+
+	// Return status 204 no content.
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Gettags func gets tags for autocomplete.
+// @Description Get tags starting with the given prefix, ordered by name.
+// @Summary autocomplete tags
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param q query string false "Prefix of the tag"
+// @Param limit query integer false "Maximal number of tags (default 10, at most 50)"
+// @Success 200 {array} models.Tag
+// @Router /v1/tags [get]
+func Gettags(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultTagLimit)
+	if limit < 1 || limit > maxTagLimit {
+		limit = defaultTagLimit
+	}
+
+	// Get tags with given prefix.
+	tags, err := categoryStore.Tags(c.UserContext(), c.Query("q"), limit)
+	if err != nil {
+		return categoryError(c, err)
+	}
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"tags":  names,
+	})
+}
+
+// canEditProduct func for checking, that the token is of the creator or has the `product:update` credential.
+func canEditProduct(c *fiber.Ctx) (models.Product, bool, error) {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return models.Product{}, false, err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return product, false, err
+	}
+
+	// Only the creator or holders of `product:update` credential can change categories and tags of a product.
+	if product.UserID != claims.UserID && !claims.Credentials[repository.ProductUpdateCredential] {
+		// Return status 403 and permission denied error message.
+		return product, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, only the creator or holders of product:update can update this product",
+		})
+	}
+	return product, true, nil
+}
+
+// Setproductcategories func for replaces the categories of a product.
+// @Description Replace the categories of a product, an empty list removes the product from all categories.
+// @Summary set categories of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param categories body queries.ProductCategories true "Category IDs"
+// @Success 200 {array} models.Category
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/categories [put]
+func Setproductcategories(c *fiber.Ctx) error {
+	product, ok, err := canEditProduct(c)
+	if !ok {
+		return err
+	}
+
+	// Check, if received JSON data is valid.
+	body := &queries.ProductCategories{}
+	if err := c.BodyParser(body); err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	if err := utils2.NewValidator().Struct(body); err != nil {
+		// Return, if some fields are not valid.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils2.ValidatorErrors(err),
+		})
+	}
+
+	// Replace categories of the product.
+	if err := categoryStore.SetProductCategories(c.UserContext(), product.ID, body.CategoryIDs); err != nil {
+		return categoryError(c, err)
+	}
+	categories, err := categoryStore.ProductCategories(c.UserContext(), product.ID)
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":      false,
+		"msg":        nil,
+		"categories": categories,
+	})
+}
+
+// Setproducttags func for replaces the tags of a product.
+// @Description Replace the tags of a product. Tags are trimmed and lower-cased, unknown tags are created.
+// @Summary set tags of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param tags body queries.ProductTags true "Tags"
+// @Success 200 {array} string
+// @Failure 400 {string} status "tags are empty, too long or contain commas"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/tags [put]
+func Setproducttags(c *fiber.Ctx) error {
+	product, ok, err := canEditProduct(c)
+	if !ok {
+		return err
+	}
+
+	// Check, if received JSON data is valid.
+	body := &queries.ProductTags{}
+	if err := c.BodyParser(body); err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Replace tags of the product.
+	tags, err := categoryStore.SetProductTags(c.UserContext(), product.ID, body.Tags)
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"tags":  tags,
+	})
+}
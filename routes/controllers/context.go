@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+	"tuxiaocao/routes/models"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// abortedQuery func for answering cancelled or timed-out database queries.
+// Returns false, if the given error is not caused by the request context.
+func abortedQuery(c *fiber.Ctx, err error) (bool, error) {
+	if !models.IsContextError(err) {
+		return false, nil
+	}
+
+	// Return status 504, if query took longer than the request deadline.
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true, c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{
+			"error": true,
+			"msg":   "query timeout, the database did not answer in time",
+		})
+	}
+
+	// Return status 503, if request was cancelled by the server shutdown.
+	return true, c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"error": true,
+		"msg":   "request was cancelled, the server is shutting down",
+	})
+}
+
+// validClaims func for checking, that the JWT is valid and not expired.
+func validClaims(c *fiber.Ctx) (*utils2.TokenMetadata, bool, error) {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return nil, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > claims.Expires {
+		// Return status 401 and unauthorized error message.
+		return nil, false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+	return claims, true, nil
+}
+
+// productOfParam func for getting the product of the ID in URL.
+func productOfParam(c *fiber.Ctx) (models.Product, bool, error) {
+	// Catch product ID from URL.
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		// Return status 400 and error message.
+		return models.Product{}, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if product with given ID is exists.
+	product, err := productStore.Get(c.UserContext(), id)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return product, false, err
+		}
+
+		// Return status 404 and product not found error.
+		return product, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "product with this ID not found",
+		})
+	}
+	return product, true, nil
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// etag func for building a strong ETag from the row version.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ifMatchVersion func for reading the expected row version from If-Match header.
+// Returns false, if the header is not given; "*" matches any version (0).
+func ifMatchVersion(c *fiber.Ctx) (int64, bool, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return 0, false, nil
+	}
+	if header == "*" {
+		return 0, true, nil
+	}
+
+	// Accept weak and unquoted ETags as well.
+	header = strings.TrimPrefix(header, "W/")
+	if unquoted, err := strconv.Unquote(header); err == nil {
+		header = unquoted
+	}
+	version, err := strconv.ParseInt(header, 10, 64)
+	if err != nil {
+		return 0, true, err
+	}
+	return version, true, nil
+}
+
+// ifMatchRequired func for checking PRODUCT_REQUIRE_IF_MATCH from .env file (enabled by default).
+func ifMatchRequired() bool {
+	required, err := strconv.ParseBool(os.Getenv("PRODUCT_REQUIRE_IF_MATCH"))
+	return err != nil || required
+}
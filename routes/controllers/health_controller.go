@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"tuxiaocao/pkg/platform/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Healthz func for liveness of the process, it doesn't check dependencies.
+// @Description Liveness probe, answers as long as the process serves requests.
+// @Summary liveness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /healthz [get]
+func Healthz(c *fiber.Ctx) error {
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"status": health.StatusOK,
+	})
+}
+
+// Readyz func for readiness of the service, it checks database, Redis, Kafka and etcd.
+// @Description Readiness probe with status and latency of every dependency. Fails, if a critical dependency is down or the server is shutting down.
+// @Summary readiness probe
+// @Tags health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /readyz [get]
+func Readyz(c *fiber.Ctx) error {
+	report := health.Ready(c.UserContext())
+	if !report.Ready() {
+		// Return status 503 and the failed checks.
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":  true,
+			"msg":    "service is not ready",
+			"status": report.Status,
+			"checks": report.Checks,
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"status": report.Status,
+		"checks": report.Checks,
+	})
+}
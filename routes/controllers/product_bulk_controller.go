@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Bulkproducts func for creates, upserts or deletes many products at once.
+// @Description Create, upsert (by ID) or delete many products. Every item is validated on its own, the response contains a result per item.
+// @Summary bulk create, upsert or delete products
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param action body string true "Action: create, upsert or delete"
+// @Param items body []models.Product false "Products to create or upsert"
+// @Param ids body []string false "Product IDs to delete"
+// @Success 200 {array} queries.BulkResult
+// @Security ApiKeyAuth
+// @Router /v1/products/bulk [post]
+func Bulkproducts(c *fiber.Ctx) error {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > claims.Expires {
+		// Return status 401 and unauthorized error message.
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+
+	// Create new bulk struct.
+	bulk := &queries.ProductBulk{}
+
+	// Check, if received JSON data is valid.
+	if err := c.BodyParser(bulk); err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Create a new validator for a Product model.
+	validate := utils2.NewValidator()
+
+	// Validate bulk fields.
+	if err := validate.Struct(bulk); err != nil {
+		// Return, if some fields are not valid.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils2.ValidatorErrors(err),
+		})
+	}
+
+	// Set credential from JWT data: `product:delete` for delete, `product:create` otherwise.
+	credential := repository.ProductCreateCredential
+	if bulk.Action == "delete" {
+		credential = repository.ProductDeleteCredential
+	}
+	if !claims.Credentials[credential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+
+	// Run bulk action.
+	var results []*queries.BulkResult
+	if bulk.Action == "delete" {
+		results, err = bulkDeleteProducts(c, claims.UserID, bulk.IDs)
+	} else {
+		results, err = bulkSaveProducts(c, claims.UserID, bulk.Action, bulk.Items)
+	}
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Count failed items.
+	failed := 0
+	for _, result := range results {
+		if result.Error {
+			failed++
+		}
+	}
+
+	// Return status 200 OK and result per item.
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// bulkSaveProducts func for validating and inserting (or upserting) products in chunks.
+func bulkSaveProducts(c *fiber.Ctx, userID, action string, items []models.Product) ([]*queries.BulkResult, error) {
+	results := make([]*queries.BulkResult, len(items))
+	validate := utils2.NewValidator()
+
+	// Products with given IDs must belong to the current user on upsert.
+	owners := map[uuid.UUID]string{}
+	if action == "upsert" {
+		var ids []uuid.UUID
+		for _, item := range items {
+			if item.ID != uuid.Nil {
+				ids = append(ids, item.ID)
+			}
+		}
+		var err error
+		if owners, err = productOwners(c.UserContext(), ids, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate every item on its own.
+	var valid []*models.Product
+	var indexes []int
+	for i := range items {
+		product := &items[i]
+		results[i] = &queries.BulkResult{Index: i}
+
+		// Set initialized default data for product:
+		if product.ID == uuid.Nil {
+			product.ID = uuid.New()
+		}
+		product.UserID = userID
+		product.ProductStatus = models.StateDraft // state only changes through transitions
+		results[i].ID = product.ID.String()
+
+		if owner, ok := owners[product.ID]; ok && owner != userID {
+			results[i].Error = true
+			results[i].Msg = "permission denied, only the creator can update his product"
+			continue
+		}
+		if err := validate.Struct(product); err != nil {
+			results[i].Error = true
+			results[i].Msg = utils2.ValidatorErrors(err)
+			continue
+		}
+		valid = append(valid, product)
+		indexes = append(indexes, i)
+	}
+
+	// Insert valid items in chunks.
+	var errs []error
+	if action == "upsert" {
+		errs = productStore.UpsertBatch(c.UserContext(), valid, []string{"title", "author", "product_attrs", "updated_at"}, bulkChunkSize())
+	} else {
+		errs = productStore.CreateBatch(c.UserContext(), valid, bulkChunkSize())
+	}
+	for i, err := range errs {
+		if models.IsContextError(err) {
+			return nil, err
+		}
+		if err != nil {
+			results[indexes[i]].Error = true
+			results[indexes[i]].Msg = err.Error()
+		}
+	}
+	return results, nil
+}
+
+// bulkDeleteProducts func for deleting products of the current user in chunks.
+func bulkDeleteProducts(c *fiber.Ctx, userID string, ids []uuid.UUID) ([]*queries.BulkResult, error) {
+	results := make([]*queries.BulkResult, len(ids))
+
+	// Only the creator can delete his products.
+	owners, err := productOwners(c.UserContext(), ids, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleting []uuid.UUID
+	var indexes []int
+	for i, id := range ids {
+		results[i] = &queries.BulkResult{Index: i, ID: id.String()}
+		if owner, ok := owners[id]; ok && owner != userID {
+			results[i].Error = true
+			results[i].Msg = "permission denied, only the creator can delete his product"
+			continue
+		}
+		deleting = append(deleting, id)
+		indexes = append(indexes, i)
+	}
+
+	// Delete owned products in chunks.
+	errs := productStore.DeleteByIDs(c.UserContext(), deleting, bulkChunkSize())
+	for i, err := range errs {
+		if models.IsContextError(err) {
+			return nil, err
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			results[indexes[i]].Error = true
+			results[indexes[i]].Msg = "product with this ID not found"
+		} else if err != nil {
+			results[indexes[i]].Error = true
+			results[indexes[i]].Msg = err.Error()
+		}
+	}
+	return results, nil
+}
+
+// bulkChunkSize func for getting BULK_CHUNK_SIZE from .env file (500 by default).
+func bulkChunkSize() int {
+	size, err := strconv.Atoi(os.Getenv("BULK_CHUNK_SIZE"))
+	if err != nil || size <= 0 {
+		return 500
+	}
+	return size
+}
@@ -0,0 +1,412 @@
+package controllers
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// commentEditWindow func for getting COMMENT_EDIT_WINDOW_MINUTES from .env file (15 minutes by default).
+func commentEditWindow() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("COMMENT_EDIT_WINDOW_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// commentError func for answering errors of the comment store.
+func commentError(c *fiber.Ctx, err error) error {
+	// Return status 504/503, if query was aborted.
+	if aborted, err := abortedQuery(c, err); aborted {
+		return err
+	}
+
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Return status 404, if the comment is not found.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "comment not found",
+		})
+	case errors.Is(err, models.ErrParentNotFound), errors.Is(err, models.ErrInvalidCommentStatus):
+		// Return status 400, if the replied comment is not on the product.
+		status = fiber.StatusBadRequest
+	case errors.Is(err, models.ErrNotCommentAuthor), errors.Is(err, models.ErrEditWindowClosed):
+		// Return status 403, if the user is not the author or the edit window has passed.
+		status = fiber.StatusForbidden
+	case errors.Is(err, models.ErrCommentRemoved):
+		// Return status 409, if the comment is deleted or hidden.
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// commentBody func for parsing and validating the body of a created or edited comment.
+func commentBody(c *fiber.Ctx) (*queries.CommentBody, bool, error) {
+	body := &queries.CommentBody{}
+
+	// Check, if received JSON data is valid.
+	if err := c.BodyParser(body); err != nil {
+		// Return status 400 and error message.
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Validate comment fields.
+	if err := utils2.NewValidator().Struct(body); err != nil {
+		// Return, if some fields are not valid.
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils2.ValidatorErrors(err),
+		})
+	}
+	return body, true, nil
+}
+
+// commentIDOfParam func for getting the comment ID in URL.
+func commentIDOfParam(c *fiber.Ctx) (uuid.UUID, bool, error) {
+	// Catch comment ID from URL.
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		// Return status 400 and error message.
+		return id, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	return id, true, nil
+}
+
+// moderatorClaims func for getting the claims of holders of `comment:moderate` credential.
+func moderatorClaims(c *fiber.Ctx) (*utils2.TokenMetadata, bool, error) {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return nil, false, err
+	}
+
+	// Only holders of `comment:moderate` credential can moderate comments.
+	if !claims.Credentials[repository.CommentModerateCredential] {
+		// Return status 403 and permission denied error message.
+		return nil, false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+	return claims, true, nil
+}
+
+// Getproductcomments func gets the comment threads of a product.
+// @Description Get top-level comments of a product, newest first, each with a preview of its first replies.
+// @Description Deleted and hidden comments are kept as `[deleted]` and `[hidden]` placeholders.
+// @Summary get comment threads of a product
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.CommentThread
+// @Router /v1/product/{id}/comments [get]
+func Getproductcomments(c *fiber.Ctx) error {
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Parse pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get threads of the product.
+	threads, total, err := commentStore.Threads(c.UserContext(), product.ID, query.PageNo, query.PageSize)
+	if err != nil {
+		return commentError(c, err)
+	}
+	for i := range threads {
+		threads[i].Comment = threads[i].Comment.Redacted()
+		threads[i].Replies = models.RedactComments(threads[i].Replies)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"count":    total,
+		"comments": threads,
+	})
+}
+
+// Getcommentreplies func gets all replies of the thread of a comment.
+// @Description Get replies of the thread a comment belongs to, oldest first.
+// @Summary get replies of a comment thread
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.Comment
+// @Router /v1/comment/{id}/replies [get]
+func Getcommentreplies(c *fiber.Ctx) error {
+	id, ok, err := commentIDOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Parse pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get the thread of the comment and its replies.
+	comment, err := commentStore.Comment(c.UserContext(), id)
+	if err != nil {
+		return commentError(c, err)
+	}
+	replies, total, err := commentStore.Replies(c.UserContext(), comment.RootID, query.PageNo, query.PageSize)
+	if err != nil {
+		return commentError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"count":   total,
+		"thread":  comment.RootID,
+		"replies": models.RedactComments(replies),
+	})
+}
+
+// Createproductcomment func for comments on a product or replies to a comment.
+// @Description Comment on a product, or reply to a comment of the product with `parent_id`.
+// @Description New comments are visible at once and wait in the moderation queue.
+// @Summary comment on a product
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param comment body queries.CommentBody true "Text and replied comment"
+// @Success 201 {object} models.Comment
+// @Failure 409 {string} status "replied comment is deleted or hidden"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/comments [post]
+func Createproductcomment(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+	body, ok, err := commentBody(c)
+	if !ok {
+		return err
+	}
+
+	// Create comment or reply.
+	comment := &models.Comment{ID: uuid.New(), ProductID: product.ID, ParentID: body.ParentID, UserID: claims.UserID, Text: body.Text}
+	if err := commentStore.CreateComment(c.UserContext(), comment); err != nil {
+		return commentError(c, err)
+	}
+
+	// Return status 201.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"comment": comment,
+	})
+}
+
+// Updatecomment func for edits the own comment.
+// @Description Edit the text of the own comment within COMMENT_EDIT_WINDOW_MINUTES after creation, `parent_id` is ignored.
+// @Description Edited comments wait in the moderation queue again.
+// @Summary edit own comment
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Param comment body queries.CommentBody true "New text"
+// @Success 200 {object} models.Comment
+// @Failure 403 {string} status "edit window of the comment has passed"
+// @Security ApiKeyAuth
+// @Router /v1/comment/{id} [put]
+func Updatecomment(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	id, ok, err := commentIDOfParam(c)
+	if !ok {
+		return err
+	}
+	body, ok, err := commentBody(c)
+	if !ok {
+		return err
+	}
+
+	// Edit comment of the user.
+	comment, err := commentStore.UpdateComment(c.UserContext(), id, claims.UserID, body.Text, commentEditWindow())
+	if err != nil {
+		return commentError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"comment": comment,
+	})
+}
+
+// Deletecomment func for deletes a comment.
+// @Description Delete the own comment, holders of `comment:moderate` can delete comments of other users.
+// @Description Replies are kept, the comment is shown as `[deleted]` placeholder.
+// @Summary delete comment
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Success 204 {string} status "ok"
+// @Security ApiKeyAuth
+// @Router /v1/comment/{id} [delete]
+func Deletecomment(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	id, ok, err := commentIDOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Soft delete comment.
+	moderator := claims.Credentials[repository.CommentModerateCredential]
+	if err := commentStore.DeleteComment(c.UserContext(), id, claims.UserID, moderator); err != nil {
+		return commentError(c, err)
+	}
+
+	// Return status 204 no content.
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Getcommentmoderation func gets the moderation queue of comments.
+// @Description Get new and edited comments waiting for moderation, oldest first.
+// @Summary get moderation queue of comments
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param product_id query string false "Product ID"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.Comment
+// @Security ApiKeyAuth
+// @Router /v1/comments/moderation [get]
+func Getcommentmoderation(c *fiber.Ctx) error {
+	if _, ok, err := moderatorClaims(c); !ok {
+		return err
+	}
+
+	// Parse pagination and product from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+	productID := uuid.Nil
+	if value := c.Query("product_id"); value != "" {
+		if productID, err = uuid.Parse(value); err != nil {
+			// Return status 400 and error message.
+			return invalidQuery(c, &models.QueryError{Param: "product_id", Field: value, Reason: "is not a valid ID"})
+		}
+	}
+
+	// Get comments waiting for moderation.
+	comments, total, err := commentStore.ModerationQueue(c.UserContext(), productID, query.PageNo, query.PageSize)
+	if err != nil {
+		return commentError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"count":    total,
+		"comments": comments,
+	})
+}
+
+// Approvecomment func for approves a comment in the moderation queue.
+// @Description Approve a pending or hidden comment, holders of `comment:moderate` only.
+// @Summary approve comment
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Success 200 {object} models.Comment
+// @Security ApiKeyAuth
+// @Router /v1/comment/{id}/approve [post]
+func Approvecomment(c *fiber.Ctx) error {
+	return moderateComment(c, models.CommentApproved)
+}
+
+// Hidecomment func for hides a comment.
+// @Description Hide a comment, it is shown as `[hidden]` placeholder and can't be replied to, holders of `comment:moderate` only.
+// @Summary hide comment
+// @Tags Comment
+// @Accept json
+// @Produce json
+// @Param id path string true "Comment ID"
+// @Success 200 {object} models.Comment
+// @Security ApiKeyAuth
+// @Router /v1/comment/{id}/hide [post]
+func Hidecomment(c *fiber.Ctx) error {
+	return moderateComment(c, models.CommentHidden)
+}
+
+// moderateComment func for setting the moderation status of a comment.
+func moderateComment(c *fiber.Ctx, status models.CommentStatus) error {
+	claims, ok, err := moderatorClaims(c)
+	if !ok {
+		return err
+	}
+	id, ok, err := commentIDOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Set status of the comment.
+	comment, err := commentStore.Moderate(c.UserContext(), id, status, claims.UserID)
+	if err != nil {
+		return commentError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"comment": comment,
+	})
+}
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"time"
 	"tuxiaocao/routes/models"
 	utils2 "tuxiaocao/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"tuxiaocao/pkg/repository"
 )
 
@@ -16,12 +18,54 @@ import (
 // @Tags products
 // @Accept json
 // @Produce json
+// @Param filter[field][op] query string false "Filter, e.g. filter[title][like]=x or filter[product_status][in]=draft,in_review"
+// @Param category query string false "Category ID or slug, products of subcategories included"
+// @Param tag query []string false "Tags, products must have all of them" collectionFormat(multi)
+// @Param sort query string false "Sorting, e.g. -created_at,title"
+// @Param cursor query string false "Cursor for keyset pagination (empty for the first page)"
+// @Param limit query integer false "Page size for keyset pagination"
+// @Param page_no query integer false "Page number for offset pagination"
+// @Param page_size query integer false "Page size for offset pagination (all products, if not given)"
 // @Success 200 {array} models.Product
 // @Router /v1/products [get]
 func Getproducts(c *fiber.Ctx) error {
+	// Parse filters, sorting and pagination from query string.
+	query, err := listQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Restrict products to category and tags.
+	if err := taxonomyFilter(c, query); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and error message.
+		return invalidQuery(c, err)
+	}
+
+	// Use keyset pagination, if cursor is given (even empty).
+	if c.Context().QueryArgs().Has("cursor") {
+		return getproductsByCursor(c, query)
+	}
+
 	// Get all products.
-	products, total, err := models.NewProductRepo().List(nil)
+	products, total, err := productStore.List(c.UserContext(), query)
 	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and allowed fields.
+		var queryErr *models.QueryError
+		if errors.As(err, &queryErr) {
+			return invalidQuery(c, err)
+		}
+
 		// Return, if products not found.
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error":    true,
@@ -40,6 +84,55 @@ func Getproducts(c *fiber.Ctx) error {
 	})
 }
 
+// getproductsByCursor func gets one page of products with keyset pagination.
+func getproductsByCursor(c *fiber.Ctx, query *models.Query) error {
+	// Newest products first, if no sorting is given.
+	if len(query.Sorting) == 0 {
+		query.Sorting = []*models.SortParam{{SortBy: "created_at", Descending: true}}
+	}
+
+	// Get one page of products.
+	products, page, err := productStore.ListByCursor(c.UserContext(), query, c.Query("cursor"), c.QueryInt("limit"))
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400, if cursor is broken or belongs to another sorting.
+		if errors.Is(err, models.ErrInvalidCursor) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": true,
+				"msg":   err.Error(),
+			})
+		}
+
+		// Return status 400 and allowed fields.
+		var queryErr *models.QueryError
+		if errors.As(err, &queryErr) {
+			return invalidQuery(c, err)
+		}
+
+		// Return, if products not found.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":    true,
+			"msg":      "products were not found",
+			"count":    0,
+			"products": nil,
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":       false,
+		"msg":         nil,
+		"count":       len(products),
+		"products":    products,
+		"next_cursor": page.NextCursor,
+		"prev_cursor": page.PrevCursor,
+	})
+}
+
 // Getproduct func gets product by given ID or 404 error.
 // @Description Get product by given ID.
 // @Summary get product by given ID
@@ -60,9 +153,13 @@ func Getproduct(c *fiber.Ctx) error {
 	}
 
 	// Get product by ID.
-	var product models.Product
-	err = models.NewProductRepo().Where("id = ?", id).Scan(product)
+	product, err := productStore.Get(c.UserContext(), id)
 	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
 		// Return, if product not found.
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error":   true,
@@ -71,11 +168,26 @@ func Getproduct(c *fiber.Ctx) error {
 		})
 	}
 
+	// Get categories and tags of the product.
+	categories, err := categoryStore.ProductCategories(c.UserContext(), product.ID)
+	if err != nil {
+		return categoryError(c, err)
+	}
+	tags, err := categoryStore.ProductTags(c.UserContext(), product.ID)
+	if err != nil {
+		return categoryError(c, err)
+	}
+
+	// Set ETag for conditional updates.
+	c.Set(fiber.HeaderETag, etag(product.Version))
+
 	// Return status 200 OK.
 	return c.JSON(fiber.Map{
-		"error":   false,
-		"msg":     nil,
-		"product": product,
+		"error":      false,
+		"msg":        nil,
+		"product":    product,
+		"categories": categories,
+		"tags":       tags,
 	})
 }
 
@@ -130,23 +242,29 @@ func Createproduct(c *fiber.Ctx) error {
 	//	})
 	//}
 
-	// Create new Product struct
-	product := &models.Product{}
+	// Create new productUpdate struct
+	body := &productUpdate{}
 
 	// Check, if received JSON data is valid.
-	if err := c.BodyParser(product); err != nil {
+	if err := c.BodyParser(body); err != nil {
 		// Return status 400 and error message.
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": true,
 			"msg":   err.Error(),
 		})
 	}
+
+	// Only the fields of the body are written, the owner, state and deletion can't be changed by an update.
+	product := &models.Product{ID: body.ID, Title: body.Title, Author: body.Author, ProductAttrs: body.ProductAttrs}
+	product.Version = body.Version
 	// Create a new validator for a Product model.
 	validate := utils2.NewValidator()
 
 	// Set initialized default data for product:
 	product.ID = uuid.New()
-	product.ProductStatus = 1 // 0 == draft, 1 == active
+	product.TenantID = models.TenantFrom(c.UserContext())
+	product.ProductStatus = models.StateDraft // state only changes through transitions
+	product.Rating = models.ReviewStats{}     // rating only changes through reviews
 
 	// Validate product fields.
 	if err := validate.Struct(product); err != nil {
@@ -158,7 +276,12 @@ func Createproduct(c *fiber.Ctx) error {
 	}
 
 	// Create product by given model.
-	if err := models.NewProductRepo().Create(product); err != nil {
+	if err := productStore.Create(c.UserContext(), product); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
 		// Return status 500 and error message.
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": true,
@@ -174,6 +297,15 @@ func Createproduct(c *fiber.Ctx) error {
 	})
 }
 
+// productUpdate struct to describe the body of an update, the fields of models.RevisionColumns and the version.
+type productUpdate struct {
+	ID           uuid.UUID           `json:"id"`
+	Title        string              `json:"title"`
+	Author       string              `json:"author"`
+	ProductAttrs models.ProductAttrs `json:"product_attrs"`
+	Version      int64               `json:"version"`
+}
+
 // Updateproduct func for updates product by given ID.
 // @Description Update product.
 // @Summary update product
@@ -183,10 +315,13 @@ func Createproduct(c *fiber.Ctx) error {
 // @Param id body string true "Product ID"
 // @Param title body string true "Title"
 // @Param author body string true "Author"
-// @Param user_id body string true "User ID"
-// @Param product_status body integer true "Product status"
 // @Param product_attrs body models.ProductAttrs true "Product attributes"
-// @Success 202 {string} status "ok"
+// @Param version body integer false "Version, if If-Match is not given"
+// @Param If-Match header string false "ETag from GET /v1/product/{id} (required, unless PRODUCT_REQUIRE_IF_MATCH=false)"
+// @Success 201 {string} status "ok"
+// @Failure 409 {string} status "product was changed concurrently"
+// @Failure 412 {string} status "If-Match does not match the current version"
+// @Failure 428 {string} status "If-Match header is required"
 // @Security ApiKeyAuth
 // @Router /v1/product [put]
 func Updateproduct(c *fiber.Ctx) error {
@@ -227,11 +362,11 @@ func Updateproduct(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create new Product struct
-	product := &models.Product{}
+	// Create new productUpdate struct
+	body := &productUpdate{}
 
 	// Check, if received JSON data is valid.
-	if err := c.BodyParser(product); err != nil {
+	if err := c.BodyParser(body); err != nil {
 		// Return status 400 and error message.
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": true,
@@ -239,10 +374,18 @@ func Updateproduct(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only the fields of the body are written, the owner, state and deletion can't be changed by an update.
+	product := &models.Product{ID: body.ID, Title: body.Title, Author: body.Author, ProductAttrs: body.ProductAttrs}
+	product.Version = body.Version
+
 	// Checking, if product with given ID is exists.
-	var foundedproduct models.Product
-	err = models.NewProductRepo().Where("id = ?", product.ID).Scan(foundedproduct)
+	foundedproduct, err := productStore.Get(c.UserContext(), product.ID)
 	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
 		// Return status 404 and product not found error.
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": true,
@@ -255,6 +398,44 @@ func Updateproduct(c *fiber.Ctx) error {
 
 	// Only the creator can delete his product.
 	if string(foundedproduct.UserID) == userID {
+		// Get expected version from If-Match header.
+		version, hasIfMatch, err := ifMatchVersion(c)
+		if err != nil {
+			// Return status 400 and error message.
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": true,
+				"msg":   "invalid If-Match header, " + err.Error(),
+			})
+		}
+
+		// Return status 428, if If-Match header is required but not given.
+		if !hasIfMatch && ifMatchRequired() {
+			c.Set(fiber.HeaderETag, etag(foundedproduct.Version))
+			return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+				"error":   true,
+				"msg":     "precondition required, send If-Match header with the ETag of the product",
+				"version": foundedproduct.Version,
+			})
+		}
+
+		// Return status 412, if product was changed since the client has read it.
+		if version > 0 && version != foundedproduct.Version {
+			c.Set(fiber.HeaderETag, etag(foundedproduct.Version))
+			return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+				"error":   true,
+				"msg":     "precondition failed, product was changed by another request",
+				"version": foundedproduct.Version,
+			})
+		}
+
+		// Compare-and-swap on the version the client has seen.
+		if version == 0 && product.Version == 0 {
+			version = foundedproduct.Version
+		}
+		if version > 0 {
+			product.Version = version
+		}
+
 		// Set initialized default data for product:
 		product.UpdatedAt = time.Now()
 
@@ -270,9 +451,25 @@ func Updateproduct(c *fiber.Ctx) error {
 			})
 		}
 
-		// Update product by given ID.
+		// Update product by given ID and keep snapshots of both versions in the same transaction,
+		// so they can be restored later.
+		if err := productStore.Revise(c.UserContext(), foundedproduct, product, models.RevisionColumns, userID); err != nil {
+			// Return status 504/503, if query was aborted.
+			if aborted, err := abortedQuery(c, err); aborted {
+				return err
+			}
+
+			// Return status 409 and current version, if product was changed in the meantime.
+			if errors.Is(err, models.ErrVersionConflict) {
+				current, _ := productStore.Get(c.UserContext(), foundedproduct.ID)
+				c.Set(fiber.HeaderETag, etag(current.Version))
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error":   true,
+					"msg":     err.Error(),
+					"version": current.Version,
+				})
+			}
 
-		if err := models.NewProductRepo().Where("id = ?", foundedproduct.ID).Updates(product); err != nil {
 			// Return status 500 and error message.
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": true,
@@ -280,10 +477,14 @@ func Updateproduct(c *fiber.Ctx) error {
 			})
 		}
 
+		// Set ETag of the new version.
+		c.Set(fiber.HeaderETag, etag(product.Version))
+
 		// Return status 201.
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"error": false,
-			"msg":   nil,
+			"error":   false,
+			"msg":     nil,
+			"version": product.Version,
 		})
 	} else {
 		// Return status 403 and permission denied error message.
@@ -342,11 +543,11 @@ func Deleteproduct(c *fiber.Ctx) error {
 		})
 	}
 
-	// Create new Product struct
-	product := &models.Product{}
+	// Create new productUpdate struct
+	body := &productUpdate{}
 
 	// Check, if received JSON data is valid.
-	if err := c.BodyParser(product); err != nil {
+	if err := c.BodyParser(body); err != nil {
 		// Return status 400 and error message.
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": true,
@@ -354,6 +555,10 @@ func Deleteproduct(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only the fields of the body are written, the owner, state and deletion can't be changed by an update.
+	product := &models.Product{ID: body.ID, Title: body.Title, Author: body.Author, ProductAttrs: body.ProductAttrs}
+	product.Version = body.Version
+
 	// Create a new validator for a Product model.
 	validate := utils2.NewValidator()
 
@@ -367,9 +572,13 @@ func Deleteproduct(c *fiber.Ctx) error {
 	}
 
 	// Checking, if product with given ID is exists.
-	var foundedproduct models.Product
-	err = models.NewProductRepo().Where("id = ?", product.ID).Scan(foundedproduct)
+	foundedproduct, err := productStore.Get(c.UserContext(), product.ID)
 	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
 		// Return status 404 and product not found error.
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": true,
@@ -383,7 +592,12 @@ func Deleteproduct(c *fiber.Ctx) error {
 	// Only the creator can delete his product.
 	if foundedproduct.UserID == userID {
 		// Delete product by given ID.
-		if err := models.NewProductRepo().Delete(&foundedproduct); err != nil {
+		if err := productStore.Delete(c.UserContext(), foundedproduct.ID); err != nil {
+			// Return status 504/503, if query was aborted.
+			if aborted, err := abortedQuery(c, err); aborted {
+				return err
+			}
+
 			// Return status 500 and error message.
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": true,
@@ -401,3 +615,178 @@ func Deleteproduct(c *fiber.Ctx) error {
 		})
 	}
 }
+
+// Restoreproduct func for restores soft-deleted product by given ID.
+// @Description Restore deleted product by given ID.
+// @Summary restore deleted product by given ID
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Success 200 {object} models.Product
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/restore [post]
+func Restoreproduct(c *fiber.Ctx) error {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Set expiration time from JWT data of current product.
+	expires := claims.Expires
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > expires {
+		// Return status 401 and unauthorized error message.
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+
+	// Catch product ID from URL.
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if deleted product with given ID is exists.
+	foundedproduct, err := productStore.GetDeleted(c.UserContext(), id)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 404 and product not found error.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "deleted product with this ID not found",
+		})
+	}
+
+	// Only the creator with `product:delete` or holders of `product:restore` credential can restore product.
+	owner := foundedproduct.UserID == claims.UserID && claims.Credentials[repository.ProductDeleteCredential]
+	if !owner && !claims.Credentials[repository.ProductRestoreCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, only the creator or admin can restore this product",
+		})
+	}
+
+	// Restore product by given ID.
+	if err := productStore.Restore(c.UserContext(), id); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	foundedproduct.DeletedAt = gorm.DeletedAt{}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"product": foundedproduct,
+	})
+}
+
+// Gettrashproducts func gets soft-deleted products (trash).
+// @Description Get soft-deleted products, which are not purged yet.
+// @Summary get deleted products
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param filter[field][op] query string false "Filter, e.g. filter[title][like]=x"
+// @Param sort query string false "Sorting, e.g. -delete_at"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.Product
+// @Security ApiKeyAuth
+// @Router /v1/products/trash [get]
+func Gettrashproducts(c *fiber.Ctx) error {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > claims.Expires {
+		// Return status 401 and unauthorized error message.
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+
+	// Only user with `product:restore` credential can browse the trash.
+	if !claims.Credentials[repository.ProductRestoreCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+
+	// Parse filters, sorting and pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get deleted products.
+	products, total, err := productStore.Trash(c.UserContext(), query)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and allowed fields.
+		var queryErr *models.QueryError
+		if errors.As(err, &queryErr) {
+			return invalidQuery(c, err)
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"count":    total,
+		"products": products,
+	})
+}
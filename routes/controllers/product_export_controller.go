@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"bufio"
+	"errors"
+	"time"
+	"tuxiaocao/pkg/logger"
+	"tuxiaocao/routes/models"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportRequest func for parsing format, filters, category, tags and sorting of an export.
+func exportRequest(c *fiber.Ctx) (string, *models.Query, error) {
+	format := c.Query("format", models.ExportCSV)
+	if !containsFormat(format) {
+		return "", nil, &models.QueryError{Param: "format", Field: format, Reason: "is not supported", Allowed: models.ExportFormats}
+	}
+	query, err := listQuery(c)
+	if err != nil {
+		return "", nil, err
+	}
+	return format, query, taxonomyFilter(c, query)
+}
+
+func containsFormat(format string) bool {
+	for _, f := range models.ExportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// exportFailed func for answering errors of the first export batch.
+func exportFailed(c *fiber.Ctx, err error) error {
+	// Return status 504/503, if query was aborted.
+	if aborted, err := abortedQuery(c, err); aborted {
+		return err
+	}
+
+	// Return status 400 and allowed fields.
+	var queryErr *models.QueryError
+	if errors.As(err, &queryErr) {
+		return invalidQuery(c, err)
+	}
+
+	// Return status 500 and error message.
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// Exportproducts func for streaming all products, which match the filters, as a file.
+// @Description Stream products in batches as CSV, NDJSON or JSON array, with the same filters and sorting as the list of products.
+// @Summary export products
+// @Tags products
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce json
+// @Param format query string false "Format: csv (default), ndjson or json"
+// @Param filter[field][op] query string false "Filter, e.g. filter[product_status][eq]=active"
+// @Param category query string false "Category ID or slug, products of subcategories included"
+// @Param tag query []string false "Tags, products must have all of them" collectionFormat(multi)
+// @Param sort query string false "Sorting, e.g. -created_at"
+// @Success 200 {file} file
+// @Router /v1/products/export [get]
+func Exportproducts(c *fiber.Ctx) error {
+	// Parse format, filters, category, tags and sorting from query string.
+	format, query, err := exportRequest(c)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Read the first batch, so invalid queries are answered before streaming.
+	export := models.NewProductExport(c.UserContext(), productStore, query)
+	if err := export.Prefetch(); err != nil {
+		return exportFailed(c, err)
+	}
+
+	// Return status 200 and stream the remaining batches.
+	// The status can't be changed once streaming started, so later errors only end the stream.
+	c.Set(fiber.HeaderContentType, models.ExportContentType(format))
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="products.`+format+`"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if _, err := export.WriteTo(w, format); err != nil {
+			logger.Log.Errorf("export products error %v", err)
+		}
+	})
+	return nil
+}
+
+// exportJobResponse func for answering an export job with its download link, if finished.
+func exportJobResponse(c *fiber.Ctx, job models.ExportJob) fiber.Map {
+	response := fiber.Map{
+		"error":        false,
+		"msg":          nil,
+		"job":          job,
+		"download_url": nil,
+	}
+	if job.Status == models.ExportDone {
+		response["download_url"] = c.BaseURL() + "/api/v1/products/export/" + job.ID + "/download"
+	}
+	return response
+}
+
+// exportJobClaims func for checking the JWT of export job requests.
+// Returns the user ID, or false after answering the request.
+func exportJobClaims(c *fiber.Ctx) (string, bool, error) {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return "", false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > claims.Expires {
+		// Return status 401 and unauthorized error message.
+		return "", false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+	return claims.UserID, true, nil
+}
+
+// Startproductexport func for exporting products to a file in the background.
+// @Description Start a background export of products, which writes a file. Poll the job until it is done and download the file from the returned link.
+// @Summary start background export of products
+// @Tags products
+// @Produce json
+// @Param format query string false "Format: csv (default), ndjson or json"
+// @Param filter[field][op] query string false "Filter, e.g. filter[product_status][eq]=active"
+// @Param category query string false "Category ID or slug, products of subcategories included"
+// @Param tag query []string false "Tags, products must have all of them" collectionFormat(multi)
+// @Param sort query string false "Sorting, e.g. -created_at"
+// @Success 202 {object} models.ExportJob
+// @Security ApiKeyAuth
+// @Router /v1/products/export [post]
+func Startproductexport(c *fiber.Ctx) error {
+	userID, ok, err := exportJobClaims(c)
+	if !ok {
+		return err
+	}
+
+	// Parse format, filters, category, tags and sorting from query string.
+	format, query, err := exportRequest(c)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Start export job.
+	job, err := models.StartExportJob(c.UserContext(), productStore, query, format, userID)
+	if err != nil {
+		return exportFailed(c, err)
+	}
+
+	// Return status 202 Accepted.
+	return c.Status(fiber.StatusAccepted).JSON(exportJobResponse(c, job))
+}
+
+// Getproductexport func for getting the status of a background export.
+// @Description Get the status of a background export and the download link, when it is done.
+// @Summary get background export of products
+// @Tags products
+// @Produce json
+// @Param id path string true "Export job ID"
+// @Success 200 {object} models.ExportJob
+// @Security ApiKeyAuth
+// @Router /v1/products/export/{id} [get]
+func Getproductexport(c *fiber.Ctx) error {
+	userID, ok, err := exportJobClaims(c)
+	if !ok {
+		return err
+	}
+
+	// Get export job of the user.
+	job, found := models.GetExportJob(c.UserContext(), c.Params("id"), userID)
+	if !found {
+		// Return status 404 and error message.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "export with the given ID is not found",
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(exportJobResponse(c, job))
+}
+
+// Downloadproductexport func for downloading the file of a finished background export.
+// @Description Download the file of a finished background export.
+// @Summary download background export of products
+// @Tags products
+// @Produce octet-stream
+// @Param id path string true "Export job ID"
+// @Success 200 {file} file
+// @Security ApiKeyAuth
+// @Router /v1/products/export/{id}/download [get]
+func Downloadproductexport(c *fiber.Ctx) error {
+	userID, ok, err := exportJobClaims(c)
+	if !ok {
+		return err
+	}
+
+	// Get export job of the user.
+	job, found := models.GetExportJob(c.UserContext(), c.Params("id"), userID)
+	if !found {
+		// Return status 404 and error message.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "export with the given ID is not found",
+		})
+	}
+
+	file, err := job.File()
+	if err != nil {
+		// Return status 409, if export is still running or failed.
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":  true,
+			"msg":    err.Error(),
+			"status": job.Status,
+		})
+	}
+
+	// Return the file.
+	c.Set(fiber.HeaderContentType, models.ExportContentType(job.Format))
+	return c.Download(file, "products."+job.Format)
+}
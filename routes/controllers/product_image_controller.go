@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"time"
+	"tuxiaocao/pkg/logger"
+	"tuxiaocao/pkg/platform/storage"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// deleteObjects func for removing stored files, errors are only logged.
+func deleteObjects(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := storage.Store.Delete(ctx, key); err != nil {
+			logger.Log.Errorf("delete object %s error %v", key, err)
+		}
+	}
+}
+
+// ImageBodyLimit func for the body limit of image uploads, IMAGE_MAX_BYTES and the multipart framing.
+func ImageBodyLimit() int {
+	return int(models.ImageMaxBytes()) + 64<<10
+}
+
+// Uploadproductimage func for uploads the image of a product.
+// @Description Upload a JPEG, PNG or GIF image (multipart field `image`). The type is detected from the content,
+// @Description a thumbnail is generated, and picture and thumbnail of the product are set to the stored files.
+// @Summary upload image of a product
+// @Tags Product
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param image formData file true "Image file"
+// @Param If-Match header string false "ETag of the current version"
+// @Success 201 {object} queries.UploadedImage
+// @Failure 413 {string} status "image is too large"
+// @Failure 415 {string} status "unsupported image type"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/images [post]
+func Uploadproductimage(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	if storage.Store == nil {
+		// Return status 503, if no storage is configured.
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "file storage is not configured",
+		})
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Only the creator or holders of `product:update` credential can upload an image.
+	if product.UserID != claims.UserID && !claims.Credentials[repository.ProductUpdateCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, only the creator or holders of product:update can update this product",
+		})
+	}
+
+	// Return status 412, if product was changed since the client has read it.
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid If-Match header, " + err.Error(),
+		})
+	}
+	if version > 0 && version != product.Version {
+		c.Set(fiber.HeaderETag, etag(product.Version))
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error":   true,
+			"msg":     "precondition failed, product was changed by another request",
+			"version": product.Version,
+		})
+	}
+
+	// Read the uploaded file.
+	header, err := c.FormFile("image")
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "multipart field image is required",
+		})
+	}
+	data, err := func() ([]byte, error) {
+		if header.Size > models.ImageMaxBytes() {
+			return nil, models.ErrImageTooLarge
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return models.ReadImage(file)
+	}()
+	if err == nil {
+		var image *models.ProductImage
+		if image, err = models.NewProductImage(product, data); err == nil {
+			return storeProductImage(c, product, image, claims.UserID)
+		}
+	}
+
+	switch {
+	case errors.Is(err, models.ErrImageTooLarge):
+		// Return status 413 and size limit.
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error":     true,
+			"msg":       err.Error(),
+			"max_bytes": models.ImageMaxBytes(),
+		})
+	case errors.Is(err, models.ErrUnsupportedImage):
+		// Return status 415 and error message.
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	// Return status 500 and error message.
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// storeProductImage func for storing image and thumbnail and pointing the product to them.
+// New files are deleted again, if the product can't be updated.
+func storeProductImage(c *fiber.Ctx, product models.Product, image *models.ProductImage, userID string) error {
+	ctx := c.UserContext()
+	if err := storage.Store.Put(ctx, image.Key, bytes.NewReader(image.Data), int64(len(image.Data)), image.ContentType); err != nil {
+		// Return status 502, if storage has failed.
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": true,
+			"msg":   "store image error, " + err.Error(),
+		})
+	}
+	if err := storage.Store.Put(ctx, image.ThumbnailKey, bytes.NewReader(image.ThumbnailData), int64(len(image.ThumbnailData)), image.ThumbnailType); err != nil {
+		deleteObjects(context.WithoutCancel(ctx), image.Key)
+		// Return status 502, if storage has failed.
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": true,
+			"msg":   "store thumbnail error, " + err.Error(),
+		})
+	}
+
+	// Point the product to the new files, compare-and-swap on the current version,
+	// and keep snapshots of both versions in the same transaction.
+	updated := &models.Product{ID: product.ID, ProductAttrs: product.ProductAttrs}
+	updated.Version = product.Version
+	updated.UpdatedAt = time.Now()
+	updated.ProductAttrs.Picture = storage.Store.URL(image.Key)
+	updated.ProductAttrs.Thumbnail = storage.Store.URL(image.ThumbnailKey)
+	if err := productStore.Revise(ctx, product, updated, []string{"product_attrs"}, userID); err != nil {
+		deleteObjects(context.WithoutCancel(ctx), image.Key, image.ThumbnailKey)
+
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 409 and current version, if product was changed in the meantime.
+		if errors.Is(err, models.ErrVersionConflict) {
+			current, _ := productStore.Get(ctx, product.ID)
+			c.Set(fiber.HeaderETag, etag(current.Version))
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"msg":     err.Error(),
+				"version": current.Version,
+			})
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	// Files of the replaced image are kept, revisions of the product still point to them.
+
+	// Set ETag of the new version.
+	c.Set(fiber.HeaderETag, etag(updated.Version))
+	product.ProductAttrs = updated.ProductAttrs
+	product.UpdatedAt = updated.UpdatedAt
+	product.Version = updated.Version
+
+	// Return status 201 Created.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"image": queries.UploadedImage{
+			Picture:     updated.ProductAttrs.Picture,
+			Thumbnail:   updated.ProductAttrs.Thumbnail,
+			ContentType: image.ContentType,
+			Size:        len(image.Data),
+			Width:       image.Width,
+			Height:      image.Height,
+		},
+		"product": product,
+	})
+}
+
+// Getfile func for serves a stored file, e.g. product images of the local storage.
+// @Description Get a stored file by key. Keys are random, so files are cached for a year.
+// @Summary get stored file
+// @Tags File
+// @Produce octet-stream
+// @Param key path string true "Object key"
+// @Success 200 {file} file
+// @Router /v1/files/{key} [get]
+func Getfile(c *fiber.Ctx) error {
+	if storage.Store == nil {
+		// Return status 503, if no storage is configured.
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": true,
+			"msg":   "file storage is not configured",
+		})
+	}
+	key, err := url.PathUnescape(c.Params("*"))
+	if err == nil {
+		key, err = storage.CleanKey(key)
+	}
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   storage.ErrInvalidKey.Error(),
+		})
+	}
+
+	reader, object, err := storage.Store.Get(c.UserContext(), key)
+	if err != nil {
+		// Return status 404, if file doesn't exist.
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": true,
+				"msg":   "file with this key not found",
+			})
+		}
+
+		// Return status 502, if storage has failed.
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return the file, the content type was detected on upload.
+	if object.ContentType != "" {
+		c.Set(fiber.HeaderContentType, object.ContentType)
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	return c.SendStream(reader, int(object.Size))
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// ImportBodyLimit func for the body limit of imports, IMPORT_MAX_BYTES and the multipart framing.
+func ImportBodyLimit() int {
+	return int(models.ImportMaxBytes()) + 64<<10
+}
+
+// importFile func for getting the uploaded file (multipart field `file` or raw body) and its format.
+// The format is taken from the `format` query, the file name or the content type, CSV by default.
+func importFile(c *fiber.Ctx) (io.ReadCloser, string, error) {
+	format := c.Query("format")
+	var file io.ReadCloser
+	if header, err := c.FormFile("file"); err == nil {
+		if file, err = header.Open(); err != nil {
+			return nil, "", err
+		}
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	} else {
+		file = io.NopCloser(bytes.NewReader(c.Body()))
+		if format == "" && strings.Contains(c.Get(fiber.HeaderContentType), "ndjson") {
+			format = models.ImportNDJSON
+		}
+	}
+	if format == "" {
+		format = models.ImportCSV
+	}
+
+	for _, f := range models.ImportFormats {
+		if f == format {
+			return file, format, nil
+		}
+	}
+	_ = file.Close()
+	return nil, "", &models.QueryError{Param: "format", Field: format, Reason: "is not supported", Allowed: models.ImportFormats}
+}
+
+// Importproducts func for importing products from a CSV or NDJSON file.
+// @Description Import products from a CSV (same columns as the export) or NDJSON file. Every line is validated,
+// @Description valid lines are inserted in chunks. With atomic=true (default) nothing is written, if one line fails,
+// @Description with atomic=false valid lines are written anyway. With dry_run=true lines are only validated.
+// @Summary import products from file
+// @Tags Product
+// @Accept multipart/form-data
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param file formData file false "CSV or NDJSON file, or send the file as request body"
+// @Param format query string false "Format: csv or ndjson (by file name or content type, if empty)"
+// @Param dry_run query boolean false "Only validate lines, nothing is written"
+// @Param atomic query boolean false "All or nothing (default true), false imports valid lines only"
+// @Success 200 {array} queries.ImportError
+// @Failure 413 {string} status "request entity too large"
+// @Failure 422 {array} queries.ImportError
+// @Security ApiKeyAuth
+// @Router /v1/products/import [post]
+func Importproducts(c *fiber.Ctx) error {
+	// Get now time.
+	now := time.Now().Unix()
+
+	// Get claims from JWT.
+	claims, err := utils2.ExtractTokenMetadata(c)
+	if err != nil {
+		// Return status 500 and JWT parse error.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Checking, if now time greather than expiration from JWT.
+	if now > claims.Expires {
+		// Return status 401 and unauthorized error message.
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": true,
+			"msg":   "unauthorized, check expiration time of your token",
+		})
+	}
+
+	// Only user with `product:create` credential can import products.
+	if !claims.Credentials[repository.ProductCreateCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+
+	// Get uploaded file.
+	file, format, err := importFile(c)
+	if err != nil {
+		// Return status 400 and allowed formats.
+		return invalidQuery(c, err)
+	}
+	defer file.Close()
+
+	// Parse lines of the file.
+	rows, err := models.ReadProducts(file, format)
+	if err != nil {
+		// Return status 400 and error message.
+		return invalidQuery(c, err)
+	}
+
+	// Imported products start as draft, only holders of `product:approve` keep the state of the file.
+	if !claims.Credentials[repository.ProductApproveCredential] {
+		for i := range rows {
+			rows[i].Product.ProductStatus = models.StateDraft
+		}
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	atomic := c.QueryBool("atomic", true)
+	failed, valid, lines := validateImport(c, claims.UserID, rows)
+
+	// Insert valid lines in chunks, all or nothing is written on atomic import.
+	imported := 0
+	if !dryRun && (!atomic || len(failed) == 0) {
+		var errs []error
+		if atomic {
+			errs = productStore.CreateAll(c.UserContext(), valid, bulkChunkSize())
+		} else {
+			errs = productStore.CreateBatch(c.UserContext(), valid, bulkChunkSize())
+		}
+		for i, err := range errs {
+			if err == nil {
+				imported++
+				continue
+			}
+			// Return status 504/503, if query was aborted.
+			if aborted, err := abortedQuery(c, err); aborted {
+				return err
+			}
+			failed = append(failed, &queries.ImportError{Line: lines[i], ID: valid[i].ID.String(), Msg: err.Error()})
+		}
+		if atomic && len(failed) > 0 {
+			imported = 0
+		}
+	}
+
+	sort.SliceStable(failed, func(i, j int) bool { return failed[i].Line < failed[j].Line })
+
+	// Return status 422, if atomic import failed and nothing was written.
+	status := fiber.StatusOK
+	if atomic && !dryRun && len(failed) > 0 {
+		status = fiber.StatusUnprocessableEntity
+	}
+
+	// Return result and failed lines.
+	return c.Status(status).JSON(fiber.Map{
+		"error":    status != fiber.StatusOK,
+		"msg":      nil,
+		"dry_run":  dryRun,
+		"atomic":   atomic,
+		"total":    len(rows),
+		"valid":    len(valid),
+		"imported": imported,
+		"failed":   failed,
+	})
+}
+
+// validateImport func for validating the parsed lines of an import.
+// Returns the failed lines and the valid products with their line numbers.
+func validateImport(c *fiber.Ctx, userID string, rows []models.ImportRow) ([]*queries.ImportError, []*models.Product, []int) {
+	failed := []*queries.ImportError{}
+	var valid []*models.Product
+	var lines []int
+
+	// Create a new validator for a Product model.
+	validate := utils2.NewValidator()
+	for i := range rows {
+		row := &rows[i]
+		if row.Err != nil {
+			failed = append(failed, &queries.ImportError{Line: row.Line, Msg: row.Err.Error()})
+			continue
+		}
+
+		// Set initialized default data for product:
+		product := &row.Product
+		id := ""
+		if product.ID != uuid.Nil {
+			id = product.ID.String()
+		} else {
+			product.ID = uuid.New()
+		}
+		product.UserID = userID
+		product.TenantID = models.TenantFrom(c.UserContext())
+
+		// Validate product fields.
+		if err := validate.Struct(product); err != nil {
+			failed = append(failed, &queries.ImportError{Line: row.Line, ID: id, Msg: utils2.ValidatorErrors(err)})
+			continue
+		}
+		valid = append(valid, product)
+		lines = append(lines, row.Line)
+	}
+	return failed, valid, lines
+}
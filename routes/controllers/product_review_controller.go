@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"errors"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+	utils2 "tuxiaocao/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// reviewError func for answering errors of the review store.
+func reviewError(c *fiber.Ctx, err error) error {
+	// Return status 504/503, if query was aborted.
+	if aborted, err := abortedQuery(c, err); aborted {
+		return err
+	}
+
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Return status 404, if the review is not found.
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": true,
+			"msg":   "review not found",
+		})
+	case errors.Is(err, models.ErrOwnReview):
+		// Return status 403, if authors vote for their own review.
+		status = fiber.StatusForbidden
+	case errors.Is(err, models.ErrReviewExists), errors.Is(err, models.ErrAlreadyVoted), errors.Is(err, models.ErrVersionConflict):
+		// Return status 409, if the user has already reviewed or voted, or the review was changed concurrently.
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// reviewBody func for parsing and validating the body of a created or updated review.
+func reviewBody(c *fiber.Ctx) (*queries.ReviewBody, bool, error) {
+	body := &queries.ReviewBody{}
+
+	// Check, if received JSON data is valid.
+	if err := c.BodyParser(body); err != nil {
+		// Return status 400 and error message.
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Validate review fields.
+	if err := utils2.NewValidator().Struct(body); err != nil {
+		// Return, if some fields are not valid.
+		return nil, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   utils2.ValidatorErrors(err),
+		})
+	}
+	return body, true, nil
+}
+
+// Getproductreviews func gets the reviews of a product.
+// @Description Get reviews of a product with its rating summary, most helpful or most recent first.
+// @Summary get reviews of a product
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param sort query string false "Order of reviews" Enums(helpful, recent) default(helpful)
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.Review
+// @Router /v1/product/{id}/reviews [get]
+func Getproductreviews(c *fiber.Ctx) error {
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Parse pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+	by := c.Query("sort", models.ReviewSortHelpful)
+	if _, ok := models.ReviewSorts[by]; !ok {
+		// Return status 400 and allowed orders.
+		return invalidQuery(c, &models.QueryError{
+			Param:   "sort",
+			Field:   by,
+			Reason:  "is not supported",
+			Allowed: []string{models.ReviewSortHelpful, models.ReviewSortRecent},
+		})
+	}
+
+	// Get reviews of the product.
+	reviews, total, err := reviewStore.Reviews(c.UserContext(), product.ID, by, query.PageNo, query.PageSize)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"count":   total,
+		"rating":  product.Rating,
+		"reviews": reviews,
+	})
+}
+
+// Createproductreview func for reviews a product.
+// @Description Review a product with a score from 1 to 5, one review per user and product.
+// @Summary review a product
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param review body queries.ReviewBody true "Score and text"
+// @Success 201 {object} models.Review
+// @Failure 403 {string} status "creators can't review their own product"
+// @Failure 409 {string} status "user has already reviewed this product"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/reviews [post]
+func Createproductreview(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Creators can't review their own product.
+	if product.UserID == claims.UserID {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, creators can't review their own product",
+		})
+	}
+
+	body, ok, err := reviewBody(c)
+	if !ok {
+		return err
+	}
+
+	// Create review and update the rating of the product.
+	review := &models.Review{ID: uuid.New(), ProductID: product.ID, UserID: claims.UserID, Score: body.Score, Text: body.Text}
+	if err := reviewStore.CreateReview(c.UserContext(), review); err != nil {
+		return reviewError(c, err)
+	}
+
+	// Set ETag of the review.
+	c.Set(fiber.HeaderETag, etag(review.Version))
+
+	// Return status 201.
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"review": review,
+	})
+}
+
+// Updateproductreview func for updates the own review of a product.
+// @Description Change score and text of the own review of a product.
+// @Summary update own review of a product
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param review body queries.ReviewBody true "Score and text"
+// @Param If-Match header string false "ETag of the review"
+// @Success 200 {object} models.Review
+// @Failure 409 {string} status "review was changed concurrently"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/reviews [put]
+func Updateproductreview(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+	body, ok, err := reviewBody(c)
+	if !ok {
+		return err
+	}
+
+	// Get expected version from If-Match header.
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid If-Match header, " + err.Error(),
+		})
+	}
+
+	// Update review of the user and the rating of the product.
+	review := &models.Review{ProductID: product.ID, UserID: claims.UserID, Score: body.Score, Text: body.Text, Version: version}
+	if err := reviewStore.UpdateReview(c.UserContext(), review); err != nil {
+		return reviewError(c, err)
+	}
+
+	// Set ETag of the new version.
+	c.Set(fiber.HeaderETag, etag(review.Version))
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"review": review,
+	})
+}
+
+// Deleteproductreview func for deletes a review of a product.
+// @Description Delete the own review of a product, holders of `review:moderate` can delete reviews of other users.
+// @Summary delete review of a product
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param user_id query string false "Author of the review (moderators only), defaults to the current user"
+// @Success 204 {string} status "ok"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/reviews [delete]
+func Deleteproductreview(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Only holders of `review:moderate` credential can delete reviews of other users.
+	author := c.Query("user_id", claims.UserID)
+	if author != claims.UserID && !claims.Credentials[repository.ReviewModerateCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, check credentials of your token",
+		})
+	}
+
+	// Delete review and update the rating of the product.
+	if err := reviewStore.DeleteReview(c.UserContext(), product.ID, author); err != nil {
+		return reviewError(c, err)
+	}
+
+	// Return status 204 no content.
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Voteproductreview func for marks a review of a product as helpful.
+// @Description Mark a review as helpful, one vote per user, authors can't vote for their own review.
+// @Summary vote for a review
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param review path string true "Review ID"
+// @Success 200 {object} models.Review
+// @Failure 409 {string} status "user has already voted for this review"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/reviews/{review}/helpful [post]
+func Voteproductreview(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Catch review ID from URL.
+	reviewID, err := uuid.Parse(c.Params("review"))
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Count the vote of the user.
+	review, err := reviewStore.VoteHelpful(c.UserContext(), product.ID, reviewID, claims.UserID)
+	if err != nil {
+		return reviewError(c, err)
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":  false,
+		"msg":    nil,
+		"review": review,
+	})
+}
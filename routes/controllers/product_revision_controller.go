@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"tuxiaocao/pkg/repository"
+	"tuxiaocao/routes/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// currentRevision func for the snapshot of the current version, which has no revision before the first update.
+func currentRevision(product models.Product) models.ProductRevision {
+	revision := models.NewProductRevision(product, "")
+	revision.CreatedAt = product.UpdatedAt
+	return *revision
+}
+
+// productRevision func for getting the revision of the product, the current version is read from the product itself.
+func productRevision(c *fiber.Ctx, product models.Product, param string) (models.ProductRevision, bool, error) {
+	// Catch revision from URL or query string.
+	revision, err := strconv.ParseInt(param, 10, 64)
+	if err != nil || revision <= 0 {
+		// Return status 400 and error message.
+		return models.ProductRevision{}, false, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "revision must be a positive number",
+		})
+	}
+
+	// Get revision of the product.
+	found, err := productStore.Revision(c.UserContext(), product.ID, revision)
+	if errors.Is(err, gorm.ErrRecordNotFound) && revision == product.Version {
+		return currentRevision(product), true, nil
+	}
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return found, false, err
+		}
+
+		// Return status 404 and revision not found error.
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return found, false, c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": true,
+				"msg":   "revision of this product not found",
+			})
+		}
+
+		// Return status 500 and error message.
+		return found, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+	return found, true, nil
+}
+
+// Getproductrevisions func gets the revisions of a product.
+// @Description Get snapshots of a product, one per successful update, newest first.
+// @Summary get revisions of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.ProductRevision
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/revisions [get]
+func Getproductrevisions(c *fiber.Ctx) error {
+	if _, ok, err := validClaims(c); !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Parse pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get revisions of the product.
+	revisions, total, err := productStore.Revisions(c.UserContext(), product.ID, query.PageNo, query.PageSize)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":     false,
+		"msg":       nil,
+		"count":     total,
+		"version":   product.Version,
+		"revisions": revisions,
+	})
+}
+
+// Getproductrevision func gets one revision of a product.
+// @Description Get the snapshot of a product at the given revision (the version of the product).
+// @Summary get revision of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param rev path integer true "Revision"
+// @Success 200 {object} models.ProductRevision
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/revisions/{rev} [get]
+func Getproductrevision(c *fiber.Ctx) error {
+	if _, ok, err := validClaims(c); !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+	revision, ok, err := productRevision(c, product, c.Params("rev"))
+	if !ok {
+		return err
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"revision": revision,
+	})
+}
+
+// Diffproductrevisions func compares two revisions of a product.
+// @Description Get the fields, which changed between two revisions, with the value before and after. Nested fields are named like product_attrs.description.
+// @Summary compare revisions of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param rev path integer true "Revision to compare from"
+// @Param to query integer false "Revision to compare to (current version, if empty)"
+// @Success 200 {object} models.LogRecordChanges
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/revisions/{rev}/diff [get]
+func Diffproductrevisions(c *fiber.Ctx) error {
+	if _, ok, err := validClaims(c); !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+	from, ok, err := productRevision(c, product, c.Params("rev"))
+	if !ok {
+		return err
+	}
+	to := currentRevision(product)
+	if c.Query("to") != "" {
+		if to, ok, err = productRevision(c, product, c.Query("to")); !ok {
+			return err
+		}
+	}
+
+	// Compare snapshots.
+	changes, err := models.DiffSnapshots(from.Snapshot, to.Snapshot)
+	if err != nil {
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":   false,
+		"msg":     nil,
+		"from":    from.Revision,
+		"to":      to.Revision,
+		"changes": changes,
+	})
+}
+
+// Restoreproductrevision func for restores a product to the snapshot of a revision.
+// @Description Write the fields of a revision back to the product. The restore is a new version with its own revision.
+// @Summary restore revision of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param rev path integer true "Revision"
+// @Param If-Match header string false "ETag of the current version"
+// @Success 200 {object} models.Product
+// @Failure 409 {string} status "product was changed concurrently"
+// @Failure 412 {string} status "If-Match does not match the current version"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/revisions/{rev}/restore [post]
+func Restoreproductrevision(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Only the creator or holders of `product:update` credential can restore a revision.
+	if product.UserID != claims.UserID && !claims.Credentials[repository.ProductUpdateCredential] {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, only the creator or holders of product:update can restore this product",
+		})
+	}
+
+	revision, ok, err := productRevision(c, product, c.Params("rev"))
+	if !ok {
+		return err
+	}
+
+	// Return status 412, if product was changed since the client has read it.
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid If-Match header, " + err.Error(),
+		})
+	}
+	if version > 0 && version != product.Version {
+		c.Set(fiber.HeaderETag, etag(product.Version))
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error":   true,
+			"msg":     "precondition failed, product was changed by another request",
+			"version": product.Version,
+		})
+	}
+
+	// Write the snapshot back, compare-and-swap on the current version,
+	// and keep snapshots of both versions in the same transaction.
+	restored := &models.Product{ID: product.ID}
+	restored.Version = product.Version
+	revision.Snapshot.Apply(restored)
+	if err := productStore.Revise(c.UserContext(), product, restored, models.RevisionColumns, claims.UserID); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 409 and current version, if product was changed in the meantime.
+		if errors.Is(err, models.ErrVersionConflict) {
+			current, _ := productStore.Get(c.UserContext(), product.ID)
+			c.Set(fiber.HeaderETag, etag(current.Version))
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"msg":     err.Error(),
+				"version": current.Version,
+			})
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Set ETag of the new version.
+	c.Set(fiber.HeaderETag, etag(restored.Version))
+	revision.Snapshot.Apply(&product)
+	product.Version = restored.Version
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"revision": revision.Revision,
+		"product":  product,
+	})
+}
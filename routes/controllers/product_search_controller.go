@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"tuxiaocao/routes/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Searchproducts func for full-text search of products by title, author and description.
+// @Description Search products by title, author and description, most relevant first, with highlighted snippets.
+// @Summary full-text search of products
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param q query string true "Search text"
+// @Param filter[field][op] query string false "Filter, e.g. filter[product_status][eq]=active"
+// @Param category query string false "Category ID or slug, products of subcategories included"
+// @Param tag query []string false "Tags, products must have all of them" collectionFormat(multi)
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.ProductHit
+// @Router /v1/products/search [get]
+func Searchproducts(c *fiber.Ctx) error {
+	// Parse filters and pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Restrict products to category and tags.
+	if err := taxonomyFilter(c, query); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and error message.
+		return invalidQuery(c, err)
+	}
+
+	// Search products.
+	products, total, err := productStore.Search(c.UserContext(), c.Query("q"), query)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400, if search text is empty.
+		if errors.Is(err, models.ErrEmptySearch) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": true,
+				"msg":   err.Error(),
+			})
+		}
+
+		// Return status 400 and allowed fields.
+		var queryErr *models.QueryError
+		if errors.As(err, &queryErr) {
+			return invalidQuery(c, err)
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":    false,
+		"msg":      nil,
+		"count":    total,
+		"products": products,
+	})
+}
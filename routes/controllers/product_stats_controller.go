@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"tuxiaocao/routes/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Getproductstats func for grouped statistics of products.
+// @Description Count products per group, or sum/avg/min/max of a field per group. Time fields are grouped by day, week or month.
+// @Summary grouped statistics of products
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param group_by query string true "Fields to group by, comma-separated, e.g. author or created_at"
+// @Param metric query string false "Metric: count (default), sum, avg, min or max"
+// @Param field query string false "Field of the metric, required except for count"
+// @Param bucket query string false "Time bucket of time fields: day (default), week or month"
+// @Param filter[field][op] query string false "Filter, e.g. filter[product_status][eq]=active"
+// @Success 200 {array} models.AggregateRow
+// @Router /v1/products/stats [get]
+func Getproductstats(c *fiber.Ctx) error {
+	// Parse filters and aggregation from query string.
+	values, err := queryValues(c)
+	if err != nil {
+		// Return status 400 and error message.
+		return invalidQuery(c, err)
+	}
+	query, err := models.ParseQuery(values)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get statistics of products.
+	stats, err := productStore.Stats(c.UserContext(), query, models.ParseAggregation(values))
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 400 and allowed fields.
+		var queryErr *models.QueryError
+		if errors.As(err, &queryErr) {
+			return invalidQuery(c, err)
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error": false,
+		"msg":   nil,
+		"count": len(stats),
+		"stats": stats,
+	})
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+	"tuxiaocao/routes/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// transitionBody struct to describe the body of a transition.
+type transitionBody struct {
+	Comment string `json:"comment"`
+}
+
+// Transitionproduct func for changes the state of a product.
+// @Description Change the state of a product: submit (draft → in_review), approve (in_review → active), reject (in_review → draft) or archive (active → archived).
+// @Description submit and archive are allowed for the creator or holders of product:update, approve and reject for holders of product:approve.
+// @Summary change state of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param name path string true "Transition" Enums(submit, approve, reject, archive)
+// @Param comment body string false "Comment"
+// @Param If-Match header string false "ETag of the current version"
+// @Success 200 {object} models.ProductTransition
+// @Failure 409 {string} status "transition is not allowed in the current state"
+// @Failure 412 {string} status "If-Match does not match the current version"
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/transitions/{name} [post]
+func Transitionproduct(c *fiber.Ctx) error {
+	claims, ok, err := validClaims(c)
+	if !ok {
+		return err
+	}
+
+	// Return status 404 and allowed transitions, if transition is unknown.
+	transition, found := models.Transitions[c.Params("name")]
+	if !found {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   true,
+			"msg":     "unknown transition",
+			"allowed": models.TransitionNames(),
+		})
+	}
+
+	// Create new transition body struct.
+	body := &transitionBody{}
+	if len(c.Body()) > 0 {
+		// Check, if received JSON data is valid.
+		if err := c.BodyParser(body); err != nil {
+			// Return status 400 and error message.
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": true,
+				"msg":   err.Error(),
+			})
+		}
+	}
+
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Only holders of the credential of the transition (or the creator, if allowed) can change the state.
+	if !transition.Permits(product.UserID == claims.UserID, claims.Credentials) {
+		// Return status 403 and permission denied error message.
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": true,
+			"msg":   "permission denied, " + transition.Name + " requires " + transition.Credential,
+		})
+	}
+
+	// Return status 412, if product was changed since the client has read it.
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		// Return status 400 and error message.
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": true,
+			"msg":   "invalid If-Match header, " + err.Error(),
+		})
+	}
+	if version > 0 && version != product.Version {
+		c.Set(fiber.HeaderETag, etag(product.Version))
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+			"error":   true,
+			"msg":     "precondition failed, product was changed by another request",
+			"version": product.Version,
+		})
+	}
+
+	// Return status 409 and available transitions, if the current state doesn't allow the transition.
+	record, err := models.NewProductTransition(product, transition, claims.UserID, body.Comment)
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":       true,
+			"msg":         err.Error(),
+			"state":       product.ProductStatus,
+			"transitions": models.AvailableTransitions(product.ProductStatus),
+		})
+	}
+
+	// Change state, compare-and-swap on the current version.
+	changed := &models.Product{ID: product.ID}
+	changed.Version = product.Version
+	changed.UpdatedAt = time.Now()
+	if err := productStore.ChangeState(c.UserContext(), changed, record); err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 409 and current version, if product was changed in the meantime.
+		if errors.Is(err, models.ErrVersionConflict) {
+			current, _ := productStore.Get(c.UserContext(), product.ID)
+			c.Set(fiber.HeaderETag, etag(current.Version))
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   true,
+				"msg":     err.Error(),
+				"version": current.Version,
+			})
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Set ETag of the new version.
+	c.Set(fiber.HeaderETag, etag(changed.Version))
+	product.ProductStatus = changed.ProductStatus
+	product.UpdatedAt = changed.UpdatedAt
+	product.Version = changed.Version
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":       false,
+		"msg":         nil,
+		"transition":  record,
+		"transitions": models.AvailableTransitions(product.ProductStatus),
+		"product":     product,
+	})
+}
+
+// Getproducttransitions func gets the state changes of a product.
+// @Description Get the state changes of a product with user and comment, newest first.
+// @Summary get transitions of a product
+// @Tags Product
+// @Accept json
+// @Produce json
+// @Param id path string true "Product ID"
+// @Param page_no query integer false "Page number"
+// @Param page_size query integer false "Page size (default 20, max 100)"
+// @Success 200 {array} models.ProductTransition
+// @Security ApiKeyAuth
+// @Router /v1/product/{id}/transitions [get]
+func Getproducttransitions(c *fiber.Ctx) error {
+	if _, ok, err := validClaims(c); !ok {
+		return err
+	}
+	product, ok, err := productOfParam(c)
+	if !ok {
+		return err
+	}
+
+	// Parse pagination from query string.
+	query, err := pagedQuery(c)
+	if err != nil {
+		// Return status 400 and allowed fields.
+		return invalidQuery(c, err)
+	}
+
+	// Get transitions of the product.
+	transitions, total, err := productStore.Transitions(c.UserContext(), product.ID, query.PageNo, query.PageSize)
+	if err != nil {
+		// Return status 504/503, if query was aborted.
+		if aborted, err := abortedQuery(c, err); aborted {
+			return err
+		}
+
+		// Return status 500 and error message.
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": true,
+			"msg":   err.Error(),
+		})
+	}
+
+	// Return status 200 OK.
+	return c.JSON(fiber.Map{
+		"error":       false,
+		"msg":         nil,
+		"count":       total,
+		"state":       product.ProductStatus,
+		"available":   models.AvailableTransitions(product.ProductStatus),
+		"transitions": transitions,
+	})
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"tuxiaocao/routes/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// queryValues func for parsing the raw query string, keeping repeated and bracketed keys.
+func queryValues(c *fiber.Ctx) (url.Values, error) {
+	return url.ParseQuery(string(c.Request().URI().QueryString()))
+}
+
+// listQuery func for parsing filter, sort and pagination parameters of list endpoints.
+func listQuery(c *fiber.Ctx) (*models.Query, error) {
+	values, err := queryValues(c)
+	if err != nil {
+		return nil, err
+	}
+	return models.ParseQuery(values)
+}
+
+// pagedQuery func for parsing list parameters of endpoints, which return one page at most
+// (20 rows without page_size, 100 rows at most), unlike the product list, which returns all rows.
+func pagedQuery(c *fiber.Ctx) (*models.Query, error) {
+	query, err := listQuery(c)
+	if err != nil {
+		return nil, err
+	}
+	return query.Paged(), nil
+}
+
+// invalidQuery func for answering list parameters, which are unknown or not whitelisted.
+func invalidQuery(c *fiber.Ctx, err error) error {
+	// Return status 400 and the allowed fields or operators.
+	var queryErr *models.QueryError
+	if errors.As(err, &queryErr) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   true,
+			"msg":     queryErr.Error(),
+			"param":   queryErr.Param,
+			"field":   queryErr.Field,
+			"allowed": queryErr.Allowed,
+		})
+	}
+
+	// Return status 400 and error message.
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": true,
+		"msg":   err.Error(),
+	})
+}
+
+// taxonomyFilter func for restricting products to the `category` (ID or slug, descendants included)
+// and to all `tag` parameters (repeated or comma separated).
+func taxonomyFilter(c *fiber.Ctx, query *models.Query) error {
+	values, err := queryValues(c)
+	if err != nil {
+		return err
+	}
+	var tags []string
+	for _, value := range values["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if strings.TrimSpace(tag) != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	param := values.Get("category")
+	if param == "" && len(tags) == 0 {
+		return nil
+	}
+
+	var category *models.Category
+	if param != "" {
+		found, err := categoryStore.Category(c.UserContext(), param)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &models.QueryError{Param: "category", Field: param, Reason: "is not the ID or slug of a category"}
+		}
+		if err != nil {
+			return err
+		}
+		category = &found
+	}
+	taxonomy, err := categoryStore.Taxonomy(c.UserContext(), category, tags)
+	if errors.Is(err, models.ErrInvalidTag) {
+		return &models.QueryError{Param: "tag", Field: strings.Join(tags, ","), Reason: "is invalid, " + err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	query.Taxonomy = taxonomy
+	return nil
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"tuxiaocao/routes/models"
+
+	"github.com/google/uuid"
+)
+
+// Stores used by the handlers, backed by the database by default.
+var (
+	productStore  models.ProductStore  = models.NewGormProductStore()
+	userStore     models.UserStore     = models.NewGormUserStore()
+	categoryStore models.CategoryStore = models.NewGormCategoryStore()
+	reviewStore   models.ReviewStore   = models.NewGormReviewStore()
+	commentStore  models.CommentStore  = models.NewGormCommentStore()
+)
+
+// UseStores func for replacing the stores used by the handlers, e.g. with in-memory stores in tests or demo mode.
+func UseStores(products models.ProductStore, users models.UserStore, categories models.CategoryStore, reviews models.ReviewStore, comments models.CommentStore) {
+	productStore = products
+	userStore = users
+	categoryStore = categories
+	reviewStore = reviews
+	commentStore = comments
+}
+
+// productOwners func for getting the creators of the products with given IDs (deleted products included).
+func productOwners(ctx context.Context, ids []uuid.UUID, includeDeleted bool) (map[uuid.UUID]string, error) {
+	owners := map[uuid.UUID]string{}
+	if len(ids) == 0 {
+		return owners, nil
+	}
+
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	query := &models.Query{Filters: []*models.FilterParam{{Field: "id", Op: models.OpIn, Values: values}}}
+
+	list := []func(context.Context, *models.Query) ([]models.Product, int64, error){productStore.List}
+	if includeDeleted {
+		list = append(list, productStore.Trash)
+	}
+	for _, fn := range list {
+		founded, _, err := fn(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range founded {
+			owners[product.ID] = product.UserID
+		}
+	}
+	return owners, nil
+}
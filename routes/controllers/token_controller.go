@@ -2,9 +2,9 @@ package controllers
 
 import (
 	"context"
+	"strconv"
 	"time"
 	"tuxiaocao/pkg/platform/cache"
-	"tuxiaocao/routes/models"
 	"tuxiaocao/routes/queries"
 	utils2 "tuxiaocao/utils"
 
@@ -74,10 +74,24 @@ func RenewTokens(c *fiber.Ctx) error {
 		// Define user ID.
 		userID := claims.UserID
 
+		// Parse user ID.
+		id, err := strconv.Atoi(userID)
+		if err != nil {
+			// Return status 400 and error message.
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": true,
+				"msg":   "invalid user ID in token",
+			})
+		}
+
 		// Get user by ID.
-		var foundedUser models.User
-		err := models.NewUserRepo().Where("id = ? ", userID).Scan(foundedUser)
+		foundedUser, err := userStore.Get(c.UserContext(), id)
 		if err != nil {
+			// Return status 504/503, if query was aborted.
+			if aborted, err := abortedQuery(c, err); aborted {
+				return err
+			}
+
 			// Return, if user not found.
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": true,
@@ -96,7 +110,7 @@ func RenewTokens(c *fiber.Ctx) error {
 		}
 
 		// Generate JWT Access & Refresh tokens.
-		tokens, err := utils2.GenerateNewTokens(string(userID), credentials)
+		tokens, err := utils2.GenerateNewTokens(string(userID), foundedUser.TenantID, credentials)
 		if err != nil {
 			// Return status 500 and token generation error.
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
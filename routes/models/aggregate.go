@@ -0,0 +1,337 @@
+package models
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// 统计指标
+const (
+	MetricCount = "count"
+	MetricSum   = "sum"
+	MetricAvg   = "avg"
+	MetricMin   = "min"
+	MetricMax   = "max"
+)
+
+// 时间分组粒度
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// maxAggregateGroups 最多返回的分组数
+const maxAggregateGroups = 1000
+
+var (
+	metrics = []string{MetricCount, MetricSum, MetricAvg, MetricMin, MetricMax}
+	buckets = []string{BucketDay, BucketWeek, BucketMonth}
+)
+
+// Aggregation 分组统计：按 GroupBy 字段分组，时间字段按 Bucket 截断，对 Field 计算 Metric（count 不需要字段）
+// 分组字段由 group:"true" 标签开放，统计字段由 metric:"sum,avg,min,max" 标签开放
+type Aggregation struct {
+	GroupBy []string
+	Bucket  string
+	Metric  string
+	Field   string
+}
+
+// AggregateRow 一个分组的统计结果，时间分组的值为该时间段第一天（2006-01-02，周从周一开始）
+type AggregateRow struct {
+	Group map[string]interface{} `json:"group"`
+	Value interface{}            `json:"value"`
+}
+
+// ParseAggregation 解析统计参数：group_by（逗号分隔）、bucket、metric、field
+func ParseAggregation(values url.Values) *Aggregation {
+	a := &Aggregation{
+		Bucket: values.Get("bucket"),
+		Metric: values.Get("metric"),
+		Field:  values.Get("field"),
+	}
+	for _, name := range strings.Split(values.Get("group_by"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			a.GroupBy = append(a.GroupBy, name)
+		}
+	}
+	if a.Metric == "" {
+		a.Metric = MetricCount
+	}
+	return a
+}
+
+// isTimeField 时间字段分组时按 Bucket 截断
+func isTimeField(field *schema.Field) bool {
+	return field.FieldType == reflect.TypeOf(time.Time{})
+}
+
+// ValidateAggregation 按白名单校验分组和统计字段，时间分组未指定粒度时按天
+func (cs *Columns) ValidateAggregation(a *Aggregation) error {
+	if len(a.GroupBy) == 0 {
+		return &QueryError{Param: "group_by", Reason: "is required", Allowed: cs.Groups}
+	}
+	hasTime := false
+	for _, name := range a.GroupBy {
+		if !containsString(cs.Groups, name) {
+			return &QueryError{Param: "group_by", Field: name, Reason: "is not groupable", Allowed: cs.Groups}
+		}
+		hasTime = hasTime || isTimeField(cs.fields[name])
+	}
+	if hasTime && a.Bucket == "" {
+		a.Bucket = BucketDay
+	}
+	if a.Bucket != "" && !containsString(buckets, a.Bucket) {
+		return &QueryError{Param: "bucket", Field: a.Bucket, Reason: "is not supported", Allowed: buckets}
+	}
+
+	if !containsString(metrics, a.Metric) {
+		return &QueryError{Param: "metric", Field: a.Metric, Reason: "is not supported", Allowed: metrics}
+	}
+	if a.Metric == MetricCount {
+		return nil
+	}
+	var allowed []string
+	for name, ms := range cs.Metrics {
+		if containsString(ms, a.Metric) {
+			allowed = append(allowed, name)
+		}
+	}
+	sort.Strings(allowed)
+	if !containsString(allowed, a.Field) {
+		return &QueryError{Param: "field", Field: a.Field, Reason: "does not support metric " + a.Metric, Allowed: allowed}
+	}
+	return nil
+}
+
+// bucketExpr 各数据库将时间列截断到 bucket 的表达式
+func bucketExpr(dialect, column, bucket string) string {
+	switch dialect {
+	case "postgres":
+		return "date_trunc('" + bucket + "', " + column + ")"
+	case "mysql":
+		switch bucket {
+		case BucketWeek:
+			return "DATE(DATE_SUB(" + column + ", INTERVAL WEEKDAY(" + column + ") DAY))"
+		case BucketMonth:
+			return "DATE_FORMAT(" + column + ", '%Y-%m-01')"
+		}
+		return "DATE(" + column + ")"
+	default:
+		switch bucket {
+		case BucketWeek:
+			return "date(" + column + ", '-' || ((strftime('%w', " + column + ") + 6) % 7) || ' days')"
+		case BucketMonth:
+			return "strftime('%Y-%m-01', " + column + ")"
+		}
+		return "date(" + column + ")"
+	}
+}
+
+// Aggregate 分组统计，条件（如 ApplyQuery 的过滤条件）同样生效；时间分组按时间正序，其他按统计值倒序
+func (c *Curd[T]) Aggregate(a *Aggregation) ([]AggregateRow, error) {
+	var t T
+	if c.localDB == nil {
+		c.localDB = c.conn().Model(&t)
+	}
+	columns := ColumnsOf[T]()
+	if err := columns.ValidateAggregation(a); err != nil {
+		return nil, err
+	}
+
+	db := c.localDB
+	quote := db.Statement.Quote
+	selects := make([]string, 0, len(a.GroupBy)+1)
+	groups := make([]string, 0, len(a.GroupBy))
+	timeGroup := false
+	// 分组列按序号命名，时间分组的结果是日期而不是模型字段的类型
+	for i, name := range a.GroupBy {
+		expr := quote(name)
+		if isTimeField(columns.fields[name]) {
+			expr = bucketExpr(db.Dialector.Name(), expr, a.Bucket)
+			timeGroup = true
+		}
+		selects = append(selects, expr+" AS "+quote(groupAlias(i)))
+		groups = append(groups, expr)
+	}
+	metric := "COUNT(*)"
+	if a.Metric != MetricCount {
+		metric = strings.ToUpper(a.Metric) + "(" + quote(a.Field) + ")"
+	}
+	selects = append(selects, metric+" AS "+quote("value"))
+
+	order := metric + " DESC"
+	if timeGroup {
+		order = strings.Join(groups, ", ")
+	}
+	var rows []map[string]interface{}
+	err := db.Select(strings.Join(selects, ", ")).Group(strings.Join(groups, ", ")).Order(order).
+		Limit(maxAggregateGroups).Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AggregateRow, len(rows))
+	for i, row := range rows {
+		result[i] = AggregateRow{Group: map[string]interface{}{}, Value: aggregateValue(row["value"], true)}
+		for j, name := range a.GroupBy {
+			value := aggregateValue(row[groupAlias(j)], false)
+			if isTimeField(columns.fields[name]) {
+				value = bucketDate(value)
+			} else {
+				value = typedValue(columns.fields[name], value)
+			}
+			result[i].Group[name] = value
+		}
+	}
+	return result, nil
+}
+
+func groupAlias(i int) string {
+	return "group_" + strconv.Itoa(i)
+}
+
+// aggregateValue 统一驱动返回的类型：文本统一为 string，统计值中的文本数字（如 MySQL 的 DECIMAL）转换为 float64
+func aggregateValue(value interface{}, numeric bool) interface{} {
+	if raw, ok := value.([]byte); ok {
+		value = string(raw)
+	}
+	if text, ok := value.(string); ok && numeric {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// typedValue 驱动返回的整数转换为字段类型（如 ProductState），输出与内存实现一致
+func typedValue(field *schema.Field, value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || !field.FieldType.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()) || !v.CanConvert(field.FieldType) {
+		return value
+	}
+	return v.Convert(field.FieldType).Interface()
+}
+
+// bucketDate 时间分组统一为日期字符串
+func bucketDate(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.DateOnly)
+	case string:
+		if len(v) >= len(time.DateOnly) {
+			return v[:len(time.DateOnly)]
+		}
+	}
+	return value
+}
+
+// truncateTime 内存中按 bucket 截断时间，与数据库一致
+func truncateTime(t time.Time, bucket string) string {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch bucket {
+	case BucketWeek:
+		day = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case BucketMonth:
+		day = day.AddDate(0, 0, 1-day.Day())
+	}
+	return day.Format(time.DateOnly)
+}
+
+// aggregateRows 内存中分组统计，与 Aggregate 一致
+func aggregateRows[T any](columns *Columns, rows []T, a *Aggregation) ([]AggregateRow, error) {
+	if err := columns.ValidateAggregation(a); err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		key    map[string]interface{}
+		values []interface{}
+		count  int
+	}
+	groups := map[string]*group{}
+	var order []string
+	timeGroup := false
+	for i := range rows {
+		key := map[string]interface{}{}
+		for _, name := range a.GroupBy {
+			value, _ := fieldValue(&rows[i], columns.fields[name])
+			if t, ok := value.(time.Time); ok {
+				value = truncateTime(t, a.Bucket)
+				timeGroup = true
+			}
+			key[name] = value
+		}
+		id := fmt.Sprint(key)
+		g, ok := groups[id]
+		if !ok {
+			g = &group{key: key}
+			groups[id] = g
+			order = append(order, id)
+		}
+		g.count++
+		if a.Metric != MetricCount {
+			value, _ := fieldValue(&rows[i], columns.fields[a.Field])
+			g.values = append(g.values, value)
+		}
+	}
+
+	result := make([]AggregateRow, 0, len(groups))
+	for _, id := range order {
+		g := groups[id]
+		result = append(result, AggregateRow{Group: g.key, Value: metricValue(a.Metric, g.count, g.values)})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if timeGroup {
+			for _, name := range a.GroupBy {
+				if c := compareValues(result[i].Group[name], result[j].Group[name]); c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		}
+		return compareValues(result[i].Value, result[j].Value) > 0
+	})
+	if len(result) > maxAggregateGroups {
+		result = result[:maxAggregateGroups]
+	}
+	return result, nil
+}
+
+// metricValue 内存中计算统计值
+func metricValue(metric string, count int, values []interface{}) interface{} {
+	switch metric {
+	case MetricCount:
+		return int64(count)
+	case MetricSum, MetricAvg:
+		sum := 0.0
+		for _, value := range values {
+			f, _ := toFloat64(value)
+			sum += f
+		}
+		if metric == MetricAvg {
+			return sum / float64(len(values))
+		}
+		return sum
+	}
+	var best interface{}
+	for i, value := range values {
+		if i == 0 {
+			best = value
+			continue
+		}
+		if c := compareValues(value, best); (metric == MetricMin && c < 0) || (metric == MetricMax && c > 0) {
+			best = value
+		}
+	}
+	return best
+}
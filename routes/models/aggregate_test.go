@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductStats(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 12, 0, 0, 0, time.UTC) }
+	for _, product := range []Product{
+		{Author: "pike", ProductStatus: 1, BaseDbTime: BaseDbTime{CreatedAt: day(6), Version: 1}},  // Monday
+		{Author: "pike", ProductStatus: 2, BaseDbTime: BaseDbTime{CreatedAt: day(12), Version: 3}}, // Sunday
+		{Author: "thompson", ProductStatus: 1, BaseDbTime: BaseDbTime{CreatedAt: day(13), Version: 2}},
+	} {
+		product.ID = uuid.New()
+		product.Title = "title"
+		require.NoError(t, NewProductRepo().WithContext(ctx).Create(&product))
+		require.NoError(t, memory.Create(ctx, &product))
+	}
+
+	tests := []struct {
+		description string
+		query       *Query
+		aggregation *Aggregation
+		expected    []AggregateRow
+	}{
+		{
+			description: "count per author",
+			query:       &Query{},
+			aggregation: &Aggregation{GroupBy: []string{"author"}, Metric: MetricCount},
+			expected: []AggregateRow{
+				{Group: map[string]interface{}{"author": "pike"}, Value: int64(2)},
+				{Group: map[string]interface{}{"author": "thompson"}, Value: int64(1)},
+			},
+		},
+		{
+			description: "count per week",
+			query:       &Query{},
+			aggregation: &Aggregation{GroupBy: []string{"created_at"}, Bucket: BucketWeek, Metric: MetricCount},
+			expected: []AggregateRow{
+				{Group: map[string]interface{}{"created_at": "2024-05-06"}, Value: int64(2)},
+				{Group: map[string]interface{}{"created_at": "2024-05-13"}, Value: int64(1)},
+			},
+		},
+		{
+			description: "max version per author of active products",
+			query:       &Query{Filters: []*FilterParam{{Field: "product_status", Op: OpEq, Values: []string{"1"}}}},
+			aggregation: &Aggregation{GroupBy: []string{"author"}, Metric: MetricMax, Field: "version"},
+			expected: []AggregateRow{
+				{Group: map[string]interface{}{"author": "thompson"}, Value: int64(2)},
+				{Group: map[string]interface{}{"author": "pike"}, Value: int64(1)},
+			},
+		},
+	}
+
+	for name, store := range map[string]ProductStore{"gorm": NewGormProductStore(), "memory": memory} {
+		for _, test := range tests {
+			stats, err := store.Stats(ctx, test.query, test.aggregation)
+			require.NoError(t, err, name+": "+test.description)
+			assert.Equal(t, test.expected, stats, name+": "+test.description)
+		}
+
+		stats, err := store.Stats(ctx, &Query{}, &Aggregation{GroupBy: []string{"author"}, Metric: MetricAvg, Field: "version"})
+		require.NoError(t, err, name)
+		assert.InDelta(t, 2.0, stats[0].Value, 0.001, name)
+
+		_, err = store.Stats(ctx, &Query{}, &Aggregation{GroupBy: []string{"title"}, Metric: MetricCount})
+		var queryErr *QueryError
+		assert.ErrorAs(t, err, &queryErr, name)
+		_, err = store.Stats(ctx, &Query{}, &Aggregation{GroupBy: []string{"author"}, Metric: MetricSum, Field: "title"})
+		assert.ErrorAs(t, err, &queryErr, name)
+	}
+}
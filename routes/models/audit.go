@@ -0,0 +1,283 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 审计动作
+const (
+	AuditCreate = "create"
+	AuditUpsert = "upsert"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+const auditBeforeKey = "audit:before"
+
+// actorKey 上下文中的操作人
+type actorKey struct{}
+
+// WithActor 标记 ctx 中数据库写入的操作人（用户 ID），记录到审计日志
+func WithActor(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+func actorFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// auditNaming 实体名，如 Product => product
+var auditNaming = schema.NamingStrategy{SingularTable: true}
+
+// auditSnapshot 更新、删除前的记录，按主键
+type auditSnapshot struct {
+	keys   []interface{}
+	values map[interface{}]map[string]json.RawMessage
+}
+
+// AuditPlugin GORM 插件，Create、Update、Delete 后在同一事务内写入 LogRecord，记录操作人、动作以及字段修改前后的值
+// 带 audit:"-" 标签的字段不记录，如密码
+type AuditPlugin struct{}
+
+func (AuditPlugin) Name() string {
+	return "audit"
+}
+
+func (p AuditPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("gorm:create").Register("audit:create", p.afterCreate),
+		callbacks.Update().Before("gorm:update").Register("audit:before_update", p.snapshot),
+		callbacks.Update().After("gorm:update").Register("audit:update", p.afterUpdate),
+		callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", p.snapshot),
+		callbacks.Delete().After("gorm:delete").Register("audit:delete", p.afterDelete),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// audited 是否需要审计：有模型和主键，且不是审计日志、商品修订或流转记录这类历史记录本身
+func audited(db *gorm.DB) bool {
+	s := db.Statement.Schema
+	return db.Error == nil && !db.DryRun && s != nil && s.PrioritizedPrimaryField != nil &&
+		s.ModelType != reflect.TypeOf(LogRecord{}) && s.ModelType != reflect.TypeOf(ProductRevision{}) &&
+		s.ModelType != reflect.TypeOf(ProductTransition{})
+}
+
+func (p AuditPlugin) afterCreate(db *gorm.DB) {
+	if !audited(db) {
+		return
+	}
+	action := AuditCreate
+	if _, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+		action = AuditUpsert
+	}
+
+	var records []*LogRecord
+	eachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+		changes := LogRecordChanges{}
+		for name, value := range auditValues(db.Statement.Context, db.Statement.Schema, row) {
+			changes[name] = &FieldChange{Before: json.RawMessage("null"), After: value}
+		}
+		records = append(records, p.record(db, row, action, changes))
+	})
+	p.write(db, records)
+}
+
+// snapshot 更新、删除前读取将被修改的记录
+func (p AuditPlugin) snapshot(db *gorm.DB) {
+	if !audited(db) {
+		return
+	}
+	s := db.Statement.Schema
+	tx := p.session(db)
+	if where, ok := db.Statement.Clauses["WHERE"]; ok {
+		tx = tx.Clauses(where.Expression)
+	}
+	// 模型或更新值带主键时，gorm 会将主键加入条件
+	for _, value := range []reflect.Value{db.Statement.ReflectValue, reflect.Indirect(reflect.ValueOf(db.Statement.Dest))} {
+		for value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct && value.Type() == s.ModelType {
+			if pk, zero := s.PrioritizedPrimaryField.ValueOf(db.Statement.Context, value); !zero {
+				tx = tx.Where(clause.Eq{Column: clause.Column{Name: s.PrioritizedPrimaryField.DBName}, Value: pk})
+			}
+		}
+	}
+
+	snapshot, err := p.load(db, tx)
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+	db.Statement.Settings.Store(auditBeforeKey, snapshot)
+}
+
+func (p AuditPlugin) afterUpdate(db *gorm.DB) {
+	before, ok := p.before(db)
+	if !ok || !audited(db) {
+		return
+	}
+	s := db.Statement.Schema
+	after, err := p.load(db, p.session(db).Unscoped().Where(clause.IN{Column: clause.Column{Name: s.PrioritizedPrimaryField.DBName}, Values: before.keys}))
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+
+	var records []*LogRecord
+	for _, key := range before.keys {
+		changes := LogRecordChanges{}
+		for name, value := range after.values[key] {
+			if old := before.values[key][name]; string(old) != string(value) {
+				changes[name] = &FieldChange{Before: old, After: value}
+			}
+		}
+		if len(changes) > 0 {
+			records = append(records, p.newRecord(db, fmt.Sprint(key), AuditUpdate, changes, after.values[key]))
+		}
+	}
+	p.write(db, records)
+}
+
+func (p AuditPlugin) afterDelete(db *gorm.DB) {
+	before, ok := p.before(db)
+	if !ok || !audited(db) {
+		return
+	}
+
+	var records []*LogRecord
+	for _, key := range before.keys {
+		changes := LogRecordChanges{}
+		for name, value := range before.values[key] {
+			changes[name] = &FieldChange{Before: value, After: json.RawMessage("null")}
+		}
+		records = append(records, p.newRecord(db, fmt.Sprint(key), AuditDelete, changes, before.values[key]))
+	}
+	p.write(db, records)
+}
+
+func (p AuditPlugin) before(db *gorm.DB) (*auditSnapshot, bool) {
+	value, ok := db.Statement.Settings.LoadAndDelete(auditBeforeKey)
+	if !ok {
+		return nil, false
+	}
+	snapshot := value.(*auditSnapshot)
+	return snapshot, len(snapshot.keys) > 0
+}
+
+// session 同一连接（事务）上的新查询，不触发模型钩子；条件已由 TenantPlugin 限定租户
+func (p AuditPlugin) session(db *gorm.DB) *gorm.DB {
+	tx := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Set(tenantSkipKey, true).Model(reflect.New(db.Statement.Schema.ModelType).Interface())
+	if db.Statement.Unscoped {
+		tx = tx.Unscoped()
+	}
+	return tx
+}
+
+// load 查询记录并按主键保存字段值
+func (p AuditPlugin) load(db *gorm.DB, tx *gorm.DB) (*auditSnapshot, error) {
+	s := db.Statement.Schema
+	rows := reflect.New(reflect.SliceOf(s.ModelType))
+	if err := tx.Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+
+	snapshot := &auditSnapshot{values: map[interface{}]map[string]json.RawMessage{}}
+	eachRow(rows, func(row reflect.Value) {
+		key, _ := s.PrioritizedPrimaryField.ValueOf(db.Statement.Context, row)
+		snapshot.keys = append(snapshot.keys, key)
+		snapshot.values[key] = auditValues(db.Statement.Context, s, row)
+	})
+	return snapshot, nil
+}
+
+func (p AuditPlugin) record(db *gorm.DB, row reflect.Value, action string, changes LogRecordChanges) *LogRecord {
+	key, _ := db.Statement.Schema.PrioritizedPrimaryField.ValueOf(db.Statement.Context, row)
+	return p.newRecord(db, fmt.Sprint(key), action, changes, auditValues(db.Statement.Context, db.Statement.Schema, row))
+}
+
+// newRecord 审计记录属于被修改记录的租户，非租户模型属于 ctx 的租户
+func (p AuditPlugin) newRecord(db *gorm.DB, entityID, action string, changes LogRecordChanges, values map[string]json.RawMessage) *LogRecord {
+	tenant := TenantFrom(db.Statement.Context)
+	if raw, ok := values[tenantColumn]; ok {
+		_ = json.Unmarshal(raw, &tenant)
+	}
+	return &LogRecord{
+		ID:       uuid.New(),
+		TenantID: tenant,
+		UserID:   actorFrom(db.Statement.Context),
+		Entity:   auditNaming.TableName(db.Statement.Schema.Name),
+		EntityID: entityID,
+		Action:   action,
+		Changes:  changes,
+	}
+}
+
+// write 在同一连接（事务）上写入审计日志，失败时整个写入回滚
+func (p AuditPlugin) write(db *gorm.DB, records []*LogRecord) {
+	if len(records) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Set(tenantSkipKey, true).Create(&records).Error; err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+	}
+}
+
+// eachRow 遍历结构体或切片中的每一行
+func eachRow(value reflect.Value, fn func(row reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			row := reflect.Indirect(value.Index(i))
+			if row.Kind() == reflect.Struct {
+				fn(row)
+			}
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+// auditValues 行的字段值（JSON），按列名
+func auditValues(ctx context.Context, s *schema.Schema, row reflect.Value) map[string]json.RawMessage {
+	values := map[string]json.RawMessage{}
+	for _, field := range s.Fields {
+		if field.DBName == "" || field.Tag.Get("audit") == "-" {
+			continue
+		}
+		value, _ := field.ValueOf(ctx, row)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		values[field.DBName] = raw
+	}
+	return values
+}
+
+// AuditTrail 按实体类型和 ID 查询审计记录，最新的在前；entityID 为空时查询该类型的全部记录
+func AuditTrail(ctx context.Context, entity, entityID string, pageNo, pageSize int) ([]LogRecord, int64, error) {
+	repo := NewLogRecordRepo().WithContext(ctx).Where("entity = ?", entity)
+	if entityID != "" {
+		repo.Where("entity_id = ?", entityID)
+	}
+	return repo.List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder("created_at desc"))
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditTrail(t *testing.T) {
+	openTenantDB(t)
+	a := WithActor(WithTenant(context.Background(), "a"), "7")
+	b := WithTenant(context.Background(), "b")
+	store := NewGormProductStore()
+
+	product := &Product{ID: uuid.New(), Title: "go", Author: "rob"}
+	require.NoError(t, store.Create(a, product))
+	product.Title = "go 2"
+	require.NoError(t, store.Update(a, product))
+	require.NoError(t, store.Delete(a, product.ID))
+	require.NoError(t, store.Create(b, &Product{ID: uuid.New(), Title: "other"}))
+
+	// 最新的在前，记录操作人和修改前后的值
+	records, total, err := AuditTrail(a, "product", product.ID.String(), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, records, 3)
+	actions := []string{}
+	for _, record := range records {
+		actions = append(actions, record.Action)
+		assert.Equal(t, "a", record.TenantID)
+		assert.Equal(t, "7", record.UserID)
+		assert.Equal(t, product.ID.String(), record.EntityID)
+	}
+	assert.Equal(t, []string{AuditDelete, AuditUpdate, AuditCreate}, actions)
+
+	created := records[2].Changes["title"]
+	require.NotNil(t, created)
+	assert.JSONEq(t, `null`, string(created.Before))
+	assert.JSONEq(t, `"go"`, string(created.After))
+
+	// 更新只记录修改的字段
+	updated := records[1].Changes
+	require.Contains(t, updated, "title")
+	assert.JSONEq(t, `"go"`, string(updated["title"].Before))
+	assert.JSONEq(t, `"go 2"`, string(updated["title"].After))
+	assert.NotContains(t, updated, "author")
+
+	deleted := records[0].Changes["title"]
+	require.NotNil(t, deleted)
+	assert.JSONEq(t, `"go 2"`, string(deleted.Before))
+	assert.JSONEq(t, `null`, string(deleted.After))
+
+	// 其他租户的记录不可见
+	_, total, err = AuditTrail(b, "product", product.ID.String(), 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	records, total, err = AuditTrail(b, "product", "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, AuditCreate, records[0].Action)
+	assert.Equal(t, "", records[0].UserID)
+}
+
+func TestAuditSkipsSecrets(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+
+	require.NoError(t, NewUserRepo().WithContext(ctx).Create(&User{ID: 1, Username: "alice", PasswordHash: "secret"}))
+	records, _, err := AuditTrail(ctx, "user", "1", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Contains(t, records[0].Changes, "username")
+	assert.NotContains(t, records[0].Changes, "password_hash")
+	raw, err := json.Marshal(records[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+}
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
 	"strings"
 	"time"
 	"tuxiaocao/pkg/logger"
@@ -11,50 +14,136 @@ import (
 
 type Curd[T any] struct {
 	localDB *gorm.DB
+	baseDB  *gorm.DB // 绑定的连接（如事务），为空时使用 database.DB
+	cached  bool     // 读缓存，见 Cached
 }
 
 type BaseDbTime struct {
-	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime;" json:"created_at"`
-	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime;" json:"updated_at"`
-	DeletedAt *time.Time `gorm:"column:delete_at;default:null" json:"deleted_at"`
+	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime;" json:"created_at" filter:"gt,gte,lt,lte" sort:"true" group:"true" metric:"min,max"`
+	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime;" json:"updated_at" filter:"gt,gte,lt,lte" sort:"true" group:"true" metric:"min,max" audit:"-"`
+	DeletedAt gorm.DeletedAt `gorm:"column:delete_at;index" json:"deleted_at" sort:"true"`
+	Version   int64          `gorm:"column:version;not null;default:1" json:"version" metric:"sum,avg,min,max"`
+}
+
+// errNoAffectedRows 删除时没有匹配的记录
+var errNoAffectedRows = errors.New("no affected rows")
+
+// ErrVersionConflict 记录在读取之后已被其他请求修改
+var ErrVersionConflict = errors.New("version conflict, the record was changed by another request")
+
+// versioned 带版本号的模型，Updates 时按版本号做比较交换
+type versioned interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
+// base 公共字段，供内存存储读写时间和版本号
+func (b *BaseDbTime) base() *BaseDbTime {
+	return b
+}
+
+// GetVersion 当前版本号
+func (b *BaseDbTime) GetVersion() int64 {
+	return b.Version
+}
+
+// SetVersion 设置版本号
+func (b *BaseDbTime) SetVersion(version int64) {
+	b.Version = version
+}
+
+// conn 当前仓库使用的数据库连接
+func (c *Curd[T]) conn() *gorm.DB {
+	if c.baseDB != nil {
+		return c.baseDB
+	}
+	return database.DB
+}
+
+// WithContext 绑定请求上下文，上下文取消或超时后数据库操作随之中止，需在 Where/Select 等条件之前调用
+func (c *Curd[T]) WithContext(ctx context.Context) *Curd[T] {
+	c.baseDB = c.conn().WithContext(ctx)
+	c.localDB = nil
+	return c
+}
+
+// IsContextError 判断错误是否由上下文取消或超时引起
+func IsContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
 
 func (c *Curd[T]) Create(model *T) error {
 	if c.localDB == nil {
-		c.localDB = database.DB
+		c.localDB = c.conn()
 	}
-	return database.DB.Create(&model).Error
+	return c.localDB.Create(&model).Error
 }
 
 func (c *Curd[T]) Delete(model *T) error {
 	if c.localDB == nil {
-		c.localDB = database.DB
+		c.localDB = c.conn()
 	}
 	db := c.localDB.Delete(&model)
-	if db.RowsAffected == 0 {
-		return errors.New("no affected rows")
+	if db.RowsAffected == 0 && db.Error == nil {
+		return errNoAffectedRows
 	}
 	return db.Error
 }
 
+// Updates 更新非零字段；模型带版本号且版本号大于0时只在版本一致时更新，并将版本号加1，否则返回 ErrVersionConflict
 func (c *Curd[T]) Updates(model *T) error {
 	if c.localDB == nil {
 		var t T
-		c.localDB = database.DB.Model(&t)
+		c.localDB = c.conn().Model(&t)
+	}
+	if v, ok := any(model).(versioned); ok && v.GetVersion() > 0 {
+		current := v.GetVersion()
+		v.SetVersion(current + 1)
+		db := c.localDB.Where("version = ?", current).Updates(&model)
+		if db.Error == nil && db.RowsAffected == 0 {
+			db.Error = ErrVersionConflict
+		}
+		if db.Error != nil {
+			v.SetVersion(current)
+		}
+		return db.Error
 	}
 	db := c.localDB.Updates(&model)
 	return db.Error
 }
 
+// UpdateColumns 按字段值或表达式（如 gorm.Expr("count + ?", 1)）更新，不更新 updated_at 和版本号；没有匹配的记录时返回 errNoAffectedRows
+// 直接生成 SET 子句，不受字段写权限（<-:false）限制，用于只由服务端维护的字段
+func (c *Curd[T]) UpdateColumns(values map[string]interface{}) error {
+	if c.localDB == nil {
+		var t T
+		c.localDB = c.conn().Model(&t)
+	}
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	set := make(clause.Set, len(columns))
+	for i, column := range columns {
+		set[i] = clause.Assignment{Column: clause.Column{Name: column}, Value: values[column]}
+	}
+	db := c.localDB.Clauses(set).UpdateColumns(map[string]interface{}{})
+	if db.RowsAffected == 0 && db.Error == nil {
+		return errNoAffectedRows
+	}
+	return db.Error
+}
+
 func (c *Curd[T]) Table(name string, args ...interface{}) *Curd[T] {
-	c.localDB = database.DB.Table(name, args...)
+	c.localDB = c.conn().Table(name, args...)
 	return c
 }
 
 func (c *Curd[T]) Where(query interface{}, args ...interface{}) *Curd[T] {
 	if c.localDB == nil {
 		var t T
-		c.localDB = database.DB.Model(&t)
+		c.localDB = c.conn().Model(&t)
 	}
 	c.localDB = c.localDB.Where(query, args...)
 	return c
@@ -63,7 +152,7 @@ func (c *Curd[T]) Where(query interface{}, args ...interface{}) *Curd[T] {
 func (c *Curd[T]) Count() (count int64) {
 	if c.localDB == nil {
 		var t T
-		c.localDB = database.DB.Model(&t)
+		c.localDB = c.conn().Model(&t)
 	}
 	c.localDB = c.localDB.Count(&count)
 	return
@@ -72,7 +161,7 @@ func (c *Curd[T]) Count() (count int64) {
 func (c *Curd[T]) OR(query interface{}, args ...interface{}) *Curd[T] {
 	if c.localDB == nil {
 		var t T
-		c.localDB = database.DB.Model(&t)
+		c.localDB = c.conn().Model(&t)
 	}
 	c.localDB = c.localDB.Or(query, args...)
 	return c
@@ -81,7 +170,7 @@ func (c *Curd[T]) OR(query interface{}, args ...interface{}) *Curd[T] {
 func (c *Curd[T]) Preload(query string, args ...interface{}) *Curd[T] {
 	if c.localDB == nil {
 		var t T
-		c.localDB = database.DB.Model(t)
+		c.localDB = c.conn().Model(t)
 	}
 	c.localDB = c.localDB.Preload(query, args...)
 	return c
@@ -89,7 +178,16 @@ func (c *Curd[T]) Preload(query string, args ...interface{}) *Curd[T] {
 
 func (c *Curd[T]) Take() (t T, err error) {
 	if c.localDB == nil {
-		c.localDB = database.DB.Model(t)
+		c.localDB = c.conn().Model(t)
+	}
+	if ttl, ok := c.cacheTTL(); ok {
+		key := cacheKey(c.localDB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Take(&t)
+		}))
+		return cacheLoad(c.localDB.Statement.Context, ColumnsOf[T]().schema.Table, key, ttl, func(ctx context.Context) (t T, err error) {
+			err = primary(c.localDB.WithContext(ctx)).Take(&t).Error
+			return t, err
+		})
 	}
 	if err = c.localDB.Take(&t).Error; err != nil {
 		return t, err
@@ -99,7 +197,7 @@ func (c *Curd[T]) Take() (t T, err error) {
 
 func (c *Curd[T]) Select(query interface{}, args ...interface{}) *Curd[T] {
 	if c.localDB == nil {
-		c.localDB = database.DB
+		c.localDB = c.conn()
 	}
 	c.localDB = c.localDB.Select(query, args...)
 	return c
@@ -107,7 +205,7 @@ func (c *Curd[T]) Select(query interface{}, args ...interface{}) *Curd[T] {
 
 func (c *Curd[T]) Omit(columns ...string) *Curd[T] {
 	if c.localDB == nil {
-		c.localDB = database.DB
+		c.localDB = c.conn()
 	}
 	c.localDB = c.localDB.Omit(columns...)
 	return c
@@ -115,7 +213,7 @@ func (c *Curd[T]) Omit(columns ...string) *Curd[T] {
 
 func (c *Curd[T]) Scan(dest interface{}) error {
 	if c.localDB == nil {
-		c.localDB = database.DB
+		c.localDB = c.conn()
 	}
 	if err := c.localDB.Scan(dest).Error; err != nil {
 		return err
@@ -127,7 +225,7 @@ func (c *Curd[T]) Scan(dest interface{}) error {
 func (c *Curd[T]) List(op *QueryOption) (data []T, total int64, err error) {
 	if c.localDB == nil {
 		var t T
-		c.localDB = database.DB.Model(&t)
+		c.localDB = c.conn().Model(&t)
 	}
 	if op != nil {
 		// 大于0表示需要分页
@@ -142,6 +240,16 @@ func (c *Curd[T]) List(op *QueryOption) (data []T, total int64, err error) {
 			c.localDB = c.localDB.Order(op.order)
 		}
 	}
+	if ttl, ok := c.cacheTTL(); ok {
+		key := cacheKey(c.localDB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Find(&[]T{})
+		}))
+		page, err := cacheLoad(c.localDB.Statement.Context, ColumnsOf[T]().schema.Table, key, ttl, func(ctx context.Context) (page cachedPage[T], err error) {
+			err = primary(c.localDB.WithContext(ctx)).Find(&page.Data).Limit(-1).Offset(-1).Count(&page.Total).Error
+			return page, err
+		})
+		return page.Data, page.Total, err
+	}
 	if err = c.localDB.Find(&data).Limit(-1).Offset(-1).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -149,9 +257,11 @@ func (c *Curd[T]) List(op *QueryOption) (data []T, total int64, err error) {
 }
 
 type QueryOption struct {
-	offset int    // 页码
-	limit  int    // 每页数量
-	order  string // 排序字段
+	offset  int          // 页码
+	limit   int          // 每页数量
+	order   string       // 排序字段
+	sorting []*SortParam // 游标分页的排序字段
+	cursor  string       // 游标
 }
 
 // NewOP 查询条件
@@ -175,11 +285,30 @@ func (op *QueryOption) SetOrder(order string) *QueryOption {
 	return op
 }
 
+// SetSorting 游标分页使用的排序字段
+func (op *QueryOption) SetSorting(sorting []*SortParam) *QueryOption {
+	op.sorting = sorting
+	return op
+}
+
+// Sorting 排序字段
+func (op *QueryOption) Sorting() []*SortParam {
+	return op.sorting
+}
+
+// SetCursor 上一次查询返回的 next_cursor 或 prev_cursor，为空表示第一页
+func (op *QueryOption) SetCursor(cursor string) *QueryOption {
+	op.cursor = cursor
+	return op
+}
+
 type Query struct {
-	Name     string       `json:"name"`
-	PageNo   int          `json:"page_no"`
-	PageSize int          `json:"page_size"`
-	Sorting  []*SortParam `json:"sorting"`
+	Name     string         `json:"name"`
+	PageNo   int            `json:"page_no"`
+	PageSize int            `json:"page_size"`
+	Sorting  []*SortParam   `json:"sorting"`
+	Filters  []*FilterParam `json:"filters"`
+	Taxonomy *Taxonomy      `json:"-"` // 分类和标签条件，只用于商品
 }
 
 type SortParam struct {
@@ -190,7 +319,7 @@ type SortParam struct {
 func (c *Curd[T]) SetSortParams(sortParams []*SortParam) (order string) {
 	var t T
 	if c.localDB == nil {
-		c.localDB = database.DB.Model(&t)
+		c.localDB = c.conn().Model(&t)
 	}
 	if sortParams == nil || len(sortParams) == 0 {
 		sortParams = append(sortParams, &SortParam{SortBy: "id", Descending: true})
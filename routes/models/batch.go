@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultBatchSize = 100
+
+// CreateBatch 按 size 分批插入，返回与 rows 一一对应的错误（nil 表示成功）
+// 某一批插入失败时在保存点内逐行重试，以定位具体失败的行，其余行照常写入
+func (c *Curd[T]) CreateBatch(rows []*T, size int) []error {
+	return c.batch(rows, size, func(db *gorm.DB, chunk []*T) error {
+		return db.Create(chunk).Error
+	})
+}
+
+// errRolledBack 有行插入失败，事务整体回滚
+var errRolledBack = errors.New("rolled back")
+
+// CreateAll 在一个事务中按 size 分批插入，任一行失败时全部回滚，返回与 rows 一一对应的错误
+// 失败的行同 CreateBatch 一样在保存点内定位，其余行的错误为 nil，但同样没有写入
+func (c *Curd[T]) CreateAll(rows []*T, size int) []error {
+	var errs []error
+	err := c.conn().Transaction(func(tx *gorm.DB) error {
+		errs = (&Curd[T]{baseDB: tx}).CreateBatch(rows, size)
+		for _, err := range errs {
+			if err != nil {
+				return errRolledBack
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRolledBack) {
+		return repeatError(err, len(rows))
+	}
+	return errs
+}
+
+// UpsertBatch 按 size 分批插入，conflict 字段冲突时更新 columns 字段，模型带版本号时版本号加1
+func (c *Curd[T]) UpsertBatch(rows []*T, conflict []string, columns []string, size int) []error {
+	var t T
+	stmt := &gorm.Statement{DB: c.conn()}
+	if err := stmt.Parse(&t); err != nil {
+		return repeatError(err, len(rows))
+	}
+
+	onConflict := clause.OnConflict{DoUpdates: clause.AssignmentColumns(columns)}
+	for _, name := range conflict {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: name})
+	}
+	if stmt.Schema.LookUpField("version") != nil {
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+			Column: clause.Column{Name: "version"},
+			Value:  gorm.Expr("? + 1", clause.Column{Table: stmt.Schema.Table, Name: "version"}),
+		})
+	}
+
+	return c.batch(rows, size, func(db *gorm.DB, chunk []*T) error {
+		return db.Clauses(onConflict).Create(chunk).Error
+	})
+}
+
+// DeleteByIDs 按主键分批删除，返回与 ids 一一对应的错误，不存在的主键返回 gorm.ErrRecordNotFound
+// ids 的类型需与主键字段类型一致（如 uuid.UUID）
+func (c *Curd[T]) DeleteByIDs(ids []interface{}, size int) []error {
+	var t T
+	errs := make([]error, len(ids))
+	stmt := &gorm.Statement{DB: c.conn()}
+	if err := stmt.Parse(&t); err != nil {
+		return repeatError(err, len(ids))
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return repeatError(gorm.ErrPrimaryKeyRequired, len(ids))
+	}
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+
+	db := c.localDB
+	if db == nil {
+		db = c.conn().Model(&t)
+	}
+	for start := 0; start < len(ids); start += size {
+		end := min(start+size, len(ids))
+		chunk := ids[start:end]
+
+		// 先查出存在的主键，再删除，以便逐个返回结果
+		var found []T
+		if err := db.Session(&gorm.Session{}).Select(pk.DBName).Where(clause.IN{Column: clause.Column{Name: pk.DBName}, Values: chunk}).Find(&found).Error; err != nil {
+			copy(errs[start:end], repeatError(err, len(chunk)))
+			continue
+		}
+		existing := map[interface{}]bool{}
+		for i := range found {
+			value, _ := pk.ValueOf(context.Background(), reflect.ValueOf(&found[i]).Elem())
+			existing[value] = true
+		}
+
+		var deleting []interface{}
+		for i, id := range chunk {
+			if existing[id] {
+				deleting = append(deleting, id)
+			} else {
+				errs[start+i] = gorm.ErrRecordNotFound
+			}
+		}
+		if len(deleting) == 0 {
+			continue
+		}
+		if err := db.Session(&gorm.Session{}).Where(clause.IN{Column: clause.Column{Name: pk.DBName}, Values: deleting}).Delete(&t).Error; err != nil {
+			for i, id := range chunk {
+				if existing[id] {
+					errs[start+i] = err
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// batch 分批执行 fn，失败的批次在保存点内逐行重试
+func (c *Curd[T]) batch(rows []*T, size int, fn func(db *gorm.DB, chunk []*T) error) []error {
+	errs := make([]error, len(rows))
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	db := c.localDB
+	if db == nil {
+		db = c.conn()
+	}
+
+	for start := 0; start < len(rows); start += size {
+		end := min(start+size, len(rows))
+		chunk := rows[start:end]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return fn(tx, chunk)
+		})
+		if err == nil {
+			continue
+		}
+		for i, row := range chunk {
+			errs[start+i] = db.Transaction(func(tx *gorm.DB) error {
+				return fn(tx, []*T{row})
+			})
+		}
+	}
+	return errs
+}
+
+func repeatError(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
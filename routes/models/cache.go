@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"tuxiaocao/pkg/logger"
+	"tuxiaocao/pkg/platform/database"
+	redis2 "tuxiaocao/pkg/platform/redis"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// cacheTTLs 表名 => 缓存时间，未设置的模型不缓存
+var cacheTTLs sync.Map
+
+// cacheGroup 合并同一个 key 的并发未命中，只查一次数据库
+var cacheGroup singleflight.Group
+
+// SetCacheTTL 设置模型 T 的缓存时间，ttl 为0时关闭缓存
+func SetCacheTTL[T any](ttl time.Duration) {
+	table := ColumnsOf[T]().schema.Table
+	if ttl <= 0 {
+		cacheTTLs.Delete(table)
+		return
+	}
+	cacheTTLs.Store(table, ttl)
+}
+
+// Cached 开启读缓存：Take、List 先查 Redis，未命中时查主库并回写
+// 缓存按模型和查询语句的哈希区分，模型写入后由 CachePlugin 使该模型的缓存全部失效；事务内不走缓存
+// 事务内的写入在提交前即失效，提交前的并发读可能回写旧数据，最长保留一个 TTL
+func (c *Curd[T]) Cached() *Curd[T] {
+	c.cached = true
+	return c
+}
+
+// cacheTTL 当前查询能否走缓存及缓存时间
+func (c *Curd[T]) cacheTTL() (time.Duration, bool) {
+	if !c.cached || redis2.Rds == nil {
+		return 0, false
+	}
+	if _, ok := c.conn().Statement.ConnPool.(gorm.TxCommitter); ok {
+		return 0, false
+	}
+	ttl, ok := cacheTTLs.Load(ColumnsOf[T]().schema.Table)
+	if !ok {
+		return 0, false
+	}
+	return ttl.(time.Duration), true
+}
+
+// cacheKey 查询语句的哈希
+func cacheKey(sql string) string {
+	sum := sha1.Sum([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheGenerationKey 模型缓存的代数，写入后加1，旧代数的缓存不再被读取并随 TTL 过期
+func cacheGenerationKey(table string) string {
+	return "curd:" + table + ":gen"
+}
+
+// cacheLoadTimeout 合并后的 load 的超时，load 由所有等待的调用方共享，不随其中某个调用方的 ctx 取消
+const cacheLoadTimeout = 10 * time.Second
+
+// cacheLoad 从 Redis 读取 key，未命中时调用 load 并回写；Redis 不可用时直接调用 load，并发调用同样只执行一次
+// ctx 取消时调用方立即返回，load 继续执行，供其他调用方使用
+func cacheLoad[V any](ctx context.Context, table, key string, ttl time.Duration, load func(ctx context.Context) (V, error)) (V, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	generation, err := redis2.Rds.Get(ctx, cacheGenerationKey(table)).Result()
+	available := err == nil || err == redis.Nil
+	if !available {
+		logger.Log.Warnf("cache of %s is unavailable %v", table, err)
+	}
+	key = fmt.Sprintf("curd:%s:%s:%s", table, generation, key)
+
+	if available {
+		if raw, err := redis2.Rds.Get(ctx, key).Bytes(); err == nil {
+			var value V
+			if err = json.Unmarshal(raw, &value); err == nil {
+				return value, nil
+			}
+		}
+	}
+
+	loaded := cacheGroup.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheLoadTimeout)
+		defer cancel()
+		value, err := load(ctx)
+		if err != nil || !available {
+			return value, err
+		}
+		if raw, err := json.Marshal(value); err == nil {
+			if err = redis2.Rds.Set(ctx, key, raw, ttl).Err(); err != nil {
+				logger.Log.Warnf("cache %s error %v", key, err)
+			}
+		}
+		return value, nil
+	})
+	select {
+	case <-ctx.Done():
+		var value V
+		return value, ctx.Err()
+	case result := <-loaded:
+		return result.Val.(V), result.Err
+	}
+}
+
+// cachedPage List 的缓存内容
+type cachedPage[T any] struct {
+	Data  []T   `json:"data"`
+	Total int64 `json:"total"`
+}
+
+// CachePlugin GORM 插件，Create、Update、Delete 以及带表名的原生写语句（db.Table(name).Exec）执行后使对应模型的缓存失效
+// 不带表名的原生写语句（如 Exec("UPDATE ? ...", clause.Table{...})）无法确定表，写入后需调用 invalidateCache
+type CachePlugin struct{}
+
+func (CachePlugin) Name() string {
+	return "curd_cache"
+}
+
+func (p CachePlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().After("gorm:create").Register("cache:invalidate", p.invalidate),
+		callbacks.Update().After("gorm:update").Register("cache:invalidate", p.invalidate),
+		callbacks.Delete().After("gorm:delete").Register("cache:invalidate", p.invalidate),
+		callbacks.Raw().After("gorm:raw").Register("cache:invalidate", p.invalidate),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (CachePlugin) invalidate(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	invalidateCache(db.Statement.Context, db.Statement.Table)
+}
+
+// invalidateCache 使 table 的缓存全部失效，未开启缓存或 Redis 未连接时不做处理
+func invalidateCache(ctx context.Context, table string) {
+	if redis2.Rds == nil || table == "" {
+		return
+	}
+	if _, ok := cacheTTLs.Load(table); !ok {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := redis2.Rds.Incr(context.WithoutCancel(ctx), cacheGenerationKey(table)).Err(); err != nil {
+		logger.Log.Errorf("invalidate cache of %s error %v", table, err)
+	}
+}
+
+// primary 缓存未命中时从主库读取，避免把从库的旧数据写入缓存
+func primary(db *gorm.DB) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(database.WithPrimary(ctx))
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tuxiaocao/pkg/logger"
+	"tuxiaocao/pkg/platform/database"
+	redis2 "tuxiaocao/pkg/platform/redis"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	// Log to stdout instead of files.
+	logger.Replace(logger.New("", "", "debug", time.Hour, time.Hour, 100))
+	os.Exit(m.Run())
+}
+
+func TestCacheLoadCollapsesMisses(t *testing.T) {
+	// Redis is down, so every call is a miss.
+	redis2.Rds = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer func() {
+		redis2.Rds = nil
+	}()
+
+	var loads atomic.Int32
+	load := func(context.Context) (Product, error) {
+		loads.Add(1)
+		time.Sleep(200 * time.Millisecond)
+		return Product{Title: "title"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			product, err := cacheLoad(context.Background(), "products", "key", time.Minute, load)
+			assert.NoError(t, err)
+			assert.Equal(t, "title", product.Title)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+func TestCacheLoadOutlivesCancelledCaller(t *testing.T) {
+	openCache(t)
+	started := make(chan struct{})
+	load := func(ctx context.Context) (Product, error) {
+		close(started)
+		select {
+		case <-time.After(100 * time.Millisecond):
+			return Product{Title: "title"}, nil
+		case <-ctx.Done():
+			return Product{}, ctx.Err()
+		}
+	}
+
+	// The first caller gives up, the shared load goes on for the second one.
+	first, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := cacheLoad(first, "products", "key", time.Minute, load)
+		done <- err
+	}()
+	<-started
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	product, err := cacheLoad(context.Background(), "products", "key", time.Minute, load)
+	require.NoError(t, err)
+	assert.Equal(t, "title", product.Title)
+}
+
+// openCache opens a tenant database with the cache plugin and caches products in an in-memory Redis.
+func openCache(t *testing.T) *miniredis.Miniredis {
+	openTenantDB(t)
+	require.NoError(t, database.DB.Use(CachePlugin{}))
+	server := miniredis.RunT(t)
+	redis2.Rds = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	SetCacheTTL[Product](time.Minute)
+	t.Cleanup(func() {
+		redis2.Rds = nil
+		SetCacheTTL[Product](0)
+	})
+	return server
+}
+
+func TestBackfillTenantInvalidatesCache(t *testing.T) {
+	openCache(t)
+	ctx := WithTenant(context.Background(), DefaultTenant())
+	require.NoError(t, NewGormProductStore().Create(WithTenant(context.Background(), "x"), &Product{ID: uuid.New(), Title: "legacy"}))
+	require.NoError(t, database.DB.Exec("UPDATE products SET tenant_id = ''").Error)
+
+	_, total, err := NewProductRepo().WithContext(ctx).Cached().List(NewOP())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+
+	// The raw update of the backfill has no table for the plugin, the backfill invalidates the cache itself.
+	require.NoError(t, BackfillTenant(context.Background(), DefaultTenant()))
+	_, total, err = NewProductRepo().WithContext(ctx).Cached().List(NewOP())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+}
+
+func TestCachedProductStore(t *testing.T) {
+	server := openCache(t)
+	a := WithTenant(context.Background(), "a")
+	b := WithTenant(context.Background(), "b")
+	store := NewGormProductStore()
+	product := &Product{ID: uuid.New(), Title: "go"}
+	require.NoError(t, store.Create(a, product))
+
+	titles := func(ctx context.Context) []string {
+		products, _, err := store.List(ctx, &Query{})
+		require.NoError(t, err)
+		titles := []string{}
+		for _, product := range products {
+			titles = append(titles, product.Title)
+		}
+		return titles
+	}
+	title := func(id uuid.UUID) string {
+		found, err := store.Get(a, id)
+		require.NoError(t, err)
+		return found.Title
+	}
+
+	// The second read is a hit, a raw write without table isn't seen.
+	assert.Equal(t, []string{"go"}, titles(a))
+	assert.Equal(t, "go", title(product.ID))
+	require.NoError(t, database.DB.Exec("UPDATE products SET title = 'raw'").Error)
+	assert.Equal(t, []string{"go"}, titles(a))
+	assert.Equal(t, "go", title(product.ID))
+
+	// Each tenant has its own keys.
+	keys := len(server.Keys())
+	assert.Empty(t, titles(b))
+	assert.Greater(t, len(server.Keys()), keys)
+	assert.Equal(t, []string{"go"}, titles(a))
+
+	// Create, Updates and Delete invalidate the cache of the model.
+	other := &Product{ID: uuid.New(), Title: "rust"}
+	require.NoError(t, store.Create(a, other))
+	assert.ElementsMatch(t, []string{"raw", "rust"}, titles(a))
+	require.NoError(t, store.Update(a, &Product{ID: other.ID, Title: "zig"}))
+	assert.Equal(t, "zig", title(other.ID))
+	require.NoError(t, store.Delete(a, product.ID))
+	assert.Equal(t, []string{"zig"}, titles(a))
+	_, err := store.Get(a, product.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Writes of another tenant invalidate the cache as well, their rows stay invisible.
+	require.NoError(t, store.Create(b, &Product{ID: uuid.New(), Title: "other"}))
+	assert.Equal(t, []string{"zig"}, titles(a))
+	assert.Equal(t, []string{"other"}, titles(b))
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// maxCategoryDepth 分类树的最大层数（根分类为第 0 层）
+const maxCategoryDepth = 8
+
+// 标签限制
+const (
+	maxTagLength     = 64
+	maxTagsOfProduct = 20
+)
+
+var (
+	// ErrCategoryNotFound 分类（或父分类）不存在
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrCategoryNotEmpty 分类还有子分类，不能删除
+	ErrCategoryNotEmpty = errors.New("category has subcategories, move or delete them first")
+	// ErrCategoryCycle 分类不能移动到自身或子孙分类下
+	ErrCategoryCycle = errors.New("category can't be moved below itself")
+	// ErrCategoryTooDeep 超过最大层数
+	ErrCategoryTooDeep = errors.New("category tree is too deep")
+	// ErrSlugTaken 同一租户内 slug 已被使用
+	ErrSlugTaken = errors.New("slug is already used by another category")
+	// ErrInvalidTag 标签为空、过长、包含逗号或数量过多
+	ErrInvalidTag = errors.New("tags must have 1 to 64 characters without commas, at most 20 per product")
+)
+
+// Category struct to describe a node of the category tree.
+// Path is the materialized path of IDs from the root, e.g. /<root>/<parent>/<id>/,
+// so descendants of a category are all categories, whose path starts with its path.
+type Category struct {
+	ID        uuid.UUID  `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string     `gorm:"column:tenant_id;size:64;index;uniqueIndex:idx_categories_slug,priority:1" json:"tenant_id"`
+	ParentID  *uuid.UUID `gorm:"column:parent_id;type:char(36);index" json:"parent_id"`
+	Name      string     `gorm:"column:name;size:255" json:"name" validate:"required,lte=255"`
+	Slug      string     `gorm:"column:slug;size:255;uniqueIndex:idx_categories_slug,priority:2" json:"slug" validate:"lte=255"`
+	Path      string     `gorm:"column:path;size:512;index" json:"path"`
+	Depth     int        `gorm:"column:depth" json:"depth"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+type CategoryRepo struct {
+	Curd[Category]
+}
+
+func NewCategoryRepo() *CategoryRepo {
+	return &CategoryRepo{}
+}
+
+// ProductCategory struct to describe the link of a product to a category.
+type ProductCategory struct {
+	ID         uuid.UUID `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID   string    `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ProductID  uuid.UUID `gorm:"column:product_id;type:char(36);not null;uniqueIndex:idx_product_categories_link,priority:1" json:"product_id"`
+	CategoryID uuid.UUID `gorm:"column:category_id;type:char(36);not null;uniqueIndex:idx_product_categories_link,priority:2;index" json:"category_id"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// Tag struct to describe a free-form tag, the name is unique in a tenant.
+type Tag struct {
+	ID        uuid.UUID `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string    `gorm:"column:tenant_id;size:64;index;uniqueIndex:idx_tags_name,priority:1" json:"tenant_id"`
+	Name      string    `gorm:"column:name;size:64;uniqueIndex:idx_tags_name,priority:2" json:"name"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// ProductTag struct to describe the link of a product to a tag.
+type ProductTag struct {
+	ID        uuid.UUID `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string    `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ProductID uuid.UUID `gorm:"column:product_id;type:char(36);not null;uniqueIndex:idx_product_tags_link,priority:1" json:"product_id"`
+	TagID     uuid.UUID `gorm:"column:tag_id;type:char(36);not null;uniqueIndex:idx_product_tags_link,priority:2;index" json:"tag_id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// CategoryNode 分类树的节点
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// Slugify 名称转换为 slug：小写，字母和数字以外的字符合并为 -
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// place 按父分类计算路径和层数，parent 为 nil 时为根分类
+func (c *Category) place(parent *Category) error {
+	c.Path, c.Depth, c.ParentID = "/"+c.ID.String()+"/", 0, nil
+	if parent != nil {
+		if parent.Depth+1 > maxCategoryDepth {
+			return ErrCategoryTooDeep
+		}
+		id := parent.ID
+		c.Path, c.Depth, c.ParentID = parent.Path+c.ID.String()+"/", parent.Depth+1, &id
+	}
+	return nil
+}
+
+// Ancestors 祖先分类的 ID，根分类在前
+func (c Category) Ancestors() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, part := range strings.Split(strings.Trim(c.Path, "/"), "/") {
+		if id, err := uuid.Parse(part); err == nil && id != c.ID {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// prepareCategory 补全 ID 和 slug
+func prepareCategory(category *Category) {
+	if category.ID == uuid.Nil {
+		category.ID = uuid.New()
+	}
+	category.Name = strings.TrimSpace(category.Name)
+	category.Slug = Slugify(category.Slug)
+	if category.Slug == "" {
+		category.Slug = Slugify(category.Name)
+	}
+	if category.Slug == "" {
+		category.Slug = category.ID.String()
+	}
+}
+
+// moveCategory 计算移动后分类及其子孙分类（按路径排序的 subtree，包括分类本身）的路径，返回需要更新的分类
+func moveCategory(subtree []Category, parent *Category) ([]Category, error) {
+	if len(subtree) == 0 {
+		return nil, ErrCategoryNotFound
+	}
+	root := subtree[0]
+	if parent != nil && strings.HasPrefix(parent.Path, root.Path) {
+		return nil, ErrCategoryCycle
+	}
+	moved := root
+	if err := moved.place(parent); err != nil {
+		return nil, err
+	}
+	result := []Category{moved}
+	for _, child := range subtree[1:] {
+		child.Path = moved.Path + strings.TrimPrefix(child.Path, root.Path)
+		child.Depth += moved.Depth - root.Depth
+		if child.Depth > maxCategoryDepth {
+			return nil, ErrCategoryTooDeep
+		}
+		result = append(result, child)
+	}
+	return result, nil
+}
+
+// CategoryTree 按路径排序的分类组装为树
+func CategoryTree(categories []Category) []*CategoryNode {
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Path < categories[j].Path })
+	nodes := map[uuid.UUID]*CategoryNode{}
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := &CategoryNode{Category: category, Children: []*CategoryNode{}}
+		nodes[category.ID] = node
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots
+}
+
+// NormalizeTags 标签去除首尾空白、转为小写并去重，连续空白合并为一个空格
+func NormalizeTags(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range names {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if name == "" || len([]rune(name)) > maxTagLength || strings.Contains(name, ",") {
+			return nil, ErrInvalidTag
+		}
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	if len(tags) > maxTagsOfProduct {
+		return nil, ErrInvalidTag
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// matchProducts 同时满足分类条件（categoryLinks 中任一分类）和全部 tagCount 个标签的商品
+func matchProducts(byCategory bool, categoryLinks []ProductCategory, tagCount int, tagLinks []ProductTag) []uuid.UUID {
+	inCategory := map[uuid.UUID]bool{}
+	for _, link := range categoryLinks {
+		inCategory[link.ProductID] = true
+	}
+	tagged := map[uuid.UUID]int{}
+	for _, link := range tagLinks {
+		tagged[link.ProductID]++
+	}
+
+	var candidates map[uuid.UUID]bool
+	if byCategory {
+		candidates = inCategory
+	} else {
+		candidates = map[uuid.UUID]bool{}
+		for id := range tagged {
+			candidates[id] = true
+		}
+	}
+	ids := []uuid.UUID{}
+	for id := range candidates {
+		if tagCount == 0 || tagged[id] == tagCount {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
+
+// Taxonomy 商品属于分类（包括子孙分类）并带有全部标签的条件，由 CategoryStore.Taxonomy 生成，通过 Query.Taxonomy 生效
+type Taxonomy struct {
+	path     string             // 分类的路径，为空时不限分类
+	tags     []string           // 规范化后的标签
+	products map[uuid.UUID]bool // 内存存储中满足条件的商品
+}
+
+// expressions 数据库中的条件，用 EXISTS 子查询关联 table 的商品
+func (t *Taxonomy) expressions(table string) []clause.Expression {
+	var expressions []clause.Expression
+	if t.path != "" {
+		expressions = append(expressions, clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM product_categories pc JOIN categories c ON c.id = pc.category_id WHERE pc.product_id = ?.id AND c.path LIKE ? ESCAPE '!')",
+			Vars: []interface{}{clause.Table{Name: table}, likePrefix(t.path)},
+		})
+	}
+	for _, tag := range t.tags {
+		expressions = append(expressions, clause.Expr{
+			SQL:  "EXISTS (SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.product_id = ?.id AND t.name = ?)",
+			Vars: []interface{}{clause.Table{Name: table}, tag},
+		})
+	}
+	return expressions
+}
+
+// contains 内存存储中 id 是否满足条件
+func (t *Taxonomy) contains(id interface{}) bool {
+	productID, _ := id.(uuid.UUID)
+	return t.products[productID]
+}
+
+// likeEscaper 用 ! 转义 LIKE 通配符（MySQL 字符串中的 \ 本身需要转义，! 在各数据库中一致），条件需带 ESCAPE '!'
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// likePrefix 前缀匹配的 LIKE 模式
+func likePrefix(prefix string) string {
+	return likeEscaper.Replace(prefix) + "%"
+}
+
+// likeContains 包含匹配的 LIKE 模式
+func likeContains(term string) string {
+	return "%" + likeEscaper.Replace(term) + "%"
+}
+
+// normalizeTagPrefix 自动补全的前缀，与 NormalizeTags 的规则一致
+func normalizeTagPrefix(prefix string) string {
+	name := strings.ToLower(strings.Join(strings.Fields(prefix), " "))
+	if strings.HasSuffix(prefix, " ") && name != "" {
+		name += " "
+	}
+	return name
+}
+
+// uniqueIDs 去重，保持顺序
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
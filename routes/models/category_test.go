@@ -0,0 +1,204 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestSlugifyAndNormalizeTags(t *testing.T) {
+	assert.Equal(t, "science-fiction", Slugify("  Science   Fiction! "))
+	assert.Equal(t, "小说-2024", Slugify("小说 2024"))
+	assert.Equal(t, "", Slugify("!!!"))
+
+	tests := []struct {
+		names []string
+		tags  []string
+		err   bool
+	}{
+		{[]string{" Go ", "go", "Web  Dev"}, []string{"go", "web dev"}, false},
+		{[]string{}, []string{}, false},
+		{[]string{" "}, nil, true},
+		{[]string{"a,b"}, nil, true},
+		{[]string{string(make([]rune, 65))}, nil, true},
+	}
+	for _, test := range tests {
+		tags, err := NormalizeTags(test.names)
+		if test.err {
+			assert.ErrorIs(t, err, ErrInvalidTag, test.names)
+			continue
+		}
+		require.NoError(t, err, test.names)
+		assert.Equal(t, test.tags, tags, test.names)
+	}
+}
+
+func TestCategoryStore(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	other := WithTenant(context.Background(), "b")
+
+	stores := map[string]struct {
+		CategoryStore
+		products ProductStore
+	}{
+		"gorm":   {NewGormCategoryStore(), NewGormProductStore()},
+		"memory": {NewMemoryCategoryStore(), NewMemoryProductStore()},
+	}
+	for name, store := range stores {
+		// listed lists the IDs of the products matching the taxonomy.
+		listed := func(category *Category, tags ...string) []uuid.UUID {
+			taxonomy, err := store.Taxonomy(ctx, category, tags)
+			require.NoError(t, err, name)
+			products, _, err := store.products.List(ctx, &Query{Taxonomy: taxonomy})
+			require.NoError(t, err, name)
+			ids := []uuid.UUID{}
+			for _, product := range products {
+				ids = append(ids, product.ID)
+			}
+			return ids
+		}
+
+		// books → fiction → fantasy, music
+		books := &Category{Name: "Books"}
+		require.NoError(t, store.CreateCategory(ctx, books), name)
+		fiction := &Category{Name: "Fiction", ParentID: &books.ID}
+		require.NoError(t, store.CreateCategory(ctx, fiction), name)
+		fantasy := &Category{Name: "Fantasy", ParentID: &fiction.ID}
+		require.NoError(t, store.CreateCategory(ctx, fantasy), name)
+		music := &Category{Name: "Music"}
+		require.NoError(t, store.CreateCategory(ctx, music), name)
+		assert.Equal(t, "books", books.Slug, name)
+		assert.Equal(t, 2, fantasy.Depth, name)
+		assert.Equal(t, []uuid.UUID{books.ID, fiction.ID}, fantasy.Ancestors(), name)
+
+		missing := uuid.New()
+		assert.ErrorIs(t, store.CreateCategory(ctx, &Category{Name: "x", ParentID: &missing}), ErrCategoryNotFound, name)
+		assert.ErrorIs(t, store.CreateCategory(ctx, &Category{Name: "BOOKS"}), ErrSlugTaken, name)
+		// Slugs are unique per tenant.
+		require.NoError(t, store.CreateCategory(other, &Category{Name: "Books"}), name)
+
+		found, err := store.Category(ctx, "Fantasy")
+		require.NoError(t, err, name)
+		assert.Equal(t, fantasy.ID, found.ID, name)
+		_, err = store.Category(other, fiction.ID.String())
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, name)
+
+		// Products: p1 in fantasy with go, web; p2 in books with go; p3 in music with web.
+		p1, p2, p3 := uuid.New(), uuid.New(), uuid.New()
+		for _, id := range []uuid.UUID{p1, p2, p3, uuid.New()} {
+			require.NoError(t, store.products.Create(ctx, &Product{ID: id, Title: "product"}), name)
+		}
+		require.NoError(t, store.SetProductCategories(ctx, p1, []uuid.UUID{fantasy.ID, fantasy.ID}), name)
+		require.NoError(t, store.SetProductCategories(ctx, p2, []uuid.UUID{books.ID}), name)
+		require.NoError(t, store.SetProductCategories(ctx, p3, []uuid.UUID{music.ID}), name)
+		assert.ErrorIs(t, store.SetProductCategories(ctx, p3, []uuid.UUID{missing}), ErrCategoryNotFound, name)
+		tags, err := store.SetProductTags(ctx, p1, []string{"Web", "go", "GO"})
+		require.NoError(t, err, name)
+		assert.Equal(t, []string{"go", "web"}, tags, name)
+		_, err = store.SetProductTags(ctx, p2, []string{"go"})
+		require.NoError(t, err, name)
+		_, err = store.SetProductTags(ctx, p3, []string{"web", "golang"})
+		require.NoError(t, err, name)
+
+		categories, err := store.ProductCategories(ctx, p1)
+		require.NoError(t, err, name)
+		require.Len(t, categories, 1, name)
+		assert.Equal(t, fantasy.ID, categories[0].ID, name)
+		tags, err = store.ProductTags(ctx, p3)
+		require.NoError(t, err, name)
+		assert.Equal(t, []string{"golang", "web"}, tags, name)
+
+		completions, err := store.Tags(ctx, "Go", 10)
+		require.NoError(t, err, name)
+		require.Len(t, completions, 2, name)
+		assert.Equal(t, "go", completions[0].Name, name)
+		assert.Equal(t, "golang", completions[1].Name, name)
+		completions, err = store.Tags(ctx, "%", 10)
+		require.NoError(t, err, name)
+		assert.Empty(t, completions, name)
+		completions, err = store.Tags(other, "go", 10)
+		require.NoError(t, err, name)
+		assert.Empty(t, completions, name)
+
+		// Categories include their descendants, all tags must match.
+		assert.ElementsMatch(t, []uuid.UUID{p1, p2}, listed(books), name)
+		assert.Equal(t, []uuid.UUID{p1}, listed(fiction), name)
+		assert.ElementsMatch(t, []uuid.UUID{p1, p3}, listed(nil, "web"), name)
+		assert.Equal(t, []uuid.UUID{p1}, listed(books, "go", "Web"), name)
+		assert.Empty(t, listed(nil, "go", "unknown"), name)
+		_, err = store.Taxonomy(ctx, nil, []string{"go", ""})
+		assert.ErrorIs(t, err, ErrInvalidTag, name)
+
+		// Move fiction below music, its subtree follows.
+		fiction.ParentID = &music.ID
+		require.NoError(t, store.UpdateCategory(ctx, fiction), name)
+		assert.Equal(t, "/"+music.ID.String()+"/"+fiction.ID.String()+"/", fiction.Path, name)
+		moved, err := store.Category(ctx, fantasy.ID.String())
+		require.NoError(t, err, name)
+		assert.Equal(t, fiction.Path+fantasy.ID.String()+"/", moved.Path, name)
+		assert.ElementsMatch(t, []uuid.UUID{p1, p3}, listed(music), name)
+
+		// A category can't be moved below itself.
+		fiction.ParentID = &fantasy.ID
+		assert.ErrorIs(t, store.UpdateCategory(ctx, fiction), ErrCategoryCycle, name)
+		fiction.ParentID = &fiction.ID
+		assert.ErrorIs(t, store.UpdateCategory(ctx, fiction), ErrCategoryCycle, name)
+
+		// Rename a root.
+		music.Name, music.Slug = "Songs", ""
+		require.NoError(t, store.UpdateCategory(ctx, music), name)
+		all, err := store.Categories(ctx)
+		require.NoError(t, err, name)
+		tree := CategoryTree(all)
+		roots := map[string]*CategoryNode{}
+		for _, node := range tree {
+			roots[node.Slug] = node
+		}
+		require.Len(t, roots, 2, name)
+		require.NotNil(t, roots["songs"], name)
+		require.Len(t, roots["songs"].Children, 1, name)
+		assert.Equal(t, "fiction", roots["songs"].Children[0].Slug, name)
+		assert.Equal(t, "fantasy", roots["songs"].Children[0].Children[0].Slug, name)
+
+		// Only leaves can be deleted, the links of products are removed.
+		assert.ErrorIs(t, store.DeleteCategory(ctx, fiction.ID), ErrCategoryNotEmpty, name)
+		require.NoError(t, store.DeleteCategory(ctx, fantasy.ID), name)
+		categories, err = store.ProductCategories(ctx, p1)
+		require.NoError(t, err, name)
+		assert.Empty(t, categories, name)
+		assert.ErrorIs(t, store.DeleteCategory(ctx, fantasy.ID), gorm.ErrRecordNotFound, name)
+	}
+}
+
+func TestPurgeDeletedTaxonomy(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	products, categories := NewGormProductStore(), NewGormCategoryStore()
+
+	books := &Category{Name: "Books"}
+	require.NoError(t, categories.CreateCategory(ctx, books))
+	purged, kept := &Product{ID: uuid.New(), Title: "purged"}, &Product{ID: uuid.New(), Title: "kept"}
+	for _, product := range []*Product{purged, kept} {
+		require.NoError(t, products.Create(ctx, product))
+		require.NoError(t, categories.SetProductCategories(ctx, product.ID, []uuid.UUID{books.ID}))
+		_, err := categories.SetProductTags(ctx, product.ID, []string{"go"})
+		require.NoError(t, err)
+	}
+
+	// The category and tag links of the purged product go with it, the category and the tag stay.
+	purgeProduct(t, ctx, purged.ID)
+	for _, table := range []string{"product_categories", "product_tags"} {
+		assert.Equal(t, int64(0), countRows(t, table, "product_id = ?", purged.ID), table)
+		assert.Equal(t, int64(1), countRows(t, table, "product_id = ?", kept.ID), table)
+	}
+	tags, err := categories.Tags(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+	_, err = categories.Category(ctx, books.ID.String())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommentStatus 评论的审核状态，新评论和修改后的评论直接可见，等待审核
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentHidden   CommentStatus = "hidden"
+)
+
+// 删除或隐藏的评论在讨论串中的占位内容，回复保留
+const (
+	CommentDeletedText = "[deleted]"
+	CommentHiddenText  = "[hidden]"
+)
+
+// CommentReplyPreview 讨论串列表中每个讨论串预览的回复数
+const CommentReplyPreview = 3
+
+// 讨论串最新的在前，回复和审核队列按时间顺序，相同时按 ID 排序保证分页稳定
+const (
+	commentThreadOrder = "created_at desc, id"
+	commentReplyOrder  = "created_at, id"
+)
+
+var (
+	// ErrParentNotFound 回复的评论不存在或不属于该商品
+	ErrParentNotFound = errors.New("parent comment not found on this product")
+	// ErrCommentRemoved 评论已删除或被隐藏，不能修改、回复或审核
+	ErrCommentRemoved = errors.New("comment is deleted or hidden")
+	// ErrNotCommentAuthor 只有作者能修改或删除自己的评论
+	ErrNotCommentAuthor = errors.New("only the author can change the comment")
+	// ErrEditWindowClosed 超过了评论的修改期限
+	ErrEditWindowClosed = errors.New("edit window of the comment has passed")
+	// ErrInvalidCommentStatus 审核只能通过或隐藏评论
+	ErrInvalidCommentStatus = errors.New("unknown status of comments, use approved or hidden")
+)
+
+// Comment struct to describe a comment (tucao) on a product, replies point to their parent and the top-level comment of the thread.
+type Comment struct {
+	ID          uuid.UUID     `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID    string        `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ProductID   uuid.UUID     `gorm:"column:product_id;type:char(36);not null;index" json:"product_id"`
+	ParentID    *uuid.UUID    `gorm:"column:parent_id;type:char(36)" json:"parent_id"`
+	RootID      uuid.UUID     `gorm:"column:root_id;type:char(36);not null;index" json:"root_id"`
+	UserID      string        `gorm:"column:user_id;size:64;not null" json:"user_id"`
+	Text        string        `gorm:"column:text" json:"text"`
+	Status      CommentStatus `gorm:"column:status;size:16;not null;default:pending;index" json:"status"`
+	ModeratedBy string        `gorm:"column:moderated_by;size:64" json:"moderated_by,omitempty"`
+	ModeratedAt *time.Time    `gorm:"column:moderated_at" json:"moderated_at,omitempty"`
+	EditedAt    *time.Time    `gorm:"column:edited_at" json:"edited_at"`
+	DeletedAt   *time.Time    `gorm:"column:deleted_at" json:"deleted_at"`
+	CreatedAt   time.Time     `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time     `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+type CommentRepo struct {
+	Curd[Comment]
+}
+
+func NewCommentRepo() *CommentRepo {
+	return &CommentRepo{}
+}
+
+// CommentThread struct to describe a top-level comment with the first replies of its thread.
+type CommentThread struct {
+	Comment
+	Replies    []Comment `json:"replies"`
+	ReplyCount int64     `json:"reply_count"`
+}
+
+// Removed 评论已删除或被隐藏
+func (c *Comment) Removed() bool {
+	return c.DeletedAt != nil || c.Status == CommentHidden
+}
+
+// Editable 作者在修改期限（window 大于0时）内可以修改未删除、未隐藏的评论
+func (c *Comment) Editable(userID string, now time.Time, window time.Duration) error {
+	if c.UserID != userID {
+		return ErrNotCommentAuthor
+	}
+	if c.Removed() {
+		return ErrCommentRemoved
+	}
+	if window > 0 && now.Sub(c.CreatedAt) > window {
+		return ErrEditWindowClosed
+	}
+	return nil
+}
+
+// Redacted 公开展示的评论，删除的评论隐去内容和作者，隐藏的评论隐去内容
+func (c Comment) Redacted() Comment {
+	switch {
+	case c.DeletedAt != nil:
+		c.Text, c.UserID = CommentDeletedText, ""
+	case c.Status == CommentHidden:
+		c.Text = CommentHiddenText
+	}
+	return c
+}
+
+// RedactComments 公开展示的评论列表
+func RedactComments(comments []Comment) []Comment {
+	redacted := make([]Comment, len(comments))
+	for i, comment := range comments {
+		redacted[i] = comment.Redacted()
+	}
+	return redacted
+}
+
+// sortComments 按时间排序，newest 为真时最新的在前
+func sortComments(comments []Comment, newest bool) {
+	sort.Slice(comments, func(i, j int) bool {
+		a, b := comments[i], comments[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt) == newest
+		}
+		return a.ID.String() < b.ID.String()
+	})
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestCommentRedacted(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		comment Comment
+		text    string
+		user    string
+	}{
+		{"visible", Comment{UserID: "alice", Text: "meh", Status: CommentPending}, "meh", "alice"},
+		{"hidden", Comment{UserID: "alice", Text: "meh", Status: CommentHidden}, CommentHiddenText, "alice"},
+		{"deleted", Comment{UserID: "alice", Text: "meh", Status: CommentHidden, DeletedAt: &now}, CommentDeletedText, ""},
+	}
+	for _, test := range tests {
+		redacted := test.comment.Redacted()
+		assert.Equal(t, test.text, redacted.Text, test.name)
+		assert.Equal(t, test.user, redacted.UserID, test.name)
+		assert.Equal(t, "meh", test.comment.Text, test.name)
+	}
+}
+
+func TestCommentStore(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+
+	stores := map[string]struct {
+		products ProductStore
+		comments CommentStore
+	}{
+		"gorm":   {NewGormProductStore(), NewGormCommentStore()},
+		"memory": {memory, NewMemoryCommentStore(memory)},
+	}
+	for name, store := range stores {
+		product := &Product{ID: uuid.New(), Title: "go"}
+		require.NoError(t, store.products.Create(ctx, product), name)
+
+		// Two threads, the second with nested replies.
+		first := &Comment{ProductID: product.ID, UserID: "alice", Text: "first"}
+		require.NoError(t, store.comments.CreateComment(ctx, first), name)
+		time.Sleep(5 * time.Millisecond)
+		second := &Comment{ProductID: product.ID, UserID: "bob", Text: "second", Status: CommentApproved}
+		require.NoError(t, store.comments.CreateComment(ctx, second), name)
+		assert.Equal(t, second.ID, second.RootID, name)
+		assert.Equal(t, CommentPending, second.Status, name)
+
+		var replies []*Comment
+		parent := second
+		for i := 0; i < 4; i++ {
+			time.Sleep(5 * time.Millisecond)
+			reply := &Comment{ProductID: product.ID, ParentID: &parent.ID, UserID: "carol", Text: "reply"}
+			require.NoError(t, store.comments.CreateComment(ctx, reply), name)
+			assert.Equal(t, second.ID, reply.RootID, name)
+			replies = append(replies, reply)
+			parent = reply
+		}
+		assert.ErrorIs(t, store.comments.CreateComment(ctx, &Comment{ProductID: product.ID, ParentID: &product.ID, UserID: "carol"}), ErrParentNotFound, name)
+		assert.ErrorIs(t, store.comments.CreateComment(ctx, &Comment{ProductID: uuid.New(), UserID: "carol"}), gorm.ErrRecordNotFound, name)
+
+		threads, total, err := store.comments.Threads(ctx, product.ID, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(2), total, name)
+		require.Len(t, threads, 2, name)
+		assert.Equal(t, second.ID, threads[0].ID, name)
+		assert.Equal(t, int64(4), threads[0].ReplyCount, name)
+		require.Len(t, threads[0].Replies, CommentReplyPreview, name)
+		assert.Equal(t, replies[0].ID, threads[0].Replies[0].ID, name)
+		assert.Empty(t, threads[1].Replies, name)
+
+		page, total, err := store.comments.Replies(ctx, second.ID, 2, 3)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(4), total, name)
+		require.Len(t, page, 1, name)
+		assert.Equal(t, replies[3].ID, page[0].ID, name)
+
+		// Only authors edit their comments within the window, edits go back to the queue.
+		_, err = store.comments.Moderate(ctx, first.ID, CommentApproved, "mod")
+		require.NoError(t, err, name)
+		_, err = store.comments.UpdateComment(ctx, first.ID, "bob", "mine", time.Hour)
+		assert.ErrorIs(t, err, ErrNotCommentAuthor, name)
+		_, err = store.comments.UpdateComment(ctx, first.ID, "alice", "late", time.Millisecond)
+		assert.ErrorIs(t, err, ErrEditWindowClosed, name)
+		edited, err := store.comments.UpdateComment(ctx, first.ID, "alice", "edited", time.Hour)
+		require.NoError(t, err, name)
+		assert.Equal(t, "edited", edited.Text, name)
+		assert.Equal(t, CommentPending, edited.Status, name)
+		assert.NotNil(t, edited.EditedAt, name)
+
+		// Hidden and deleted comments stay in the thread, but can't be replied to.
+		hidden, err := store.comments.Moderate(ctx, replies[1].ID, CommentHidden, "mod")
+		require.NoError(t, err, name)
+		assert.Equal(t, "mod", hidden.ModeratedBy, name)
+		_, err = store.comments.Moderate(ctx, replies[1].ID, CommentPending, "mod")
+		assert.ErrorIs(t, err, ErrInvalidCommentStatus, name)
+		assert.ErrorIs(t, store.comments.CreateComment(ctx, &Comment{ProductID: product.ID, ParentID: &replies[1].ID, UserID: "dave"}), ErrCommentRemoved, name)
+
+		assert.ErrorIs(t, store.comments.DeleteComment(ctx, replies[0].ID, "bob", false), ErrNotCommentAuthor, name)
+		require.NoError(t, store.comments.DeleteComment(ctx, replies[0].ID, "carol", false), name)
+		assert.ErrorIs(t, store.comments.DeleteComment(ctx, replies[0].ID, "carol", false), gorm.ErrRecordNotFound, name)
+		require.NoError(t, store.comments.DeleteComment(ctx, replies[2].ID, "mod", true), name)
+		_, err = store.comments.Moderate(ctx, replies[2].ID, CommentApproved, "mod")
+		assert.ErrorIs(t, err, ErrCommentRemoved, name)
+		_, err = store.comments.UpdateComment(ctx, replies[0].ID, "carol", "back", time.Hour)
+		assert.ErrorIs(t, err, ErrCommentRemoved, name)
+
+		page, total, err = store.comments.Replies(ctx, second.ID, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(4), total, name)
+		assert.NotNil(t, page[0].DeletedAt, name)
+		assert.Equal(t, CommentDeletedText, page[0].Redacted().Text, name)
+
+		// Pending comments are queued oldest first, deleted comments are not.
+		queue, total, err := store.comments.ModerationQueue(ctx, product.ID, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(3), total, name)
+		assert.Equal(t, []uuid.UUID{first.ID, second.ID, replies[3].ID}, []uuid.UUID{queue[0].ID, queue[1].ID, queue[2].ID}, name)
+		_, total, err = store.comments.ModerationQueue(ctx, uuid.Nil, 1, 1)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(3), total, name)
+
+		// Comments are scoped by tenant.
+		_, err = store.comments.Comment(WithTenant(context.Background(), "b"), first.ID)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, name)
+	}
+}
+
+func TestPurgeDeletedComments(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	products, comments := NewGormProductStore(), NewGormCommentStore()
+
+	purged, kept := &Product{ID: uuid.New(), Title: "purged"}, &Product{ID: uuid.New(), Title: "kept"}
+	for _, product := range []*Product{purged, kept} {
+		require.NoError(t, products.Create(ctx, product))
+		root := &Comment{ProductID: product.ID, UserID: "alice", Text: "first"}
+		require.NoError(t, comments.CreateComment(ctx, root))
+		require.NoError(t, comments.CreateComment(ctx, &Comment{ProductID: product.ID, ParentID: &root.ID, UserID: "bob", Text: "reply"}))
+	}
+
+	// The threads of the purged product go with it, the others stay.
+	purgeProduct(t, ctx, purged.ID)
+	assert.Equal(t, int64(0), countRows(t, "comments", "product_id = ?", purged.ID))
+	assert.Equal(t, int64(2), countRows(t, "comments", "product_id = ?", kept.ID))
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor 游标无法解析或与当前排序不一致
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	defaultCursorLimit = 20
+	maxCursorLimit     = 100
+)
+
+// CursorPage 游标分页结果，为空表示没有更多数据
+type CursorPage struct {
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+}
+
+// cursorToken 游标内容：排序键、排序键对应的值以及翻页方向
+type cursorToken struct {
+	Sort     string            `json:"s"`
+	Values   []json.RawMessage `json:"v"`
+	Backward bool              `json:"b,omitempty"`
+}
+
+// sortKey 已校验的排序字段
+type sortKey struct {
+	field      *schema.Field
+	descending bool
+}
+
+// ListByCursor 游标（keyset）分页查询，排序取自 QueryOption.SetSorting，并自动追加主键保证顺序唯一
+func (c *Curd[T]) ListByCursor(op *QueryOption) (data []T, page *CursorPage, err error) {
+	var t T
+	if c.localDB == nil {
+		c.localDB = c.conn().Model(&t)
+	}
+	if op == nil {
+		op = NewOP()
+	}
+
+	stmt := &gorm.Statement{DB: c.conn()}
+	if err = stmt.Parse(&t); err != nil {
+		return nil, nil, err
+	}
+	keys := cursorSortKeys(stmt.Schema, op.sorting)
+	signature := cursorSignature(keys)
+
+	limit := cursorLimit(op.limit)
+
+	var token *cursorToken
+	if op.cursor != "" {
+		if token, err = decodeCursor(op.cursor, signature, len(keys)); err != nil {
+			return nil, nil, err
+		}
+	}
+	backward := token != nil && token.Backward
+
+	db := c.localDB
+	if token != nil {
+		cond, err := cursorCondition(keys, token, backward)
+		if err != nil {
+			return nil, nil, err
+		}
+		db = db.Clauses(clause.Where{Exprs: []clause.Expression{cond}})
+	}
+	// 向前翻页时反转排序，取出后再还原
+	for _, key := range keys {
+		db = db.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: key.field.DBName},
+			Desc:   key.descending != backward,
+		})
+	}
+	if err = db.Limit(limit + 1).Find(&data).Error; err != nil {
+		return nil, nil, err
+	}
+	return cursorResult(keys, signature, data, limit, token)
+}
+
+// cursorLimit 游标分页的每页数量
+func cursorLimit(limit int) int {
+	if limit <= 0 {
+		return defaultCursorLimit
+	}
+	if limit > maxCursorLimit {
+		return maxCursorLimit
+	}
+	return limit
+}
+
+// cursorResult 根据多取的一行判断是否还有数据，并生成前后页游标；data 为按翻页方向排序的 limit+1 行
+func cursorResult[T any](keys []sortKey, signature string, data []T, limit int, token *cursorToken) ([]T, *CursorPage, error) {
+	var err error
+	backward := token != nil && token.Backward
+	hasMore := len(data) > limit
+	if hasMore {
+		data = data[:limit]
+	}
+	if backward {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	page := &CursorPage{}
+	if len(data) == 0 {
+		return data, page, nil
+	}
+	first, last := data[0], data[len(data)-1]
+	if hasMore || backward {
+		if page.NextCursor, err = encodeCursor(keys, signature, &last, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	if (backward && hasMore) || (!backward && token != nil) {
+		if page.PrevCursor, err = encodeCursor(keys, signature, &first, true); err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, page, nil
+}
+
+// cursorSortKeys 只保留模型中存在的字段，并以主键兜底
+func cursorSortKeys(s *schema.Schema, sortParams []*SortParam) (keys []sortKey) {
+	seen := map[string]bool{}
+	for _, sortParam := range sortParams {
+		if sortParam == nil {
+			continue
+		}
+		field := s.LookUpField(strings.TrimSuffix(sortParam.SortBy, "_info"))
+		if field == nil || field.DBName == "" || seen[field.DBName] {
+			continue
+		}
+		seen[field.DBName] = true
+		keys = append(keys, sortKey{field: field, descending: sortParam.Descending})
+	}
+	for _, field := range s.PrimaryFields {
+		if !seen[field.DBName] {
+			keys = append(keys, sortKey{field: field, descending: true})
+		}
+	}
+	return keys
+}
+
+// cursorSignature 排序签名，防止游标在不同排序之间混用
+func cursorSignature(keys []sortKey) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		direction := "asc"
+		if key.descending {
+			direction = "desc"
+		}
+		parts = append(parts, key.field.DBName+" "+direction)
+	}
+	return strings.Join(parts, ",")
+}
+
+// cursorCondition 构造 (a > ?) OR (a = ? AND b > ?) ... 形式的 keyset 条件
+func cursorCondition(keys []sortKey, token *cursorToken, backward bool) (clause.Expression, error) {
+	values, err := cursorValues(keys, token)
+	if err != nil {
+		return nil, err
+	}
+
+	ors := make([]clause.Expression, 0, len(keys))
+	for i, key := range keys {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: clause.Column{Name: keys[j].field.DBName}, Value: values[j]})
+		}
+		column := clause.Column{Name: key.field.DBName}
+		if key.descending != backward {
+			ands = append(ands, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: column, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	return clause.Or(ors...), nil
+}
+
+// cursorValues 将游标中的值还原为字段类型
+func cursorValues(keys []sortKey, token *cursorToken) ([]interface{}, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		value := reflect.New(key.field.FieldType)
+		if err := json.Unmarshal(token.Values[i], value.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = value.Elem().Interface()
+	}
+	return values, nil
+}
+
+func encodeCursor[T any](keys []sortKey, signature string, row *T, backward bool) (string, error) {
+	token := cursorToken{Sort: signature, Backward: backward}
+	rv := reflect.ValueOf(row).Elem()
+	for _, key := range keys {
+		value, _ := key.field.ValueOf(context.Background(), rv)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		token.Values = append(token.Values, raw)
+	}
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor, signature string, size int) (*cursorToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	token := &cursorToken{}
+	if err := json.Unmarshal(raw, token); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if token.Sort != signature || len(token.Values) != size {
+		return nil, ErrInvalidCursor
+	}
+	return token, nil
+}
@@ -0,0 +1,221 @@
+package models
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// 导出格式
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportJSON   = "json"
+)
+
+// ExportFormats 支持的导出格式
+var ExportFormats = []string{ExportCSV, ExportNDJSON, ExportJSON}
+
+// ProductCSVHeader CSV 导出的列，导入使用相同的列；rating 为平均评分，和 review_count 一样在导入时忽略
+var ProductCSVHeader = []string{"id", "user_id", "title", "author", "product_status", "picture", "description", "rating", "review_count", "created_at", "updated_at", "version"}
+
+// ExportContentType 导出格式对应的 Content-Type
+func ExportContentType(format string) string {
+	switch format {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// ProductExport 按游标分批读取商品，每次只保留一批，内存占用与总数无关
+// 过滤条件和排序与列表接口一致，未指定排序时按创建时间倒序
+type ProductExport struct {
+	ctx     context.Context
+	store   ProductStore
+	q       *Query
+	cursor  string
+	pending []Product
+	done    bool
+}
+
+func NewProductExport(ctx context.Context, store ProductStore, q *Query) *ProductExport {
+	export := &ProductExport{ctx: ctx, store: store, q: &Query{Filters: q.Filters, Sorting: q.Sorting, Taxonomy: q.Taxonomy}}
+	if len(export.q.Sorting) == 0 {
+		export.q.Sorting = []*SortParam{{SortBy: "created_at", Descending: true}}
+	}
+	return export
+}
+
+// Prefetch 预先读取第一批，开始写响应前暴露非法查询等错误
+func (e *ProductExport) Prefetch() error {
+	batch, err := e.fetch()
+	e.pending = batch
+	return err
+}
+
+// Next 下一批商品，没有更多数据时返回空
+func (e *ProductExport) Next() ([]Product, error) {
+	if e.pending != nil {
+		batch := e.pending
+		e.pending = nil
+		return batch, nil
+	}
+	return e.fetch()
+}
+
+func (e *ProductExport) fetch() ([]Product, error) {
+	if e.done {
+		return nil, nil
+	}
+	batch, page, err := e.store.ListByCursor(e.ctx, e.q, e.cursor, maxCursorLimit)
+	if err != nil {
+		return nil, err
+	}
+	e.cursor = page.NextCursor
+	e.done = e.cursor == ""
+	return batch, nil
+}
+
+// WriteTo 按 format 写入全部商品，每批写完后刷新 w（如 *bufio.Writer），返回写入的行数
+func (e *ProductExport) WriteTo(w io.Writer, format string) (int64, error) {
+	var rows int64
+	writer := newExportWriter(w, format)
+	if err := writer.begin(); err != nil {
+		return rows, err
+	}
+	for {
+		batch, err := e.Next()
+		if err != nil {
+			return rows, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for i := range batch {
+			if err = writer.write(&batch[i]); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		if err = writer.flush(); err != nil {
+			return rows, err
+		}
+	}
+	if err := writer.end(); err != nil {
+		return rows, err
+	}
+	return rows, writer.flush()
+}
+
+// exportWriter 一种导出格式的写入
+type exportWriter interface {
+	begin() error
+	write(product *Product) error
+	end() error
+	flush() error
+}
+
+func newExportWriter(w io.Writer, format string) exportWriter {
+	switch format {
+	case ExportCSV:
+		return &csvExportWriter{w: w, csv: csv.NewWriter(w)}
+	case ExportNDJSON:
+		return &jsonExportWriter{w: w, lines: true}
+	}
+	return &jsonExportWriter{w: w}
+}
+
+// flushWriter 刷新带缓冲的 w
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+type csvExportWriter struct {
+	w   io.Writer
+	csv *csv.Writer
+}
+
+func (cw *csvExportWriter) begin() error {
+	return cw.csv.Write(ProductCSVHeader)
+}
+
+func (cw *csvExportWriter) write(product *Product) error {
+	return cw.csv.Write([]string{
+		product.ID.String(),
+		product.UserID,
+		product.Title,
+		product.Author,
+		product.ProductStatus.String(),
+		product.ProductAttrs.Picture,
+		product.ProductAttrs.Description,
+		strconv.FormatFloat(product.Rating.Average, 'f', -1, 64),
+		strconv.FormatInt(product.Rating.Count, 10),
+		product.CreatedAt.Format(time.RFC3339Nano),
+		product.UpdatedAt.Format(time.RFC3339Nano),
+		strconv.FormatInt(product.Version, 10),
+	})
+}
+
+func (cw *csvExportWriter) end() error {
+	return nil
+}
+
+func (cw *csvExportWriter) flush() error {
+	cw.csv.Flush()
+	if err := cw.csv.Error(); err != nil {
+		return err
+	}
+	return flushWriter(cw.w)
+}
+
+// jsonExportWriter lines 为 true 时每行一个对象（NDJSON），否则为一个数组
+type jsonExportWriter struct {
+	w     io.Writer
+	lines bool
+	count int
+}
+
+func (jw *jsonExportWriter) begin() error {
+	if jw.lines {
+		return nil
+	}
+	_, err := io.WriteString(jw.w, "[")
+	return err
+}
+
+func (jw *jsonExportWriter) write(product *Product) error {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+	switch {
+	case jw.lines:
+		data = append(data, '\n')
+	case jw.count > 0:
+		data = append([]byte{','}, data...)
+	}
+	jw.count++
+	_, err = jw.w.Write(data)
+	return err
+}
+
+func (jw *jsonExportWriter) end() error {
+	if jw.lines {
+		return nil
+	}
+	_, err := io.WriteString(jw.w, "]\n")
+	return err
+}
+
+func (jw *jsonExportWriter) flush() error {
+	return flushWriter(jw.w)
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+	"tuxiaocao/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// 导出任务状态
+const (
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ErrExportNotReady 导出任务未完成或已失败，没有可下载的文件
+var ErrExportNotReady = errors.New("export is not finished")
+
+// ExportJob 后台导出任务，文件写入 EXPORT_DIR，完成后保留 EXPORT_RETENTION_HOURS（默认24小时）
+// 任务只保存在当前实例的内存中，重启后丢失
+type ExportJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Format     string     `json:"format"`
+	Rows       int64      `json:"rows"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	owner      string
+	tenant     string
+	path       string
+}
+
+var exportJobs = struct {
+	sync.Mutex
+	jobs map[string]*ExportJob
+}{jobs: map[string]*ExportJob{}}
+
+// exportDir 导出文件目录
+func exportDir() string {
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "tuxiaocao-exports")
+}
+
+// exportRetention 导出文件保留时长
+func exportRetention() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("EXPORT_RETENTION_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// StartExportJob 在后台导出商品，owner 为发起导出的用户，只有该用户能查看和下载
+// 查询在开始前校验，ctx 的取消不影响任务，租户等上下文值保留
+func StartExportJob(ctx context.Context, store ProductStore, q *Query, format, owner string) (ExportJob, error) {
+	export := NewProductExport(context.WithoutCancel(ctx), store, q)
+	if err := export.Prefetch(); err != nil {
+		return ExportJob{}, err
+	}
+	dir := exportDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return ExportJob{}, err
+	}
+
+	job := &ExportJob{
+		ID:        uuid.NewString(),
+		Status:    ExportRunning,
+		Format:    format,
+		CreatedAt: time.Now(),
+		owner:     owner,
+		tenant:    TenantFrom(ctx),
+	}
+	job.path = filepath.Join(dir, job.ID+"."+format)
+	exportJobs.Lock()
+	exportJobs.jobs[job.ID] = job
+	snapshot := *job
+	exportJobs.Unlock()
+
+	go job.run(export)
+	return snapshot, nil
+}
+
+// run 先写入临时文件，成功后重命名，失败时删除
+func (job *ExportJob) run(export *ProductExport) {
+	rows, err := writeExportFile(job.path+".part", export, job.Format)
+	if err == nil {
+		err = os.Rename(job.path+".part", job.path)
+	}
+	if err != nil {
+		_ = os.Remove(job.path + ".part")
+		logger.Log.Errorf("export job %s error %v", job.ID, err)
+	}
+
+	now := time.Now()
+	exportJobs.Lock()
+	job.Rows = rows
+	job.FinishedAt = &now
+	job.Status = ExportDone
+	if err != nil {
+		job.Status = ExportFailed
+		job.Error = err.Error()
+	}
+	exportJobs.Unlock()
+
+	time.AfterFunc(exportRetention(), job.expire)
+}
+
+func writeExportFile(path string, export *ProductExport, format string) (int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := export.WriteTo(bufio.NewWriter(file), format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return rows, err
+}
+
+// expire 删除过期的任务和文件
+func (job *ExportJob) expire() {
+	exportJobs.Lock()
+	delete(exportJobs.jobs, job.ID)
+	exportJobs.Unlock()
+	if err := os.Remove(job.path); err != nil && !os.IsNotExist(err) {
+		logger.Log.Errorf("remove export %s error %v", job.ID, err)
+	}
+}
+
+// GetExportJob 按 ID 查询 owner 的导出任务，其他用户或租户的任务视为不存在
+func GetExportJob(ctx context.Context, id, owner string) (ExportJob, bool) {
+	exportJobs.Lock()
+	defer exportJobs.Unlock()
+	job, ok := exportJobs.jobs[id]
+	if !ok || job.owner != owner || job.tenant != TenantFrom(ctx) {
+		return ExportJob{}, false
+	}
+	return *job, true
+}
+
+// File 已完成任务的导出文件路径
+func (job ExportJob) File() (string, error) {
+	if job.Status != ExportDone {
+		return "", ErrExportNotReady
+	}
+	return job.path, nil
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductExport(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+	// More rows than one batch, so the export has to follow the cursor.
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxCursorLimit+5; i++ {
+		author := "pike"
+		if i%2 == 1 {
+			author = "thompson"
+		}
+		product := Product{ID: uuid.New(), Title: "title", Author: author, ProductStatus: 1, BaseDbTime: BaseDbTime{CreatedAt: start.Add(time.Duration(i) * time.Minute)}}
+		require.NoError(t, NewProductRepo().WithContext(ctx).Create(&product))
+		require.NoError(t, memory.Create(ctx, &product))
+	}
+	query := &Query{
+		Filters: []*FilterParam{{Field: "author", Op: OpEq, Values: []string{"pike"}}},
+		Sorting: []*SortParam{{SortBy: "created_at"}},
+	}
+
+	for name, store := range map[string]ProductStore{"gorm": NewGormProductStore(), "memory": memory} {
+		t.Run(name+" csv", func(t *testing.T) {
+			var b bytes.Buffer
+			rows, err := NewProductExport(ctx, store, query).WriteTo(&b, ExportCSV)
+			require.NoError(t, err)
+			assert.Equal(t, int64(53), rows)
+
+			records, err := csv.NewReader(&b).ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, ProductCSVHeader, records[0])
+			require.Len(t, records, 54)
+			assert.Equal(t, start.Format(time.RFC3339Nano), records[1][9])
+			assert.Equal(t, start.Add(104*time.Minute).Format(time.RFC3339Nano), records[53][9])
+		})
+
+		t.Run(name+" ndjson", func(t *testing.T) {
+			var b bytes.Buffer
+			rows, err := NewProductExport(ctx, store, query).WriteTo(&b, ExportNDJSON)
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			require.Len(t, lines, int(rows))
+			var product Product
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &product))
+			assert.Equal(t, "pike", product.Author)
+		})
+
+		t.Run(name+" json", func(t *testing.T) {
+			var b bytes.Buffer
+			_, err := NewProductExport(ctx, store, query).WriteTo(&b, ExportJSON)
+			require.NoError(t, err)
+			var products []Product
+			require.NoError(t, json.Unmarshal(b.Bytes(), &products))
+			assert.Len(t, products, 53)
+		})
+
+		t.Run(name+" empty", func(t *testing.T) {
+			var b bytes.Buffer
+			empty := &Query{Filters: []*FilterParam{{Field: "author", Op: OpEq, Values: []string{"nobody"}}}}
+			_, err := NewProductExport(ctx, store, empty).WriteTo(&b, ExportJSON)
+			require.NoError(t, err)
+			assert.Equal(t, "[]\n", b.String())
+		})
+
+		t.Run(name+" invalid query", func(t *testing.T) {
+			invalid := &Query{Filters: []*FilterParam{{Field: "user_id", Op: OpLike, Values: []string{"1"}}}}
+			var queryErr *QueryError
+			assert.ErrorAs(t, NewProductExport(ctx, store, invalid).Prefetch(), &queryErr)
+		})
+	}
+}
+
+func TestExportJob(t *testing.T) {
+	t.Setenv("EXPORT_DIR", t.TempDir())
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+	require.NoError(t, memory.Create(ctx, &Product{ID: uuid.New(), Title: "title", Author: "pike", ProductStatus: 1}))
+
+	job, err := StartExportJob(ctx, memory, &Query{}, ExportNDJSON, "1")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		job, _ = GetExportJob(ctx, job.ID, "1")
+		return job.Status != ExportRunning
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, ExportDone, job.Status)
+	assert.Equal(t, int64(1), job.Rows)
+
+	file, err := job.File()
+	require.NoError(t, err)
+	assert.FileExists(t, file)
+
+	// Jobs of other users and tenants are not found.
+	_, found := GetExportJob(ctx, job.ID, "2")
+	assert.False(t, found)
+	_, found = GetExportJob(WithTenant(context.Background(), "b"), job.ID, "1")
+	assert.False(t, found)
+}
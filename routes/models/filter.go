@@ -0,0 +1,331 @@
+package models
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Paged 使用的分页大小
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// 支持的过滤操作符
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpLike = "like"
+	OpIn   = "in"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+)
+
+// FilterParam 过滤条件，对应 filter[field][op]=value，in 操作符的多个值以逗号分隔
+type FilterParam struct {
+	Field  string   `json:"field"`
+	Op     string   `json:"op"`
+	Values []string `json:"values"`
+}
+
+// QueryError 查询参数不合法：字段未知、字段或操作符未开放、值无法转换
+type QueryError struct {
+	Param   string   `json:"param"`
+	Field   string   `json:"field"`
+	Reason  string   `json:"reason"`
+	Allowed []string `json:"allowed"`
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s: %s %s, allowed: %s", e.Param, e.Field, e.Reason, strings.Join(e.Allowed, ", "))
+}
+
+// Columns 模型通过 struct tag 开放的过滤、排序、分组、统计字段
+//
+//	Title string `gorm:"column:title" filter:"eq,like" sort:"true" group:"true"`
+//	Price int    `gorm:"column:price" metric:"sum,avg,min,max"`
+type Columns struct {
+	Filters map[string][]string      // 字段 => 允许的操作符
+	Sorts   []string                 // 允许排序的字段
+	Groups  []string                 // 允许分组的字段
+	Metrics map[string][]string      // 字段 => 允许的统计指标
+	fields  map[string]*schema.Field // 字段 => 模型字段
+	schema  *schema.Schema
+}
+
+var columnsCache sync.Map
+
+// ColumnsOf 解析模型 T 的过滤、排序白名单
+func ColumnsOf[T any]() *Columns {
+	var t T
+	typ := reflect.TypeOf(t)
+	if cached, ok := columnsCache.Load(typ); ok {
+		return cached.(*Columns)
+	}
+
+	s, err := schema.Parse(&t, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Errorf("columns of %v: %w", typ, err))
+	}
+	columns := &Columns{Filters: map[string][]string{}, Metrics: map[string][]string{}, fields: map[string]*schema.Field{}, schema: s}
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if ops := field.Tag.Get("filter"); ops != "" {
+			columns.Filters[field.DBName] = strings.Split(ops, ",")
+			columns.fields[field.DBName] = field
+		}
+		if field.Tag.Get("sort") == "true" {
+			columns.Sorts = append(columns.Sorts, field.DBName)
+			columns.fields[field.DBName] = field
+		}
+		if field.Tag.Get("group") == "true" {
+			columns.Groups = append(columns.Groups, field.DBName)
+			columns.fields[field.DBName] = field
+		}
+		if ms := field.Tag.Get("metric"); ms != "" {
+			columns.Metrics[field.DBName] = strings.Split(ms, ",")
+			columns.fields[field.DBName] = field
+		}
+	}
+	sort.Strings(columns.Sorts)
+	sort.Strings(columns.Groups)
+
+	cached, _ := columnsCache.LoadOrStore(typ, columns)
+	return cached.(*Columns)
+}
+
+// filterFields 允许过滤的字段，用于错误提示
+func (cs *Columns) filterFields() []string {
+	names := make([]string, 0, len(cs.Filters))
+	for name := range cs.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate 按白名单校验过滤和排序字段
+func (cs *Columns) Validate(q *Query) error {
+	for _, filter := range q.Filters {
+		ops, ok := cs.Filters[filter.Field]
+		if !ok {
+			return &QueryError{Param: "filter", Field: filter.Field, Reason: "is not filterable", Allowed: cs.filterFields()}
+		}
+		if !containsString(ops, filter.Op) {
+			return &QueryError{Param: "filter", Field: filter.Field, Reason: "does not support operator " + filter.Op, Allowed: ops}
+		}
+	}
+	for _, sortParam := range q.Sorting {
+		if !containsString(cs.Sorts, sortParam.SortBy) {
+			return &QueryError{Param: "sort", Field: sortParam.SortBy, Reason: "is not sortable", Allowed: cs.Sorts}
+		}
+	}
+	return nil
+}
+
+// FilterValues 将过滤值转换为字段类型
+func (cs *Columns) FilterValues(filter *FilterParam) ([]interface{}, error) {
+	field := cs.fields[filter.Field]
+	values := make([]interface{}, 0, len(filter.Values))
+	for _, raw := range filter.Values {
+		if filter.Op == OpLike {
+			values = append(values, raw)
+			continue
+		}
+		value, err := convertValue(field.FieldType, raw)
+		if err != nil {
+			return nil, &QueryError{Param: "filter", Field: filter.Field, Reason: "has invalid value " + strconv.Quote(raw), Allowed: cs.Filters[filter.Field]}
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Expression 过滤条件对应的 SQL 表达式
+func (cs *Columns) Expression(filter *FilterParam) (clause.Expression, error) {
+	values, err := cs.FilterValues(filter)
+	if err != nil {
+		return nil, err
+	}
+	column := clause.Column{Name: filter.Field}
+	switch filter.Op {
+	case OpNe:
+		return clause.Neq{Column: column, Value: values[0]}, nil
+	case OpLike:
+		// 用户输入的 % 和 _ 按字面匹配
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, likeContains(values[0].(string))}}, nil
+	case OpIn:
+		return clause.IN{Column: column, Values: values}, nil
+	case OpGt:
+		return clause.Gt{Column: column, Value: values[0]}, nil
+	case OpGte:
+		return clause.Gte{Column: column, Value: values[0]}, nil
+	case OpLt:
+		return clause.Lt{Column: column, Value: values[0]}, nil
+	case OpLte:
+		return clause.Lte{Column: column, Value: values[0]}, nil
+	default:
+		return clause.Eq{Column: column, Value: values[0]}, nil
+	}
+}
+
+// ApplyQuery 校验查询参数并追加过滤条件，返回对应的分页、排序选项
+func (c *Curd[T]) ApplyQuery(q *Query) (*QueryOption, error) {
+	var t T
+	if c.localDB == nil {
+		c.localDB = c.conn().Model(&t)
+	}
+	columns := ColumnsOf[T]()
+	if err := columns.Validate(q); err != nil {
+		return nil, err
+	}
+	for _, filter := range q.Filters {
+		expression, err := columns.Expression(filter)
+		if err != nil {
+			return nil, err
+		}
+		c.localDB = c.localDB.Clauses(clause.Where{Exprs: []clause.Expression{expression}})
+	}
+	if q.Taxonomy != nil {
+		if columns.schema.Table != ColumnsOf[Product]().schema.Table {
+			return nil, &QueryError{Param: "category", Field: columns.schema.Table, Reason: "is only supported for products"}
+		}
+		c.localDB = c.localDB.Clauses(clause.Where{Exprs: q.Taxonomy.expressions(columns.schema.Table)})
+	}
+
+	op := NewOP().SetOffset(q.PageNo).SetLimit(q.PageSize).SetSorting(q.Sorting)
+	orders := make([]string, 0, len(q.Sorting))
+	for _, sortParam := range q.Sorting {
+		direction := "asc"
+		if sortParam.Descending {
+			direction = "desc"
+		}
+		orders = append(orders, sortParam.SortBy+" "+direction)
+	}
+	op.SetOrder(strings.Join(orders, ", "))
+	return op, nil
+}
+
+// ParseQuery 解析列表查询参数，没有 page_size 时 PageSize 为0，即返回全部数据
+//
+//	filter[title][like]=x&filter[product_status][in]=draft,in_review&sort=-created_at,title&page_no=2&page_size=20
+func ParseQuery(values url.Values) (*Query, error) {
+	q := &Query{PageNo: 1}
+	for key, vals := range values {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		// filter[field] 等同于 filter[field][eq]
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]"), "][")
+		if len(parts) > 2 || parts[0] == "" {
+			return nil, &QueryError{Param: "filter", Field: key, Reason: "is malformed, use filter[field][op]=value"}
+		}
+		op := OpEq
+		if len(parts) == 2 {
+			op = parts[1]
+		}
+		for _, val := range vals {
+			filter := &FilterParam{Field: parts[0], Op: op, Values: []string{val}}
+			if op == OpIn {
+				filter.Values = strings.Split(val, ",")
+			}
+			q.Filters = append(q.Filters, filter)
+		}
+	}
+	// 保证条件顺序稳定，便于缓存和排查
+	sort.SliceStable(q.Filters, func(i, j int) bool {
+		return q.Filters[i].Field+q.Filters[i].Op < q.Filters[j].Field+q.Filters[j].Op
+	})
+
+	if sorting := values.Get("sort"); sorting != "" {
+		for _, sortBy := range strings.Split(sorting, ",") {
+			sortBy = strings.TrimSpace(sortBy)
+			if sortBy == "" {
+				continue
+			}
+			q.Sorting = append(q.Sorting, &SortParam{
+				SortBy:     strings.TrimPrefix(sortBy, "-"),
+				Descending: strings.HasPrefix(sortBy, "-"),
+			})
+		}
+	}
+
+	var err error
+	if pageNo := values.Get("page_no"); pageNo != "" {
+		if q.PageNo, err = strconv.Atoi(pageNo); err != nil || q.PageNo < 1 {
+			return nil, &QueryError{Param: "page_no", Field: pageNo, Reason: "is not a positive number"}
+		}
+	}
+	if pageSize := values.Get("page_size"); pageSize != "" {
+		if q.PageSize, err = strconv.Atoi(pageSize); err != nil || q.PageSize < 1 {
+			return nil, &QueryError{Param: "page_size", Field: pageSize, Reason: "is not a positive number"}
+		}
+	}
+	return q, nil
+}
+
+// Paged 没有 page_size 时每页 defaultPageSize 条，且不超过 maxPageSize 条，用于不应一次返回全部数据的列表
+func (q *Query) Paged() *Query {
+	if q.PageSize <= 0 {
+		q.PageSize = defaultPageSize
+	}
+	if q.PageSize > maxPageSize {
+		q.PageSize = maxPageSize
+	}
+	return q
+}
+
+// convertValue 将字符串转换为字段类型，支持基础类型、time.Time 以及实现 encoding.TextUnmarshaler 的类型（如 uuid.UUID）
+func convertValue(typ reflect.Type, raw string) (interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, nil
+		}
+		return time.ParseInLocation(time.DateOnly, raw, time.Local)
+	}
+	if ptr := reflect.New(typ); ptr.Type().Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw)); err != nil {
+			return nil, err
+		}
+		return ptr.Elem().Interface(), nil
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(raw, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(raw, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.String:
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", typ)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuery(t *testing.T) {
+	values, _ := url.ParseQuery("filter[title][like]=x&filter[product_status][in]=1,2&sort=-created_at,title&page_no=2")
+
+	query, err := ParseQuery(values)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, query.PageNo)
+	// Without page_size all rows are returned, unless the list is paged.
+	assert.Equal(t, 0, query.PageSize)
+	assert.Equal(t, defaultPageSize, query.Paged().PageSize)
+	assert.Equal(t, []*FilterParam{
+		{Field: "product_status", Op: OpIn, Values: []string{"1", "2"}},
+		{Field: "title", Op: OpLike, Values: []string{"x"}},
+	}, query.Filters)
+	assert.Equal(t, []*SortParam{
+		{SortBy: "created_at", Descending: true},
+		{SortBy: "title"},
+	}, query.Sorting)
+	assert.NoError(t, ColumnsOf[Product]().Validate(query))
+}
+
+func TestColumnsValidate(t *testing.T) {
+	// Define a structure for specifying input and output data of a single test case.
+	tests := []struct {
+		description string
+		query       string
+		field       string
+	}{
+		{
+			description: "unknown filter field",
+			query:       "filter[password][eq]=x",
+			field:       "password",
+		},
+		{
+			description: "not allowed operator",
+			query:       "filter[title][gt]=x",
+			field:       "title",
+		},
+		{
+			description: "not sortable field",
+			query:       "sort=user_id",
+			field:       "user_id",
+		},
+	}
+
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		query, err := ParseQuery(values)
+		assert.NoErrorf(t, err, test.description)
+
+		err = ColumnsOf[Product]().Validate(query)
+
+		queryErr, ok := err.(*QueryError)
+		if assert.Truef(t, ok, test.description) {
+			assert.Equalf(t, test.field, queryErr.Field, test.description)
+			assert.NotEmptyf(t, queryErr.Allowed, test.description)
+		}
+	}
+}
+
+func TestLikeFilterEscapesWildcards(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	for _, title := range []string{"50% off", "500 off", "a_b", "axb", "c!d"} {
+		require.NoError(t, NewProductRepo().WithContext(ctx).Create(&Product{ID: uuid.New(), Title: title}))
+	}
+
+	for like, expected := range map[string][]string{
+		"0%":  {"50% off"},
+		"_":   {"a_b"},
+		"!":   {"c!d"},
+		"off": {"50% off", "500 off"},
+	} {
+		repo := NewProductRepo().WithContext(ctx)
+		op, err := repo.ApplyQuery(&Query{Filters: []*FilterParam{{Field: "title", Op: OpLike, Values: []string{like}}}})
+		require.NoError(t, err, like)
+		products, _, err := repo.List(op.SetOrder("title"))
+		require.NoError(t, err, like)
+		titles := []string{}
+		for _, product := range products {
+			titles = append(titles, product.Title)
+		}
+		assert.Equal(t, expected, titles, like)
+	}
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // 注册 GIF 解码
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ImageTypes 允许上传的图片类型及扩展名，按文件内容识别，与客户端声明的类型无关
+var ImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ErrUnsupportedImage 文件内容不是允许的图片类型
+var ErrUnsupportedImage = errors.New("unsupported image type, use jpeg, png or gif")
+
+// ErrImageTooLarge 图片文件或像素数超过上限
+var ErrImageTooLarge = errors.New("image is too large")
+
+// ImageMaxBytes 上传文件大小上限，IMAGE_MAX_BYTES，默认 5MB
+func ImageMaxBytes() int64 {
+	n, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64)
+	if err != nil || n <= 0 {
+		return 5 << 20
+	}
+	return n
+}
+
+// imageMaxPixels 解码前检查的像素数上限，防止小文件解码出超大图片
+func imageMaxPixels() int {
+	n, err := strconv.Atoi(os.Getenv("IMAGE_MAX_PIXELS"))
+	if err != nil || n <= 0 {
+		return 40_000_000
+	}
+	return n
+}
+
+// thumbnailSize 缩略图最长边，THUMBNAIL_SIZE，默认 256
+func thumbnailSize() int {
+	n, err := strconv.Atoi(os.Getenv("THUMBNAIL_SIZE"))
+	if err != nil || n <= 0 {
+		return 256
+	}
+	return n
+}
+
+// ProductImage 上传的商品图片：原图和缩略图的对象键及内容
+type ProductImage struct {
+	Key           string
+	ContentType   string
+	Data          []byte
+	Width         int
+	Height        int
+	ThumbnailKey  string
+	ThumbnailType string
+	ThumbnailData []byte
+}
+
+// NewProductImage 识别并解码图片，生成缩略图；对象键为 products/<租户>/<商品>/<随机名>
+func NewProductImage(product Product, data []byte) (*ProductImage, error) {
+	if int64(len(data)) > ImageMaxBytes() {
+		return nil, ErrImageTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := ImageTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w, got %s", ErrUnsupportedImage, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrUnsupportedImage, err)
+	}
+	if config.Width*config.Height > imageMaxPixels() {
+		return nil, fmt.Errorf("%w, %dx%d pixels", ErrImageTooLarge, config.Width, config.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrUnsupportedImage, err)
+	}
+
+	// 缩略图：JPEG 仍为 JPEG，PNG 和 GIF（第一帧）为 PNG 以保留透明
+	thumb := Thumbnail(src, thumbnailSize())
+	var buf bytes.Buffer
+	thumbType, thumbExt := "image/png", ".png"
+	if contentType == "image/jpeg" {
+		thumbType, thumbExt = "image/jpeg", ".jpg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("products/%s/%s/%s", product.TenantID, product.ID, uuid.New())
+	return &ProductImage{
+		Key:           name + ext,
+		ContentType:   contentType,
+		Data:          data,
+		Width:         config.Width,
+		Height:        config.Height,
+		ThumbnailKey:  name + "_thumb" + thumbExt,
+		ThumbnailType: thumbType,
+		ThumbnailData: buf.Bytes(),
+	}, nil
+}
+
+// ReadImage 读取上传文件，超过 ImageMaxBytes 时返回 ErrImageTooLarge
+func ReadImage(r io.Reader) ([]byte, error) {
+	limit := ImageMaxBytes()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrImageTooLarge
+	}
+	return data, nil
+}
+
+// Thumbnail 等比缩小到最长边不超过 size，按区域取平均值；小图只复制不放大
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	if tw == w && th == h {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeImage(t *testing.T, format string, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	} else {
+		require.NoError(t, png.Encode(&buf, img))
+	}
+	return buf.Bytes()
+}
+
+func TestNewProductImage(t *testing.T) {
+	t.Setenv("THUMBNAIL_SIZE", "64")
+	product := Product{ID: uuid.New(), TenantID: "a"}
+
+	tests := []struct {
+		description string
+		data        []byte
+		contentType string
+		thumbType   string
+		thumbW      int
+		thumbH      int
+		err         error
+	}{
+		{description: "landscape png", data: encodeImage(t, "png", 200, 100), contentType: "image/png", thumbType: "image/png", thumbW: 64, thumbH: 32},
+		{description: "portrait jpeg", data: encodeImage(t, "jpeg", 50, 100), contentType: "image/jpeg", thumbType: "image/jpeg", thumbW: 32, thumbH: 64},
+		{description: "small image is not enlarged", data: encodeImage(t, "png", 10, 20), contentType: "image/png", thumbType: "image/png", thumbW: 10, thumbH: 20},
+		{description: "text with image file name", data: []byte("not an image"), err: ErrUnsupportedImage},
+		{description: "broken png", data: []byte("\x89PNG\r\n\x1a\nbroken"), err: ErrUnsupportedImage},
+	}
+
+	for _, test := range tests {
+		img, err := NewProductImage(product, test.data)
+		if test.err != nil {
+			assert.ErrorIs(t, err, test.err, test.description)
+			continue
+		}
+		require.NoError(t, err, test.description)
+		assert.Equal(t, test.contentType, img.ContentType, test.description)
+		assert.Equal(t, test.thumbType, img.ThumbnailType, test.description)
+		assert.True(t, strings.HasPrefix(img.Key, "products/a/"+product.ID.String()+"/"), test.description)
+
+		thumb, _, err := image.DecodeConfig(bytes.NewReader(img.ThumbnailData))
+		require.NoError(t, err, test.description)
+		assert.Equal(t, test.thumbW, thumb.Width, test.description)
+		assert.Equal(t, test.thumbH, thumb.Height, test.description)
+	}
+
+	// Size and pixel limits.
+	t.Setenv("IMAGE_MAX_BYTES", "100")
+	_, err := ReadImage(bytes.NewReader(make([]byte, 101)))
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	t.Setenv("IMAGE_MAX_BYTES", "")
+	t.Setenv("IMAGE_MAX_PIXELS", "100")
+	_, err = NewProductImage(product, encodeImage(t, "png", 20, 20))
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
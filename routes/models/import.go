@@ -0,0 +1,179 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// 导入格式，CSV 的列与导出一致
+const (
+	ImportCSV    = ExportCSV
+	ImportNDJSON = ExportNDJSON
+)
+
+// ImportFormats 支持的导入格式
+var ImportFormats = []string{ImportCSV, ImportNDJSON}
+
+// MaxImportRows 一次最多导入的行数
+const MaxImportRows = 10000
+
+// ImportMaxBytes 导入文件大小上限，IMPORT_MAX_BYTES，默认 32MB
+func ImportMaxBytes() int64 {
+	n, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64)
+	if err != nil || n <= 0 {
+		return 32 << 20
+	}
+	return n
+}
+
+// ErrTooManyRows 导入文件超过 MaxImportRows 行
+var ErrTooManyRows = fmt.Errorf("import is limited to %d rows", MaxImportRows)
+
+// ImportRow 导入文件中的一行，Line 为文件中的行号（CSV 表头为第1行），Err 为解析错误
+type ImportRow struct {
+	Line    int
+	Product Product
+	Err     error
+}
+
+// ReadProducts 逐行解析 CSV 或 NDJSON 文件；行内错误记录在 ImportRow.Err 中，文件本身无法解析时返回错误
+// CSV 第一行为表头，列名同 ProductCSVHeader，可缺省、顺序任意；product_status 为状态名称或整数，为空时为 draft
+// 由服务端生成的字段（user_id、tenant_id、时间和版本号）被忽略，导出的文件可以直接导入
+func ReadProducts(r io.Reader, format string) ([]ImportRow, error) {
+	var rows []ImportRow
+	var err error
+	if format == ImportNDJSON {
+		rows, err = readNDJSON(r)
+	} else {
+		rows, err = readCSV(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkDuplicateIDs(rows)
+	return rows, nil
+}
+
+func readCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv header is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !containsString(ProductCSVHeader, name) {
+			return nil, &QueryError{Param: "header", Field: name, Reason: "is not a product column", Allowed: ProductCSVHeader}
+		}
+		columns[i] = name
+	}
+	if !containsString(columns, "title") {
+		return nil, &QueryError{Param: "header", Field: "title", Reason: "is required", Allowed: ProductCSVHeader}
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		// 列数不一致只影响该行，其他语法错误无法继续解析
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, err
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Line: line, Err: err}
+		if err == nil {
+			row.Product, row.Err = productOfRecord(columns, record)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// productOfRecord CSV 一行映射为商品
+func productOfRecord(columns, record []string) (Product, error) {
+	product := Product{ProductStatus: StateDraft}
+	for i, name := range columns {
+		value := strings.TrimSpace(record[i])
+		var err error
+		switch name {
+		case "id":
+			if value != "" {
+				product.ID, err = uuid.Parse(value)
+			}
+		case "title":
+			product.Title = value
+		case "author":
+			product.Author = value
+		case "product_status":
+			if value != "" {
+				err = product.ProductStatus.UnmarshalText([]byte(value))
+			}
+		case "picture":
+			product.ProductAttrs.Picture = value
+		case "description":
+			product.ProductAttrs.Description = value
+		}
+		if err != nil {
+			return product, fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return product, nil
+}
+
+func readNDJSON(r io.Reader) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var rows []ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+		row := ImportRow{Line: line, Product: Product{ProductStatus: StateDraft}}
+		row.Err = json.Unmarshal(data, &row.Product)
+		row.Product.UserID, row.Product.TenantID, row.Product.Rating, row.Product.BaseDbTime = "", "", ReviewStats{}, BaseDbTime{}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// checkDuplicateIDs 文件中重复的 ID 只保留第一行
+func checkDuplicateIDs(rows []ImportRow) {
+	lines := map[uuid.UUID]int{}
+	for i := range rows {
+		id := rows[i].Product.ID
+		if rows[i].Err != nil || id == uuid.Nil {
+			continue
+		}
+		if line, ok := lines[id]; ok {
+			rows[i].Err = fmt.Errorf("id %s is already used in line %d", id, line)
+			continue
+		}
+		lines[id] = rows[i].Line
+	}
+}
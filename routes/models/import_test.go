@@ -0,0 +1,133 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadProducts(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		description string
+		format      string
+		file        string
+		lines       []int
+		errors      []bool
+		fileError   bool
+	}{
+		{
+			description: "csv with some columns in any order",
+			format:      ImportCSV,
+			file:        "author,title,rating\npike,go,5\nthompson,c,\n",
+			lines:       []int{2, 3},
+			errors:      []bool{false, false},
+		},
+		{
+			description: "csv with broken lines",
+			format:      ImportCSV,
+			file:        "id,title,rating\n" + id.String() + ",go,five\n" + id.String() + ",c,1\nx,y\nnot-a-uuid,z,1\n",
+			lines:       []int{2, 3, 4, 5},
+			errors:      []bool{false, true, true, true},
+		},
+		{
+			description: "csv with duplicated id",
+			format:      ImportCSV,
+			file:        "id,title\n" + id.String() + ",go\n" + id.String() + ",c\n",
+			lines:       []int{2, 3},
+			errors:      []bool{false, true},
+		},
+		{
+			description: "csv with unknown column",
+			format:      ImportCSV,
+			file:        "title,price\ngo,1\n",
+			fileError:   true,
+		},
+		{
+			description: "csv without title column",
+			format:      ImportCSV,
+			file:        "author\npike\n",
+			fileError:   true,
+		},
+		{
+			description: "ndjson with empty and broken lines",
+			format:      ImportNDJSON,
+			file:        `{"title":"go","product_attrs":{"rating":5}}` + "\n\n{broken\n" + `{"title":"c","version":7}` + "\n",
+			lines:       []int{1, 3, 4},
+			errors:      []bool{false, true, false},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := ReadProducts(strings.NewReader(test.file), test.format)
+		if test.fileError {
+			assert.Error(t, err, test.description)
+			continue
+		}
+		require.NoError(t, err, test.description)
+		require.Len(t, rows, len(test.lines), test.description)
+		for i, row := range rows {
+			assert.Equal(t, test.lines[i], row.Line, test.description)
+			assert.Equal(t, test.errors[i], row.Err != nil, test.description)
+			if row.Err == nil {
+				assert.Equal(t, StateDraft, row.Product.ProductStatus, test.description)
+				assert.Zero(t, row.Product.Version, test.description)
+			}
+		}
+	}
+}
+
+func TestReadProductsOfExport(t *testing.T) {
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+	product := Product{ID: uuid.New(), Title: "go, the language", Author: "pike", ProductStatus: 1, ProductAttrs: ProductAttrs{Description: "line\nbreak", Picture: "go.png"}}
+	require.NoError(t, memory.Create(ctx, &product))
+
+	var b bytes.Buffer
+	_, err := NewProductExport(ctx, memory, &Query{}).WriteTo(&b, ExportCSV)
+	require.NoError(t, err)
+
+	rows, err := ReadProducts(&b, ImportCSV)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.NoError(t, rows[0].Err)
+	assert.Equal(t, product.ID, rows[0].Product.ID)
+	assert.Equal(t, product.Title, rows[0].Product.Title)
+	assert.Equal(t, product.ProductAttrs, rows[0].Product.ProductAttrs)
+	assert.Empty(t, rows[0].Product.UserID)
+}
+
+func TestCreateAll(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+	existing := Product{ID: uuid.New(), Title: "existing"}
+	require.NoError(t, NewProductRepo().WithContext(ctx).Create(&existing))
+	require.NoError(t, memory.Create(ctx, &existing))
+
+	for name, store := range map[string]ProductStore{"gorm": NewGormProductStore(), "memory": memory} {
+		// The duplicated ID fails, so nothing is written.
+		products := []*Product{{ID: uuid.New(), Title: "a"}, {ID: existing.ID, Title: "b"}, {ID: uuid.New(), Title: "c"}}
+		errs := store.CreateAll(ctx, products, 2)
+		require.Len(t, errs, 3, name)
+		assert.NoError(t, errs[0], name)
+		assert.Error(t, errs[1], name)
+		assert.NoError(t, errs[2], name)
+		_, total, err := store.List(ctx, &Query{})
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(1), total, name)
+
+		products = []*Product{{ID: uuid.New(), Title: "a"}, {ID: uuid.New(), Title: "c"}}
+		for _, err := range store.CreateAll(ctx, products, 1) {
+			assert.NoError(t, err, name)
+		}
+		_, total, err = store.List(ctx, &Query{})
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(3), total, name)
+	}
+}
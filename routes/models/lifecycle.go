@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+	"tuxiaocao/pkg/repository"
+
+	"github.com/google/uuid"
+)
+
+// ProductState 商品状态，数据库中保存为整数（兼容之前的 0 == draft, 1 == active），JSON 中为名称
+type ProductState int
+
+// 商品状态
+const (
+	StateDraft    ProductState = 0
+	StateActive   ProductState = 1
+	StateInReview ProductState = 2
+	StateArchived ProductState = 3
+)
+
+var stateNames = map[ProductState]string{
+	StateDraft:    "draft",
+	StateActive:   "active",
+	StateInReview: "in_review",
+	StateArchived: "archived",
+}
+
+// ErrInvalidState 未知的商品状态
+var ErrInvalidState = errors.New("unknown product state, use draft, in_review, active or archived")
+
+// ErrInvalidTransition 商品当前状态不允许该流转
+var ErrInvalidTransition = errors.New("transition is not allowed in the current state")
+
+func (s ProductState) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return strconv.Itoa(int(s))
+}
+
+// MarshalText 输出状态名称
+func (s ProductState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText 接受状态名称或整数，用于 JSON、过滤条件和导入
+func (s *ProductState) UnmarshalText(text []byte) error {
+	for state, name := range stateNames {
+		if name == string(text) {
+			*s = state
+			return nil
+		}
+	}
+	n, err := strconv.Atoi(string(text))
+	if _, ok := stateNames[ProductState(n)]; err != nil || !ok {
+		return ErrInvalidState
+	}
+	*s = ProductState(n)
+	return nil
+}
+
+// Transition 商品状态流转：从 From 中的状态到 To，需要 Credential 权限，Owner 为 true 时创建人无需该权限
+type Transition struct {
+	Name       string         `json:"name"`
+	From       []ProductState `json:"from"`
+	To         ProductState   `json:"to"`
+	Credential string         `json:"credential"`
+	Owner      bool           `json:"owner"`
+}
+
+// Transitions 商品的工作流：draft → in_review → active → archived，审核不通过退回 draft
+var Transitions = map[string]Transition{
+	"submit":  {Name: "submit", From: []ProductState{StateDraft}, To: StateInReview, Credential: repository.ProductUpdateCredential, Owner: true},
+	"approve": {Name: "approve", From: []ProductState{StateInReview}, To: StateActive, Credential: repository.ProductApproveCredential},
+	"reject":  {Name: "reject", From: []ProductState{StateInReview}, To: StateDraft, Credential: repository.ProductApproveCredential},
+	"archive": {Name: "archive", From: []ProductState{StateActive}, To: StateArchived, Credential: repository.ProductUpdateCredential, Owner: true},
+}
+
+// TransitionNames 全部流转名称，按字母排序
+func TransitionNames() []string {
+	names := make([]string, 0, len(Transitions))
+	for name := range Transitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Allows 当前状态是否允许该流转
+func (t Transition) Allows(state ProductState) bool {
+	for _, from := range t.From {
+		if from == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Permits 是否有权执行该流转
+func (t Transition) Permits(owner bool, credentials map[string]bool) bool {
+	return (t.Owner && owner) || credentials[t.Credential]
+}
+
+// AvailableTransitions 当前状态允许的流转名称
+func AvailableTransitions(state ProductState) []string {
+	var names []string
+	for _, name := range TransitionNames() {
+		if Transitions[name].Allows(state) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ProductTransition struct to describe a recorded state change of a product.
+type ProductTransition struct {
+	ID        uuid.UUID    `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string       `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ProductID uuid.UUID    `gorm:"column:product_id;type:char(36);not null;index" json:"product_id"`
+	Name      string       `gorm:"column:name;size:32" json:"name"`
+	FromState ProductState `gorm:"column:from_state" json:"from_state"`
+	ToState   ProductState `gorm:"column:to_state" json:"to_state"`
+	UserID    string       `gorm:"column:user_id" json:"user_id"`
+	Comment   string       `gorm:"column:comment" json:"comment"`
+	CreatedAt time.Time    `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+type ProductTransitionRepo struct {
+	Curd[ProductTransition]
+}
+
+func NewProductTransitionRepo() *ProductTransitionRepo {
+	return &ProductTransitionRepo{}
+}
+
+// NewProductTransition 商品执行流转的记录，当前状态不允许时返回 ErrInvalidTransition，不检查权限
+func NewProductTransition(product Product, t Transition, userID, comment string) (*ProductTransition, error) {
+	if !t.Allows(product.ProductStatus) {
+		return nil, fmt.Errorf("%w: %s from %s", ErrInvalidTransition, t.Name, product.ProductStatus)
+	}
+	return &ProductTransition{
+		ID:        uuid.New(),
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		Name:      t.Name,
+		FromState: product.ProductStatus,
+		ToState:   t.To,
+		UserID:    userID,
+		Comment:   comment,
+	}, nil
+}
+
+// transitionsOf 商品的流转记录，最新的在前
+func transitionsOf(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]ProductTransition, int64, error) {
+	repo := NewProductTransitionRepo().WithContext(ctx).Where("product_id = ?", productID)
+	return repo.List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder("created_at desc"))
+}
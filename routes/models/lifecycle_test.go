@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"tuxiaocao/pkg/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductStateText(t *testing.T) {
+	tests := []struct {
+		text  string
+		state ProductState
+		err   bool
+	}{
+		{"draft", StateDraft, false},
+		{"in_review", StateInReview, false},
+		{"1", StateActive, false},
+		{"3", StateArchived, false},
+		{"deleted", 0, true},
+		{"9", 0, true},
+	}
+	for _, test := range tests {
+		var state ProductState
+		err := state.UnmarshalText([]byte(test.text))
+		if test.err {
+			assert.ErrorIs(t, err, ErrInvalidState, test.text)
+			continue
+		}
+		require.NoError(t, err, test.text)
+		assert.Equal(t, test.state, state, test.text)
+	}
+
+	raw, err := json.Marshal(Product{ProductStatus: StateInReview})
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"product_status":"in_review"`)
+}
+
+func TestTransitions(t *testing.T) {
+	assert.Equal(t, []string{"submit"}, AvailableTransitions(StateDraft))
+	assert.Equal(t, []string{"approve", "reject"}, AvailableTransitions(StateInReview))
+	assert.Empty(t, AvailableTransitions(StateArchived))
+
+	submit, approve := Transitions["submit"], Transitions["approve"]
+	assert.True(t, submit.Permits(true, nil))
+	assert.False(t, submit.Permits(false, nil))
+	assert.False(t, approve.Permits(true, map[string]bool{repository.ProductUpdateCredential: true}))
+	assert.True(t, approve.Permits(false, map[string]bool{repository.ProductApproveCredential: true}))
+
+	_, err := NewProductTransition(Product{ProductStatus: StateDraft}, approve, "1", "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+}
+
+func TestChangeState(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+
+	for name, store := range map[string]ProductStore{"gorm": NewGormProductStore(), "memory": NewMemoryProductStore()} {
+		product := &Product{ID: uuid.New(), Title: "first", Author: "alice"}
+		require.NoError(t, store.Create(ctx, product), name)
+
+		// draft → in_review
+		record, err := NewProductTransition(*product, Transitions["submit"], "1", "ready")
+		require.NoError(t, err, name)
+		changed := &Product{ID: product.ID}
+		changed.Version = product.Version
+		require.NoError(t, store.ChangeState(ctx, changed, record), name)
+		current, err := store.Get(ctx, product.ID)
+		require.NoError(t, err, name)
+		assert.Equal(t, StateInReview, current.ProductStatus, name)
+		assert.Equal(t, product.Version+1, current.Version, name)
+
+		// Filter by state name.
+		values, _ := url.ParseQuery("filter[product_status][eq]=in_review")
+		query, err := ParseQuery(values)
+		require.NoError(t, err, name)
+		found, total, err := store.List(ctx, query)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(1), total, name)
+		require.Len(t, found, 1, name)
+		assert.Equal(t, product.ID, found[0].ID, name)
+
+		// in_review → draft, the state is zero and written as well.
+		record, err = NewProductTransition(current, Transitions["reject"], "2", "missing description")
+		require.NoError(t, err, name)
+		changed = &Product{ID: product.ID}
+		changed.Version = current.Version
+		require.NoError(t, store.ChangeState(ctx, changed, record), name)
+		current, err = store.Get(ctx, product.ID)
+		require.NoError(t, err, name)
+		assert.Equal(t, StateDraft, current.ProductStatus, name)
+
+		// The version has changed since, no transition is recorded.
+		record, err = NewProductTransition(current, Transitions["submit"], "1", "")
+		require.NoError(t, err, name)
+		changed = &Product{ID: product.ID}
+		changed.Version = product.Version
+		assert.ErrorIs(t, store.ChangeState(ctx, changed, record), ErrVersionConflict, name)
+
+		transitions, total, err := store.Transitions(ctx, product.ID, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(2), total, name)
+		require.Len(t, transitions, 2, name)
+		names := []string{transitions[0].Name, transitions[1].Name}
+		assert.ElementsMatch(t, []string{"submit", "reject"}, names, name)
+	}
+}
+
+func TestPurgeDeletedTransitions(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	store := NewGormProductStore()
+
+	purged, kept := &Product{ID: uuid.New(), Title: "purged"}, &Product{ID: uuid.New(), Title: "kept"}
+	for _, product := range []*Product{purged, kept} {
+		require.NoError(t, store.Create(ctx, product))
+		record, err := NewProductTransition(*product, Transitions["submit"], "1", "")
+		require.NoError(t, err)
+		require.NoError(t, store.ChangeState(ctx, &Product{ID: product.ID}, record))
+	}
+
+	// The transitions of the purged product go with it, the others stay.
+	purgeProduct(t, ctx, purged.ID)
+	assert.Equal(t, int64(0), countRows(t, "product_transitions", "product_id = ?", purged.ID))
+	assert.Equal(t, int64(1), countRows(t, "product_transitions", "product_id = ?", kept.ID))
+}
@@ -7,14 +7,15 @@ import (
 	"github.com/google/uuid"
 )
 
-// LogRecord struct to describe product object.
+// LogRecord struct to describe audit entry of a create, update or delete.
 type LogRecord struct {
-	ID              uuid.UUID      `gorm:"column:id;type:bigint;not null;primaryKey;auto_increment" json:"id" `
-	UserID          string         `gorm:"column:user_id" json:"user_id" `
-	Title           string         `gorm:"column:title" json:"title" validate:"required,lte=255"`
-	Author          string         `gorm:"column:author" json:"author" validae:"required,lte=255"`
-	LogRecordStatus int            `gorm:"column:log_status" json:"log_status" validate:"required,len=1"`
-	LogRecordAttrs  LogRecordAttrs `gorm:"column:log_attrs;type:json" json:"log_attrs"`
+	ID       uuid.UUID        `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID string           `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	UserID   string           `gorm:"column:user_id" json:"user_id"`
+	Entity   string           `gorm:"column:entity;size:64;index:idx_log_records_entity,priority:1" json:"entity"`
+	EntityID string           `gorm:"column:entity_id;size:64;index:idx_log_records_entity,priority:2" json:"entity_id"`
+	Action   string           `gorm:"column:action;size:16" json:"action"`
+	Changes  LogRecordChanges `gorm:"column:changes;type:json" json:"changes"`
 	BaseDbTime
 }
 
@@ -26,26 +27,29 @@ func NewLogRecordRepo() *LogRecordRepo {
 	return &LogRecordRepo{}
 }
 
-// LogRecordAttrs struct to describe product attributes.
-type LogRecordAttrs struct {
-	Picture     string `json:"picture"`
-	Description string `json:"description"`
-	Rating      int    `json:"rating" validate:"-"`
+// FieldChange struct to describe value of a field before and after the change (null if absent).
+type FieldChange struct {
+	Before json.RawMessage `json:"before" swaggertype:"object"`
+	After  json.RawMessage `json:"after" swaggertype:"object"`
 }
 
-// Value make the LogRecordAttrs struct implement the driver.Valuer interface.
+// LogRecordChanges struct to describe changed fields by column name.
+type LogRecordChanges map[string]*FieldChange
+
+// Value make the LogRecordChanges struct implement the driver.Valuer interface.
 // This method simply returns the JSON-encoded representation of the struct.
-func (b LogRecordAttrs) Value() (driver.Value, error) {
+func (b LogRecordChanges) Value() (driver.Value, error) {
 	return json.Marshal(b)
 }
 
-// Scan make the LogRecordAttrs struct implement the sql.Scanner interface.
+// Scan make the LogRecordChanges struct implement the sql.Scanner interface.
 // This method simply decodes a JSON-encoded value into the struct fields.
-func (b *LogRecordAttrs) Scan(value interface{}) error {
-	j, ok := value.([]byte)
-	if !ok {
-		return errors.New("type assertion to []byte failed")
+func (b *LogRecordChanges) Scan(value interface{}) error {
+	switch j := value.(type) {
+	case []byte:
+		return json.Unmarshal(j, &b)
+	case string:
+		return json.Unmarshal([]byte(j), &b)
 	}
-
-	return json.Unmarshal(j, &b)
+	return errors.New("type assertion to []byte failed")
 }
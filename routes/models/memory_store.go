@@ -0,0 +1,1539 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// memoryScope 软删除范围
+type memoryScope int
+
+const (
+	scopeActive memoryScope = iota
+	scopeDeleted
+	scopeAll
+)
+
+// memoryTable 线程安全的内存表，过滤、排序、分页、软删除和版本号语义与 Curd 保持一致
+type memoryTable[T any] struct {
+	mu      sync.RWMutex
+	rows    map[interface{}]*T
+	columns *Columns
+	pk      *schema.Field
+	tenant  *schema.Field // 租户字段，非租户模型为 nil
+	nextID  int64
+}
+
+func newMemoryTable[T any]() *memoryTable[T] {
+	columns := ColumnsOf[T]()
+	return &memoryTable[T]{
+		rows:    map[interface{}]*T{},
+		columns: columns,
+		pk:      columns.schema.PrioritizedPrimaryField,
+		tenant:  columns.schema.LookUpField(tenantColumn),
+	}
+}
+
+func fieldValue[T any](row *T, field *schema.Field) (interface{}, bool) {
+	return field.ValueOf(context.Background(), reflect.ValueOf(row).Elem())
+}
+
+func setFieldValue[T any](row *T, field *schema.Field, value interface{}) error {
+	return field.Set(context.Background(), reflect.ValueOf(row).Elem(), value)
+}
+
+func baseOf[T any](row *T) *BaseDbTime {
+	if b, ok := any(row).(interface{ base() *BaseDbTime }); ok {
+		return b.base()
+	}
+	return nil
+}
+
+func inScope[T any](row *T, scope memoryScope) bool {
+	deleted := false
+	if b := baseOf(row); b != nil {
+		deleted = b.DeletedAt.Valid
+	}
+	switch scope {
+	case scopeActive:
+		return !deleted
+	case scopeDeleted:
+		return deleted
+	}
+	return true
+}
+
+// memoryTenant 与 TenantPlugin 一致的租户范围；内存存储没有 AllTenants，超级管理员不带租户时不限定租户
+type memoryTenant struct {
+	field  *schema.Field
+	tenant string
+	all    bool
+}
+
+func (m *memoryTable[T]) tenantOf(ctx context.Context) (memoryTenant, error) {
+	if m.tenant == nil {
+		return memoryTenant{all: true}, nil
+	}
+	tenant, all, err := tenantOf(ctx, isSuperAdmin(ctx) && TenantFrom(ctx) == "")
+	return memoryTenant{field: m.tenant, tenant: tenant, all: all}, err
+}
+
+// visible 记录是否属于租户
+func visible[T any](row *T, t memoryTenant) bool {
+	if t.all {
+		return true
+	}
+	value, _ := fieldValue(row, t.field)
+	return value == t.tenant
+}
+
+// assign 插入时填充租户，属于其他租户时返回 ErrCrossTenant
+func assign[T any](row *T, t memoryTenant) error {
+	if t.all {
+		return nil
+	}
+	if value, zero := fieldValue(row, t.field); zero {
+		return setFieldValue(row, t.field, t.tenant)
+	} else if value != t.tenant {
+		return ErrCrossTenant
+	}
+	return nil
+}
+
+// remove 删除插入的行，用于回滚
+func (m *memoryTable[T]) remove(row *T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, _ := fieldValue(row, m.pk)
+	delete(m.rows, key)
+}
+
+// put 写回一行，用于回滚
+func (m *memoryTable[T]) put(row *T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, _ := fieldValue(row, m.pk)
+	m.rows[key] = row
+}
+
+// insert 插入一行，整数主键为空时自增
+func (m *memoryTable[T]) insert(ctx context.Context, row *T) error {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	if err = assign(row, t); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, zero := fieldValue(row, m.pk)
+	if zero {
+		if !isIntegerKind(m.pk.FieldType.Kind()) {
+			return gorm.ErrPrimaryKeyRequired
+		}
+		m.nextID++
+		if err := setFieldValue(row, m.pk, m.nextID); err != nil {
+			return err
+		}
+		key, _ = fieldValue(row, m.pk)
+	} else if id, ok := toInt64(key); ok && id > m.nextID {
+		m.nextID = id
+	}
+	if _, ok := m.rows[key]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+
+	// 与 gorm 一致，不可写入的字段（<-:false）使用默认值
+	for _, field := range m.columns.schema.Fields {
+		if field.DBName != "" && !field.Creatable {
+			if err := setFieldValue(row, field, reflect.Zero(field.FieldType).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	if b := baseOf(row); b != nil {
+		if b.CreatedAt.IsZero() {
+			b.CreatedAt = now
+		}
+		if b.UpdatedAt.IsZero() {
+			b.UpdatedAt = now
+		}
+		if b.Version == 0 {
+			b.Version = 1
+		}
+	}
+	stored := *row
+	m.rows[key] = &stored
+	return nil
+}
+
+// get 按主键查询
+func (m *memoryTable[T]) get(ctx context.Context, key interface{}, scope memoryScope) (T, error) {
+	var row T
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return row, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.rows[key]
+	if !ok || !inScope(stored, scope) || !visible(stored, t) {
+		return row, gorm.ErrRecordNotFound
+	}
+	return *stored, nil
+}
+
+// find 返回第一条满足条件的记录
+func (m *memoryTable[T]) find(ctx context.Context, match func(row *T) bool) (T, error) {
+	var row T
+	rows, err := m.findAll(ctx, match)
+	if err != nil {
+		return row, err
+	}
+	if len(rows) == 0 {
+		return row, gorm.ErrRecordNotFound
+	}
+	return rows[0], nil
+}
+
+// findAll 返回全部满足条件的记录，顺序不固定
+func (m *memoryTable[T]) findAll(ctx context.Context, match func(row *T) bool) ([]T, error) {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rows []T
+	for _, stored := range m.rows {
+		if inScope(stored, scopeActive) && visible(stored, t) && match(stored) {
+			rows = append(rows, *stored)
+		}
+	}
+	return rows, nil
+}
+
+// update 更新非零字段，给定 columns 时只更新这些字段（包括零值），版本号大于0时做比较交换
+func (m *memoryTable[T]) update(ctx context.Context, row *T, columns ...string) error {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, _ := fieldValue(row, m.pk)
+	stored, ok := m.rows[key]
+	if ok && (!inScope(stored, scopeActive) || !visible(stored, t)) {
+		ok = false
+	}
+
+	b := baseOf(row)
+	if b != nil && b.Version > 0 {
+		if !ok || baseOf(stored).Version != b.Version {
+			return ErrVersionConflict
+		}
+		b.Version++
+	}
+	if !ok {
+		return nil
+	}
+	if b != nil {
+		b.UpdatedAt = time.Now()
+	}
+
+	selected := map[string]bool{}
+	for _, name := range columns {
+		selected[name] = true
+	}
+	updated := *stored
+	for _, field := range m.columns.schema.Fields {
+		// 租户只在插入时确定，不可更新的字段（<-:false）被忽略
+		if field.DBName == "" || field.PrimaryKey || field == m.tenant || !field.Updatable {
+			continue
+		}
+		if len(columns) > 0 && !selected[field.DBName] && field.DBName != "version" && field.DBName != "updated_at" {
+			continue
+		}
+		if value, zero := fieldValue(row, field); !zero || selected[field.DBName] {
+			if err := setFieldValue(&updated, field, value); err != nil {
+				if b != nil && b.Version > 0 {
+					b.Version--
+				}
+				return err
+			}
+		}
+	}
+	m.rows[key] = &updated
+	return nil
+}
+
+// upsert 主键不存在时插入，存在时更新 columns 字段，版本号加1
+func (m *memoryTable[T]) upsert(ctx context.Context, row *T, columns []string) error {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+	if err = assign(row, t); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	key, zero := fieldValue(row, m.pk)
+	stored, ok := m.rows[key]
+	if zero || !ok {
+		m.mu.Unlock()
+		return m.insert(ctx, row)
+	}
+	defer m.mu.Unlock()
+	if !visible(stored, t) {
+		return ErrCrossTenant
+	}
+
+	updated := *stored
+	for _, name := range columns {
+		field := m.columns.schema.LookUpField(name)
+		if field == nil {
+			return fmt.Errorf("unknown column %s", name)
+		}
+		if field == m.tenant {
+			continue
+		}
+		value, _ := fieldValue(row, field)
+		if err := setFieldValue(&updated, field, value); err != nil {
+			return err
+		}
+	}
+	if b := baseOf(&updated); b != nil {
+		b.Version++
+	}
+	m.rows[key] = &updated
+	return nil
+}
+
+// modify 在锁内修改一行（包括已软删除的行），不更新版本号和 updated_at，相当于 Curd.UpdateColumns
+func (m *memoryTable[T]) modify(ctx context.Context, key interface{}, fn func(row *T)) error {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.rows[key]
+	if !ok || !visible(stored, t) {
+		return gorm.ErrRecordNotFound
+	}
+	updated := *stored
+	fn(&updated)
+	m.rows[key] = &updated
+	return nil
+}
+
+// setDeleted 软删除或恢复
+func (m *memoryTable[T]) setDeleted(ctx context.Context, key interface{}, deleted bool) error {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.rows[key]
+	scope := scopeActive
+	if !deleted {
+		scope = scopeDeleted
+	}
+	if !ok || !inScope(stored, scope) || !visible(stored, t) {
+		return gorm.ErrRecordNotFound
+	}
+
+	updated := *stored
+	if b := baseOf(&updated); b != nil {
+		b.DeletedAt = gorm.DeletedAt{}
+		if deleted {
+			b.DeletedAt = gorm.DeletedAt(sql.NullTime{Time: time.Now(), Valid: true})
+		}
+	}
+	m.rows[key] = &updated
+	return nil
+}
+
+// match 按过滤条件筛选记录
+func (m *memoryTable[T]) match(ctx context.Context, q *Query, scope memoryScope) ([]T, error) {
+	t, err := m.tenantOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.columns.Validate(q); err != nil {
+		return nil, err
+	}
+	filters := make([][]interface{}, len(q.Filters))
+	for i, filter := range q.Filters {
+		values, err := m.columns.FilterValues(filter)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = values
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rows []T
+	for _, stored := range m.rows {
+		if !inScope(stored, scope) || !visible(stored, t) {
+			continue
+		}
+		if q.Taxonomy != nil {
+			if id, _ := fieldValue(stored, m.pk); !q.Taxonomy.contains(id) {
+				continue
+			}
+		}
+		matched := true
+		for i, filter := range q.Filters {
+			value, _ := fieldValue(stored, m.columns.fields[filter.Field])
+			if !matchFilter(value, filter.Op, filters[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			rows = append(rows, *stored)
+		}
+	}
+	return rows, nil
+}
+
+// list 过滤、排序后按页码分页
+func (m *memoryTable[T]) list(ctx context.Context, q *Query, scope memoryScope) ([]T, int64, error) {
+	rows, err := m.match(ctx, q, scope)
+	if err != nil {
+		return nil, 0, err
+	}
+	sortRows(rows, cursorSortKeys(m.columns.schema, q.Sorting), false)
+
+	total := int64(len(rows))
+	if q.PageSize > 0 {
+		start := 0
+		if q.PageNo > 0 {
+			start = min((q.PageNo-1)*q.PageSize, len(rows))
+		}
+		rows = rows[start:min(start+q.PageSize, len(rows))]
+	}
+	return rows, total, nil
+}
+
+// listByCursor 过滤、排序后按游标分页
+func (m *memoryTable[T]) listByCursor(ctx context.Context, q *Query, cursor string, limit int) ([]T, *CursorPage, error) {
+	keys := cursorSortKeys(m.columns.schema, q.Sorting)
+	signature := cursorSignature(keys)
+	limit = cursorLimit(limit)
+
+	var token *cursorToken
+	var values []interface{}
+	var err error
+	if cursor != "" {
+		if token, err = decodeCursor(cursor, signature, len(keys)); err != nil {
+			return nil, nil, err
+		}
+		if values, err = cursorValues(keys, token); err != nil {
+			return nil, nil, err
+		}
+	}
+	backward := token != nil && token.Backward
+
+	rows, err := m.match(ctx, q, scopeActive)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != nil {
+		after := rows[:0]
+		for i := range rows {
+			if compareKeys(&rows[i], keys, values, backward) > 0 {
+				after = append(after, rows[i])
+			}
+		}
+		rows = after
+	}
+	sortRows(rows, keys, backward)
+	if len(rows) > limit+1 {
+		rows = rows[:limit+1]
+	}
+	return cursorResult(keys, signature, rows, limit, token)
+}
+
+// sortRows 按排序键排序，backward 时反转
+func sortRows[T any](rows []T, keys []sortKey, backward bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		values := make([]interface{}, len(keys))
+		for k, key := range keys {
+			values[k], _ = fieldValue(&rows[j], key.field)
+		}
+		return compareKeys(&rows[i], keys, values, backward) < 0
+	})
+}
+
+// compareKeys 比较记录与排序键的值，按排序方向返回 -1（在前）、0、1（在后）
+func compareKeys[T any](row *T, keys []sortKey, values []interface{}, backward bool) int {
+	for i, key := range keys {
+		value, _ := fieldValue(row, key.field)
+		result := compareValues(value, values[i])
+		if key.descending != backward {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func matchFilter(value interface{}, op string, values []interface{}) bool {
+	switch op {
+	case OpNe:
+		return compareValues(value, values[0]) != 0
+	case OpLike:
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(values[0].(string)))
+	case OpIn:
+		for _, v := range values {
+			if compareValues(value, v) == 0 {
+				return true
+			}
+		}
+		return false
+	case OpGt:
+		return compareValues(value, values[0]) > 0
+	case OpGte:
+		return compareValues(value, values[0]) >= 0
+	case OpLt:
+		return compareValues(value, values[0]) < 0
+	case OpLte:
+		return compareValues(value, values[0]) <= 0
+	default:
+		return compareValues(value, values[0]) == 0
+	}
+}
+
+// compareValues 比较两个字段值，支持数字、字符串、布尔、时间，其余类型按字符串形式比较（如 uuid.UUID）
+func compareValues(a, b interface{}) int {
+	if at, ok := asTime(a); ok {
+		if bt, ok := asTime(b); ok {
+			return at.Compare(bt)
+		}
+	}
+	if ai, ok := toInt64(a); ok {
+		if bi, ok := toInt64(b); ok {
+			return cmp.Compare(ai, bi)
+		}
+	}
+	if af, ok := toFloat64(a); ok {
+		if bf, ok := toFloat64(b); ok {
+			return cmp.Compare(af, bf)
+		}
+	}
+	if ab, ok := a.(bool); ok {
+		if bb, ok := b.(bool); ok {
+			switch {
+			case ab == bb:
+				return 0
+			case bb:
+				return -1
+			default:
+				return 1
+			}
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func asTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case *time.Time:
+		if t != nil {
+			return *t, true
+		}
+	case gorm.DeletedAt:
+		return t.Time, true
+	case sql.NullTime:
+		return t.Time, true
+	}
+	return time.Time{}, false
+}
+
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64 {
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// checkContext 与数据库实现保持一致，上下文结束后不再执行
+func checkContext(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// MemoryProductStore 线程安全的内存商品存储，用于测试和演示模式
+type MemoryProductStore struct {
+	table       *memoryTable[Product]
+	revisions   *memoryTable[ProductRevision]
+	revisionsMu sync.Mutex // 检查版本是否已存在和插入之间加锁
+	transitions *memoryTable[ProductTransition]
+}
+
+func NewMemoryProductStore() *MemoryProductStore {
+	return &MemoryProductStore{table: newMemoryTable[Product](), revisions: newMemoryTable[ProductRevision](), transitions: newMemoryTable[ProductTransition]()}
+}
+
+func (s *MemoryProductStore) List(ctx context.Context, q *Query) ([]Product, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.table.list(ctx, q, scopeActive)
+}
+
+func (s *MemoryProductStore) ListByCursor(ctx context.Context, q *Query, cursor string, limit int) ([]Product, *CursorPage, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	return s.table.listByCursor(ctx, q, cursor, limit)
+}
+
+func (s *MemoryProductStore) Trash(ctx context.Context, q *Query) ([]Product, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	return s.table.list(ctx, q, scopeDeleted)
+}
+
+func (s *MemoryProductStore) Get(ctx context.Context, id uuid.UUID) (Product, error) {
+	if err := checkContext(ctx); err != nil {
+		return Product{}, err
+	}
+	return s.table.get(ctx, id, scopeActive)
+}
+
+func (s *MemoryProductStore) GetDeleted(ctx context.Context, id uuid.UUID) (Product, error) {
+	if err := checkContext(ctx); err != nil {
+		return Product{}, err
+	}
+	return s.table.get(ctx, id, scopeDeleted)
+}
+
+func (s *MemoryProductStore) Create(ctx context.Context, product *Product) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return s.table.insert(ctx, product)
+}
+
+func (s *MemoryProductStore) Update(ctx context.Context, product *Product) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return s.table.update(ctx, product)
+}
+
+func (s *MemoryProductStore) UpdateColumns(ctx context.Context, product *Product, columns []string) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return s.table.update(ctx, product, columns...)
+}
+
+func (s *MemoryProductStore) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return s.table.setDeleted(ctx, id, true)
+}
+
+func (s *MemoryProductStore) Restore(ctx context.Context, id uuid.UUID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return s.table.setDeleted(ctx, id, false)
+}
+
+// CreateBatch 逐行插入，size 仅为与数据库实现保持签名一致
+func (s *MemoryProductStore) CreateBatch(ctx context.Context, products []*Product, size int) []error {
+	errs := make([]error, len(products))
+	for i, product := range products {
+		if errs[i] = checkContext(ctx); errs[i] == nil {
+			errs[i] = s.table.insert(ctx, product)
+		}
+	}
+	return errs
+}
+
+// CreateAll 逐行插入，任一行失败时删除已插入的行
+func (s *MemoryProductStore) CreateAll(ctx context.Context, products []*Product, size int) []error {
+	errs := s.CreateBatch(ctx, products, size)
+	failed := false
+	for _, err := range errs {
+		failed = failed || err != nil
+	}
+	if failed {
+		for i, product := range products {
+			if errs[i] == nil {
+				s.table.remove(product)
+			}
+		}
+	}
+	return errs
+}
+
+func (s *MemoryProductStore) UpsertBatch(ctx context.Context, products []*Product, columns []string, size int) []error {
+	errs := make([]error, len(products))
+	for i, product := range products {
+		if errs[i] = checkContext(ctx); errs[i] == nil {
+			errs[i] = s.table.upsert(ctx, product, columns)
+		}
+	}
+	return errs
+}
+
+func (s *MemoryProductStore) DeleteByIDs(ctx context.Context, ids []uuid.UUID, size int) []error {
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		if errs[i] = checkContext(ctx); errs[i] == nil {
+			errs[i] = s.table.setDeleted(ctx, id, true)
+		}
+	}
+	return errs
+}
+
+// Search 与 LIKE 检索一致：每个词至少命中一个字段，相关度为命中字段的权重之和
+func (s *MemoryProductStore) Search(ctx context.Context, text string, q *Query) ([]ProductHit, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, 0, ErrEmptySearch
+	}
+	rows, err := s.table.match(ctx, &Query{Filters: q.Filters, Taxonomy: q.Taxonomy}, scopeActive)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := searchRows(rows, terms)
+	total := int64(len(hits))
+	if q.PageSize > 0 {
+		start := min((max(q.PageNo, 1)-1)*q.PageSize, len(hits))
+		hits = hits[start:min(start+q.PageSize, len(hits))]
+	}
+	return hits, total, nil
+}
+
+func (s *MemoryProductStore) Stats(ctx context.Context, q *Query, a *Aggregation) ([]AggregateRow, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.table.match(ctx, &Query{Filters: q.Filters, Taxonomy: q.Taxonomy}, scopeActive)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateRows(s.table.columns, rows, a)
+}
+
+// Revise 更新后保存修改前后的快照，快照保存失败时恢复修改前的商品
+func (s *MemoryProductStore) Revise(ctx context.Context, before Product, product *Product, columns []string, userID string) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	previous, err := s.table.get(ctx, product.ID, scopeActive)
+	if err := s.table.update(ctx, product, columns...); err != nil {
+		return err
+	}
+	after, err := s.table.get(ctx, product.ID, scopeActive)
+	if err == nil {
+		err = s.SaveRevisions(ctx, NewProductRevision(before, ""), NewProductRevision(after, userID))
+		if err != nil {
+			s.table.put(&previous)
+		}
+	}
+	return err
+}
+
+func (s *MemoryProductStore) SaveRevisions(ctx context.Context, revisions ...*ProductRevision) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.revisionsMu.Lock()
+	defer s.revisionsMu.Unlock()
+	for _, revision := range revisions {
+		if _, err := s.Revision(ctx, revision.ProductID, revision.Revision); err == nil {
+			continue
+		}
+		if revision.CreatedAt.IsZero() {
+			revision.CreatedAt = time.Now()
+		}
+		if err := s.revisions.insert(ctx, revision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryProductStore) Revisions(ctx context.Context, id uuid.UUID, pageNo, pageSize int) ([]ProductRevision, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	revisions, err := s.revisions.findAll(ctx, func(revision *ProductRevision) bool {
+		return revision.ProductID == id
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision > revisions[j].Revision })
+
+	total := int64(len(revisions))
+	if pageSize > 0 {
+		start := min((max(pageNo, 1)-1)*pageSize, len(revisions))
+		revisions = revisions[start:min(start+pageSize, len(revisions))]
+	}
+	return revisions, total, nil
+}
+
+func (s *MemoryProductStore) Revision(ctx context.Context, id uuid.UUID, revision int64) (ProductRevision, error) {
+	if err := checkContext(ctx); err != nil {
+		return ProductRevision{}, err
+	}
+	return s.revisions.find(ctx, func(row *ProductRevision) bool {
+		return row.ProductID == id && row.Revision == revision
+	})
+}
+
+// ChangeState 状态更新成功后才保存流转记录
+func (s *MemoryProductStore) ChangeState(ctx context.Context, product *Product, record *ProductTransition) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	product.ProductStatus = record.ToState
+	if err := s.table.update(ctx, product, "product_status"); err != nil {
+		return err
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	return s.transitions.insert(ctx, record)
+}
+
+func (s *MemoryProductStore) Transitions(ctx context.Context, id uuid.UUID, pageNo, pageSize int) ([]ProductTransition, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	transitions, err := s.transitions.findAll(ctx, func(transition *ProductTransition) bool {
+		return transition.ProductID == id
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.SliceStable(transitions, func(i, j int) bool { return transitions[i].CreatedAt.After(transitions[j].CreatedAt) })
+
+	total := int64(len(transitions))
+	if pageSize > 0 {
+		start := min((max(pageNo, 1)-1)*pageSize, len(transitions))
+		transitions = transitions[start:min(start+pageSize, len(transitions))]
+	}
+	return transitions, total, nil
+}
+
+// MemoryUserStore 线程安全的内存用户存储，用于测试和演示模式
+type MemoryUserStore struct {
+	table *memoryTable[User]
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{table: newMemoryTable[User]()}
+}
+
+func (s *MemoryUserStore) Create(ctx context.Context, user *User) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return s.table.insert(ctx, user)
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id int) (User, error) {
+	if err := checkContext(ctx); err != nil {
+		return User{}, err
+	}
+	return s.table.get(ctx, id, scopeActive)
+}
+
+func (s *MemoryUserStore) GetByUsername(ctx context.Context, username string) (User, error) {
+	if err := checkContext(ctx); err != nil {
+		return User{}, err
+	}
+	return s.table.find(ctx, func(user *User) bool {
+		return user.Username == username
+	})
+}
+
+// MemoryCategoryStore 线程安全的内存分类和标签存储，用于测试和演示模式
+type MemoryCategoryStore struct {
+	mu           sync.Mutex // 修改分类树和替换关联时加锁，相当于事务
+	categories   *memoryTable[Category]
+	productLinks *memoryTable[ProductCategory]
+	tags         *memoryTable[Tag]
+	tagLinks     *memoryTable[ProductTag]
+}
+
+func NewMemoryCategoryStore() *MemoryCategoryStore {
+	return &MemoryCategoryStore{
+		categories:   newMemoryTable[Category](),
+		productLinks: newMemoryTable[ProductCategory](),
+		tags:         newMemoryTable[Tag](),
+		tagLinks:     newMemoryTable[ProductTag](),
+	}
+}
+
+func (s *MemoryCategoryStore) Categories(ctx context.Context) ([]Category, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	categories, err := s.categories.findAll(ctx, func(*Category) bool { return true })
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Path < categories[j].Path })
+	return categories, err
+}
+
+func (s *MemoryCategoryStore) Category(ctx context.Context, idOrSlug string) (Category, error) {
+	if err := checkContext(ctx); err != nil {
+		return Category{}, err
+	}
+	if id, err := uuid.Parse(idOrSlug); err == nil {
+		return s.categories.get(ctx, id, scopeActive)
+	}
+	slug := Slugify(idOrSlug)
+	return s.categories.find(ctx, func(category *Category) bool { return category.Slug == slug })
+}
+
+// prepare 检查父分类和 slug，计算路径
+func (s *MemoryCategoryStore) prepare(ctx context.Context, category *Category) (*Category, error) {
+	var parent *Category
+	if category.ParentID != nil {
+		found, err := s.categories.get(ctx, *category.ParentID, scopeActive)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		parent = &found
+	}
+	if _, err := s.categories.find(ctx, func(row *Category) bool {
+		return row.Slug == category.Slug && row.ID != category.ID
+	}); err == nil {
+		return nil, ErrSlugTaken
+	}
+	return parent, nil
+}
+
+func (s *MemoryCategoryStore) CreateCategory(ctx context.Context, category *Category) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	prepareCategory(category)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parent, err := s.prepare(ctx, category)
+	if err != nil {
+		return err
+	}
+	if err := category.place(parent); err != nil {
+		return err
+	}
+	category.CreatedAt, category.UpdatedAt = time.Now(), time.Now()
+	return s.categories.insert(ctx, category)
+}
+
+func (s *MemoryCategoryStore) UpdateCategory(ctx context.Context, category *Category) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	prepareCategory(category)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.categories.get(ctx, category.ID, scopeActive)
+	if err != nil {
+		return err
+	}
+	parent, err := s.prepare(ctx, category)
+	if err != nil {
+		return err
+	}
+	subtree, err := s.categories.findAll(ctx, func(row *Category) bool { return strings.HasPrefix(row.Path, current.Path) })
+	if err != nil {
+		return err
+	}
+	sort.Slice(subtree, func(i, j int) bool { return subtree[i].Path < subtree[j].Path })
+	subtree[0].Name, subtree[0].Slug = category.Name, category.Slug
+	moved, err := moveCategory(subtree, parent)
+	if err != nil {
+		return err
+	}
+	for i := range moved {
+		moved[i].UpdatedAt = time.Now()
+		if err := s.categories.update(ctx, &moved[i], "name", "slug", "parent_id", "path", "depth"); err != nil {
+			return err
+		}
+	}
+	*category = moved[0]
+	return nil
+}
+
+func (s *MemoryCategoryStore) DeleteCategory(ctx context.Context, id uuid.UUID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	category, err := s.categories.get(ctx, id, scopeActive)
+	if err != nil {
+		return err
+	}
+	if _, err := s.categories.find(ctx, func(row *Category) bool {
+		return row.ParentID != nil && *row.ParentID == id
+	}); err == nil {
+		return ErrCategoryNotEmpty
+	}
+	links, err := s.productLinks.findAll(ctx, func(link *ProductCategory) bool { return link.CategoryID == id })
+	if err != nil {
+		return err
+	}
+	for i := range links {
+		s.productLinks.remove(&links[i])
+	}
+	s.categories.remove(&category)
+	return nil
+}
+
+func (s *MemoryCategoryStore) SetProductCategories(ctx context.Context, productID uuid.UUID, categoryIDs []uuid.UUID) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	categoryIDs = uniqueIDs(categoryIDs)
+	for _, id := range categoryIDs {
+		if _, err := s.categories.get(ctx, id, scopeActive); errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		} else if err != nil {
+			return err
+		}
+	}
+	links, err := s.productLinks.findAll(ctx, func(link *ProductCategory) bool { return link.ProductID == productID })
+	if err != nil {
+		return err
+	}
+	for i := range links {
+		s.productLinks.remove(&links[i])
+	}
+	for _, id := range categoryIDs {
+		link := &ProductCategory{ID: uuid.New(), ProductID: productID, CategoryID: id, CreatedAt: time.Now()}
+		if err := s.productLinks.insert(ctx, link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryCategoryStore) ProductCategories(ctx context.Context, productID uuid.UUID) ([]Category, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	links, err := s.productLinks.findAll(ctx, func(link *ProductCategory) bool { return link.ProductID == productID })
+	if err != nil {
+		return nil, err
+	}
+	categories := []Category{}
+	for _, link := range links {
+		if category, err := s.categories.get(ctx, link.CategoryID, scopeActive); err == nil {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Path < categories[j].Path })
+	return categories, nil
+}
+
+func (s *MemoryCategoryStore) SetProductTags(ctx context.Context, productID uuid.UUID, names []string) ([]string, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	names, err := NormalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links, err := s.tagLinks.findAll(ctx, func(link *ProductTag) bool { return link.ProductID == productID })
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		s.tagLinks.remove(&links[i])
+	}
+	for _, name := range names {
+		tag, err := s.tags.find(ctx, func(tag *Tag) bool { return tag.Name == name })
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tag = Tag{ID: uuid.New(), Name: name, CreatedAt: time.Now()}
+			err = s.tags.insert(ctx, &tag)
+		}
+		if err != nil {
+			return nil, err
+		}
+		link := &ProductTag{ID: uuid.New(), ProductID: productID, TagID: tag.ID, CreatedAt: time.Now()}
+		if err := s.tagLinks.insert(ctx, link); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+func (s *MemoryCategoryStore) ProductTags(ctx context.Context, productID uuid.UUID) ([]string, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	links, err := s.tagLinks.findAll(ctx, func(link *ProductTag) bool { return link.ProductID == productID })
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, link := range links {
+		if tag, err := s.tags.get(ctx, link.TagID, scopeActive); err == nil {
+			names = append(names, tag.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *MemoryCategoryStore) Tags(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	prefix = normalizeTagPrefix(prefix)
+	tags, err := s.tags.findAll(ctx, func(tag *Tag) bool { return strings.HasPrefix(tag.Name, prefix) })
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	if limit > 0 && len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+func (s *MemoryCategoryStore) Taxonomy(ctx context.Context, category *Category, tags []string) (*Taxonomy, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	var categoryLinks []ProductCategory
+	if category != nil {
+		descendants, err := s.categories.findAll(ctx, func(row *Category) bool { return strings.HasPrefix(row.Path, category.Path) })
+		if err != nil {
+			return nil, err
+		}
+		ids := map[uuid.UUID]bool{}
+		for _, descendant := range descendants {
+			ids[descendant.ID] = true
+		}
+		if categoryLinks, err = s.productLinks.findAll(ctx, func(link *ProductCategory) bool { return ids[link.CategoryID] }); err != nil {
+			return nil, err
+		}
+	}
+	named, err := s.tags.findAll(ctx, func(tag *Tag) bool { return len(tags) > 0 && slices.Contains(tags, tag.Name) })
+	if err != nil {
+		return nil, err
+	}
+	ids := map[uuid.UUID]bool{}
+	for _, tag := range named {
+		ids[tag.ID] = true
+	}
+	tagLinks, err := s.tagLinks.findAll(ctx, func(link *ProductTag) bool { return ids[link.TagID] })
+	if err != nil {
+		return nil, err
+	}
+	taxonomy := &Taxonomy{tags: tags, products: map[uuid.UUID]bool{}}
+	if category != nil {
+		taxonomy.path = category.Path
+	}
+	for _, id := range matchProducts(category != nil, categoryLinks, len(tags), tagLinks) {
+		taxonomy.products[id] = true
+	}
+	return taxonomy, nil
+}
+
+// MemoryReviewStore 线程安全的内存评价存储，评分汇总写入 products 的内存表
+type MemoryReviewStore struct {
+	mu       sync.Mutex // 评价和评分汇总的修改加锁，相当于事务
+	products *memoryTable[Product]
+	reviews  *memoryTable[Review]
+	votes    *memoryTable[ReviewVote]
+}
+
+func NewMemoryReviewStore(products *MemoryProductStore) *MemoryReviewStore {
+	return &MemoryReviewStore{products: products.table, reviews: newMemoryTable[Review](), votes: newMemoryTable[ReviewVote]()}
+}
+
+// addStats 按增量更新商品的评分汇总
+func (s *MemoryReviewStore) addStats(ctx context.Context, productID uuid.UUID, count, score int64) error {
+	return s.products.modify(ctx, productID, func(product *Product) {
+		product.Rating.add(count, score)
+	})
+}
+
+func (s *MemoryReviewStore) Reviews(ctx context.Context, productID uuid.UUID, by string, pageNo, pageSize int) ([]Review, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	if _, ok := ReviewSorts[by]; !ok {
+		return nil, 0, ErrInvalidReviewSort
+	}
+	reviews, err := s.reviews.findAll(ctx, func(review *Review) bool { return review.ProductID == productID })
+	if err != nil {
+		return nil, 0, err
+	}
+	sortReviews(reviews, by)
+
+	total := int64(len(reviews))
+	if pageSize > 0 {
+		start := min((max(pageNo, 1)-1)*pageSize, len(reviews))
+		reviews = reviews[start:min(start+pageSize, len(reviews))]
+	}
+	return reviews, total, nil
+}
+
+func (s *MemoryReviewStore) Review(ctx context.Context, productID uuid.UUID, userID string) (Review, error) {
+	if err := checkContext(ctx); err != nil {
+		return Review{}, err
+	}
+	return s.reviews.find(ctx, func(review *Review) bool {
+		return review.ProductID == productID && review.UserID == userID
+	})
+}
+
+func (s *MemoryReviewStore) CreateReview(ctx context.Context, review *Review) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.Review(ctx, review.ProductID, review.UserID); err == nil {
+		return ErrReviewExists
+	}
+	if _, err := s.products.get(ctx, review.ProductID, scopeAll); err != nil {
+		return err
+	}
+	if review.ID == uuid.Nil {
+		review.ID = uuid.New()
+	}
+	now := time.Now()
+	review.Helpful, review.Version, review.CreatedAt, review.UpdatedAt = 0, 1, now, now
+	if err := s.reviews.insert(ctx, review); err != nil {
+		return err
+	}
+	return s.addStats(ctx, review.ProductID, 1, int64(review.Score))
+}
+
+func (s *MemoryReviewStore) UpdateReview(ctx context.Context, review *Review) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.Review(ctx, review.ProductID, review.UserID)
+	if err != nil {
+		return err
+	}
+	if review.Version > 0 && review.Version != current.Version {
+		return ErrVersionConflict
+	}
+	review.ID, review.TenantID, review.Helpful, review.CreatedAt = current.ID, current.TenantID, current.Helpful, current.CreatedAt
+	review.Version, review.UpdatedAt = current.Version+1, time.Now()
+	if err := s.reviews.update(ctx, review, "score", "text", "updated_at", "version"); err != nil {
+		return err
+	}
+	if delta := review.Score - current.Score; delta != 0 {
+		return s.addStats(ctx, review.ProductID, 0, int64(delta))
+	}
+	return nil
+}
+
+func (s *MemoryReviewStore) DeleteReview(ctx context.Context, productID uuid.UUID, userID string) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := s.Review(ctx, productID, userID)
+	if err != nil {
+		return err
+	}
+	s.reviews.remove(&current)
+	votes, err := s.votes.findAll(ctx, func(vote *ReviewVote) bool { return vote.ReviewID == current.ID })
+	if err != nil {
+		return err
+	}
+	for i := range votes {
+		s.votes.remove(&votes[i])
+	}
+	return s.addStats(ctx, productID, -1, -int64(current.Score))
+}
+
+func (s *MemoryReviewStore) VoteHelpful(ctx context.Context, productID, reviewID uuid.UUID, userID string) (Review, error) {
+	if err := checkContext(ctx); err != nil {
+		return Review{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	review, err := s.reviews.get(ctx, reviewID, scopeActive)
+	if err == nil && review.ProductID != productID {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return review, err
+	}
+	if review.UserID == userID {
+		return review, ErrOwnReview
+	}
+	if _, err := s.votes.find(ctx, func(vote *ReviewVote) bool { return vote.ReviewID == reviewID && vote.UserID == userID }); err == nil {
+		return review, ErrAlreadyVoted
+	}
+	if err := s.votes.insert(ctx, &ReviewVote{ID: uuid.New(), ReviewID: reviewID, UserID: userID, CreatedAt: time.Now()}); err != nil {
+		return review, err
+	}
+	review.Helpful++
+	return review, s.reviews.update(ctx, &review, "helpful")
+}
+
+// MemoryCommentStore 线程安全的内存评论存储
+type MemoryCommentStore struct {
+	mu       sync.Mutex // 评论的修改加锁，相当于事务
+	products *memoryTable[Product]
+	comments *memoryTable[Comment]
+}
+
+func NewMemoryCommentStore(products *MemoryProductStore) *MemoryCommentStore {
+	return &MemoryCommentStore{products: products.table, comments: newMemoryTable[Comment]()}
+}
+
+// page 分页后的评论
+func (s *MemoryCommentStore) page(comments []Comment, pageNo, pageSize int) []Comment {
+	if pageSize > 0 {
+		start := min((max(pageNo, 1)-1)*pageSize, len(comments))
+		comments = comments[start:min(start+pageSize, len(comments))]
+	}
+	return comments
+}
+
+func (s *MemoryCommentStore) Threads(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]CommentThread, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	roots, err := s.comments.findAll(ctx, func(comment *Comment) bool {
+		return comment.ProductID == productID && comment.ParentID == nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sortComments(roots, true)
+
+	total := int64(len(roots))
+	roots = s.page(roots, pageNo, pageSize)
+	threads := make([]CommentThread, len(roots))
+	for i, root := range roots {
+		replies, count, err := s.Replies(ctx, root.ID, 1, CommentReplyPreview)
+		if err != nil {
+			return nil, 0, err
+		}
+		threads[i] = CommentThread{Comment: root, Replies: replies, ReplyCount: count}
+	}
+	return threads, total, nil
+}
+
+func (s *MemoryCommentStore) Replies(ctx context.Context, rootID uuid.UUID, pageNo, pageSize int) ([]Comment, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	replies, err := s.comments.findAll(ctx, func(comment *Comment) bool {
+		return comment.RootID == rootID && comment.ParentID != nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sortComments(replies, false)
+	return s.page(replies, pageNo, pageSize), int64(len(replies)), nil
+}
+
+func (s *MemoryCommentStore) Comment(ctx context.Context, id uuid.UUID) (Comment, error) {
+	if err := checkContext(ctx); err != nil {
+		return Comment{}, err
+	}
+	return s.comments.get(ctx, id, scopeActive)
+}
+
+func (s *MemoryCommentStore) CreateComment(ctx context.Context, comment *Comment) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.products.get(ctx, comment.ProductID, scopeActive); err != nil {
+		return err
+	}
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+	comment.RootID = comment.ID
+	if comment.ParentID != nil {
+		parent, err := s.comments.get(ctx, *comment.ParentID, scopeActive)
+		if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && parent.ProductID != comment.ProductID {
+			return ErrParentNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if parent.Removed() {
+			return ErrCommentRemoved
+		}
+		comment.RootID = parent.RootID
+	}
+	now := time.Now()
+	comment.Status, comment.ModeratedBy, comment.ModeratedAt, comment.EditedAt, comment.DeletedAt = CommentPending, "", nil, nil, nil
+	comment.CreatedAt, comment.UpdatedAt = now, now
+	return s.comments.insert(ctx, comment)
+}
+
+func (s *MemoryCommentStore) UpdateComment(ctx context.Context, id uuid.UUID, userID, text string, window time.Duration) (Comment, error) {
+	if err := checkContext(ctx); err != nil {
+		return Comment{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, err := s.comments.get(ctx, id, scopeActive)
+	if err != nil {
+		return comment, err
+	}
+	now := time.Now()
+	if err := comment.Editable(userID, now, window); err != nil {
+		return comment, err
+	}
+	comment.Text, comment.Status, comment.EditedAt, comment.UpdatedAt = text, CommentPending, &now, now
+	return comment, s.comments.update(ctx, &comment, "text", "status", "edited_at", "updated_at")
+}
+
+func (s *MemoryCommentStore) DeleteComment(ctx context.Context, id uuid.UUID, userID string, moderator bool) error {
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, err := s.comments.get(ctx, id, scopeActive)
+	if err == nil && comment.DeletedAt != nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !moderator && comment.UserID != userID {
+		return ErrNotCommentAuthor
+	}
+	now := time.Now()
+	comment.DeletedAt, comment.UpdatedAt = &now, now
+	return s.comments.update(ctx, &comment, "deleted_at", "updated_at")
+}
+
+func (s *MemoryCommentStore) ModerationQueue(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]Comment, int64, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	comments, err := s.comments.findAll(ctx, func(comment *Comment) bool {
+		return comment.Status == CommentPending && comment.DeletedAt == nil && (productID == uuid.Nil || comment.ProductID == productID)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sortComments(comments, false)
+	return s.page(comments, pageNo, pageSize), int64(len(comments)), nil
+}
+
+func (s *MemoryCommentStore) Moderate(ctx context.Context, id uuid.UUID, status CommentStatus, moderator string) (Comment, error) {
+	if err := checkContext(ctx); err != nil {
+		return Comment{}, err
+	}
+	if status != CommentApproved && status != CommentHidden {
+		return Comment{}, ErrInvalidCommentStatus
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, err := s.comments.get(ctx, id, scopeActive)
+	if err != nil {
+		return comment, err
+	}
+	if comment.DeletedAt != nil {
+		return comment, ErrCommentRemoved
+	}
+	now := time.Now()
+	comment.Status, comment.ModeratedBy, comment.ModeratedAt, comment.UpdatedAt = status, moderator, &now, now
+	return comment, s.comments.update(ctx, &comment, "status", "moderated_by", "moderated_at", "updated_at")
+}
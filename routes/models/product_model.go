@@ -9,12 +9,14 @@ import (
 
 // Product struct to describe product object.
 type Product struct {
-	ID            uuid.UUID    `gorm:"column:id;type:bigint;not null;primaryKey;auto_increment" json:"id" `
-	UserID        string       `gorm:"column:user_id" json:"user_id" `
-	Title         string       `gorm:"column:title" json:"title" validate:"required,lte=255"`
-	Author        string       `gorm:"column:author" json:"author" validae:"required,lte=255"`
-	ProductStatus int          `gorm:"column:product_status" json:"product_status" validate:"required,len=1"`
+	ID            uuid.UUID    `gorm:"column:id;type:char(36);not null;primaryKey" json:"id" filter:"eq,in" sort:"true"`
+	TenantID      string       `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	UserID        string       `gorm:"column:user_id" json:"user_id" filter:"eq,in" group:"true"`
+	Title         string       `gorm:"column:title" json:"title" validate:"required,lte=255" filter:"eq,like" sort:"true"`
+	Author        string       `gorm:"column:author" json:"author" validae:"required,lte=255" filter:"eq,ne,like,in" sort:"true" group:"true"`
+	ProductStatus ProductState `gorm:"column:product_status" json:"product_status" swaggertype:"string" enums:"draft,in_review,active,archived" filter:"eq,ne,in" sort:"true" group:"true"`
 	ProductAttrs  ProductAttrs `gorm:"column:product_attrs;type:json" json:"product_attrs"`
+	Rating        ReviewStats  `gorm:"embedded" json:"rating"`
 	BaseDbTime
 }
 
@@ -28,9 +30,16 @@ func NewProductRepo() *ProductRepo {
 
 // ProductAttrs struct to describe product attributes.
 type ProductAttrs struct {
-	Picture     string `json:"picture"`
+	Picture     string `json:"picture"`             // URL of the uploaded image (or set by the client)
+	Thumbnail   string `json:"thumbnail,omitempty"` // URL of the thumbnail of the uploaded image
 	Description string `json:"description"`
-	Rating      int    `json:"rating" validate:"-"`
+}
+
+// ReviewStats 商品评价的汇总，只在评价写入时按增量更新，Create、Updates 不写入（<-:false）
+type ReviewStats struct {
+	Average float64 `gorm:"column:rating_avg;<-:false;not null;default:0" json:"average" filter:"gte,lte" sort:"true" metric:"avg,min,max"`
+	Count   int64   `gorm:"column:review_count;<-:false;not null;default:0" json:"count" filter:"gte,lte" sort:"true" metric:"sum,avg,max"`
+	Sum     int64   `gorm:"column:rating_sum;<-:false;not null;default:0" json:"-"`
 }
 
 // Value make the ProductAttrs struct implement the driver.Valuer interface.
@@ -42,10 +51,12 @@ func (b ProductAttrs) Value() (driver.Value, error) {
 // Scan make the ProductAttrs struct implement the sql.Scanner interface.
 // This method simply decodes a JSON-encoded value into the struct fields.
 func (b *ProductAttrs) Scan(value interface{}) error {
-	j, ok := value.([]byte)
-	if !ok {
-		return errors.New("type assertion to []byte failed")
+	switch j := value.(type) {
+	case []byte:
+		return json.Unmarshal(j, &b)
+	case string:
+		// Some drivers (e.g. SQLite) return JSON columns as text.
+		return json.Unmarshal([]byte(j), &b)
 	}
-
-	return json.Unmarshal(j, &b)
+	return errors.New("type assertion to []byte failed")
 }
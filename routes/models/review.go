@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 评价的排序方式
+const (
+	ReviewSortHelpful = "helpful"
+	ReviewSortRecent  = "recent"
+)
+
+// ReviewSorts 评价的排序方式 => 排序子句，相同时按 ID 排序保证分页稳定
+var ReviewSorts = map[string]string{
+	ReviewSortHelpful: "helpful desc, created_at desc, id",
+	ReviewSortRecent:  "created_at desc, id",
+}
+
+var (
+	// ErrReviewExists 每个用户对同一商品只能评价一次
+	ErrReviewExists = errors.New("user has already reviewed this product")
+	// ErrAlreadyVoted 每个用户对同一评价只能投一次有用票
+	ErrAlreadyVoted = errors.New("user has already voted for this review")
+	// ErrOwnReview 作者不能给自己的评价投票
+	ErrOwnReview = errors.New("authors can't vote for their own review")
+	// ErrInvalidReviewSort 未知的排序方式
+	ErrInvalidReviewSort = errors.New("unknown sort of reviews, use helpful or recent")
+)
+
+// Review struct to describe the review of a product by a user, one per user and product.
+type Review struct {
+	ID        uuid.UUID `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string    `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ProductID uuid.UUID `gorm:"column:product_id;type:char(36);not null;uniqueIndex:idx_reviews_author,priority:1" json:"product_id"`
+	UserID    string    `gorm:"column:user_id;size:64;not null;uniqueIndex:idx_reviews_author,priority:2" json:"user_id"`
+	Score     int       `gorm:"column:score;not null" json:"score"`
+	Text      string    `gorm:"column:text" json:"text"`
+	Helpful   int64     `gorm:"column:helpful;not null;default:0" json:"helpful"`
+	Version   int64     `gorm:"column:version;not null;default:1" json:"version"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// GetVersion 当前版本号
+func (r *Review) GetVersion() int64 {
+	return r.Version
+}
+
+// SetVersion 设置版本号
+func (r *Review) SetVersion(version int64) {
+	r.Version = version
+}
+
+type ReviewRepo struct {
+	Curd[Review]
+}
+
+func NewReviewRepo() *ReviewRepo {
+	return &ReviewRepo{}
+}
+
+// ReviewVote struct to describe the helpful vote of a user for a review.
+type ReviewVote struct {
+	ID        uuid.UUID `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string    `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ReviewID  uuid.UUID `gorm:"column:review_id;type:char(36);not null;uniqueIndex:idx_review_votes_voter,priority:1" json:"review_id"`
+	UserID    string    `gorm:"column:user_id;size:64;not null;uniqueIndex:idx_review_votes_voter,priority:2" json:"user_id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// add 增加 count 条评价、score 总分后重新计算平均分
+func (r *ReviewStats) add(count, score int64) {
+	r.Count += count
+	r.Sum += score
+	r.Average = 0
+	if r.Count > 0 {
+		r.Average = float64(r.Sum) / float64(r.Count)
+	}
+}
+
+// sortReviews 按 ReviewSorts 中的规则排序
+func sortReviews(reviews []Review, by string) {
+	sort.Slice(reviews, func(i, j int) bool {
+		a, b := reviews[i], reviews[j]
+		if by == ReviewSortHelpful && a.Helpful != b.Helpful {
+			return a.Helpful > b.Helpful
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"tuxiaocao/pkg/platform/database"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestReviewStore(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+
+	stores := map[string]struct {
+		products ProductStore
+		reviews  ReviewStore
+	}{
+		"gorm":   {NewGormProductStore(), NewGormReviewStore()},
+		"memory": {memory, NewMemoryReviewStore(memory)},
+	}
+	for name, store := range stores {
+		// The rating summary is not written from the product.
+		product := &Product{ID: uuid.New(), Title: "go", Rating: ReviewStats{Average: 5, Count: 9, Sum: 45}}
+		require.NoError(t, store.products.Create(ctx, product), name)
+		require.NoError(t, store.products.Update(ctx, &Product{ID: product.ID, Title: "golang", Rating: ReviewStats{Count: 1}, BaseDbTime: BaseDbTime{Version: 1}}), name)
+		rating := func() ReviewStats {
+			current, err := store.products.Get(ctx, product.ID)
+			require.NoError(t, err, name)
+			return current.Rating
+		}
+		assert.Zero(t, rating().Count, name)
+
+		alice := &Review{ProductID: product.ID, UserID: "alice", Score: 5, Text: "great"}
+		require.NoError(t, store.reviews.CreateReview(ctx, alice), name)
+		bob := &Review{ProductID: product.ID, UserID: "bob", Score: 2}
+		require.NoError(t, store.reviews.CreateReview(ctx, bob), name)
+		assert.ErrorIs(t, store.reviews.CreateReview(ctx, &Review{ProductID: product.ID, UserID: "bob", Score: 1}), ErrReviewExists, name)
+		assert.Equal(t, 3.5, rating().Average, name)
+		assert.Equal(t, int64(2), rating().Count, name)
+
+		// Only the score changes the summary, stale versions are rejected.
+		require.NoError(t, store.reviews.UpdateReview(ctx, &Review{ProductID: product.ID, UserID: "bob", Score: 4, Text: "better", Version: 1}), name)
+		assert.ErrorIs(t, store.reviews.UpdateReview(ctx, &Review{ProductID: product.ID, UserID: "bob", Score: 1, Version: 1}), ErrVersionConflict, name)
+		updated, err := store.reviews.Review(ctx, product.ID, "bob")
+		require.NoError(t, err, name)
+		assert.Equal(t, "better", updated.Text, name)
+		assert.Equal(t, int64(2), updated.Version, name)
+		assert.Equal(t, 4.5, rating().Average, name)
+
+		// One helpful vote per user, not for the own review.
+		voted, err := store.reviews.VoteHelpful(ctx, product.ID, alice.ID, "carol")
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(1), voted.Helpful, name)
+		_, err = store.reviews.VoteHelpful(ctx, product.ID, alice.ID, "carol")
+		assert.ErrorIs(t, err, ErrAlreadyVoted, name)
+		_, err = store.reviews.VoteHelpful(ctx, product.ID, alice.ID, "alice")
+		assert.ErrorIs(t, err, ErrOwnReview, name)
+		_, err = store.reviews.VoteHelpful(ctx, uuid.New(), alice.ID, "dave")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, name)
+
+		reviews, total, err := store.reviews.Reviews(ctx, product.ID, ReviewSortHelpful, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(2), total, name)
+		assert.Equal(t, []string{"alice", "bob"}, []string{reviews[0].UserID, reviews[1].UserID}, name)
+		reviews, _, err = store.reviews.Reviews(ctx, product.ID, ReviewSortRecent, 1, 1)
+		require.NoError(t, err, name)
+		require.Len(t, reviews, 1, name)
+		assert.Equal(t, "bob", reviews[0].UserID, name)
+		_, _, err = store.reviews.Reviews(ctx, product.ID, "score", 1, 10)
+		assert.ErrorIs(t, err, ErrInvalidReviewSort, name)
+
+		require.NoError(t, store.reviews.DeleteReview(ctx, product.ID, "alice"), name)
+		assert.ErrorIs(t, store.reviews.DeleteReview(ctx, product.ID, "alice"), gorm.ErrRecordNotFound, name)
+		assert.Equal(t, ReviewStats{Average: 4, Count: 1, Sum: 4}, rating(), name)
+
+		// Reviews of unknown products are rejected, the summary doesn't change the version of the product.
+		assert.ErrorIs(t, store.reviews.CreateReview(ctx, &Review{ProductID: uuid.New(), UserID: "alice", Score: 1}), gorm.ErrRecordNotFound, name)
+		current, err := store.products.Get(ctx, product.ID)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(2), current.Version, name)
+	}
+}
+
+func TestConcurrentReviews(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+
+	for name, store := range map[string]ReviewStore{"gorm": NewGormReviewStore(), "memory": NewMemoryReviewStore(memory)} {
+		var products ProductStore = NewGormProductStore()
+		if name == "memory" {
+			products = memory
+		}
+		product := &Product{ID: uuid.New(), Title: "go"}
+		require.NoError(t, products.Create(ctx, product), name)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, store.CreateReview(ctx, &Review{ProductID: product.ID, UserID: strconv.Itoa(i), Score: i%5 + 1}), name)
+			}(i)
+		}
+		wg.Wait()
+
+		current, err := products.Get(ctx, product.ID)
+		require.NoError(t, err, name)
+		assert.Equal(t, ReviewStats{Average: 3, Count: 20, Sum: 60}, current.Rating, name)
+	}
+}
+
+func TestReviewUniqueIndex(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	products, reviews := NewGormProductStore(), NewGormReviewStore()
+
+	product := &Product{ID: uuid.New(), Title: "go"}
+	require.NoError(t, products.Create(ctx, product))
+	alice := &Review{ProductID: product.ID, UserID: "alice", Score: 5}
+	require.NoError(t, reviews.CreateReview(ctx, alice))
+
+	// Rows the checks before the insert don't see (e.g. written by a concurrent request) are caught by the unique index.
+	require.NoError(t, database.DB.Exec("INSERT INTO reviews (id, tenant_id, product_id, user_id, score) VALUES (?, ?, ?, ?, ?)",
+		uuid.NewString(), "b", product.ID.String(), "bob", 1).Error)
+	assert.ErrorIs(t, reviews.CreateReview(ctx, &Review{ProductID: product.ID, UserID: "bob", Score: 1}), ErrReviewExists)
+	require.NoError(t, database.DB.Exec("INSERT INTO review_votes (id, tenant_id, review_id, user_id) VALUES (?, ?, ?, ?)",
+		uuid.NewString(), "b", alice.ID.String(), "carol").Error)
+	_, err := reviews.VoteHelpful(ctx, product.ID, alice.ID, "carol")
+	assert.ErrorIs(t, err, ErrAlreadyVoted)
+
+	// Nothing of the failed writes is kept.
+	current, err := products.Get(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ReviewStats{Average: 5, Count: 1, Sum: 5}, current.Rating)
+	review, err := reviews.Review(ctx, product.ID, "alice")
+	require.NoError(t, err)
+	assert.Zero(t, review.Helpful)
+}
+
+func TestPurgeDeletedReviews(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	products, reviews := NewGormProductStore(), NewGormReviewStore()
+
+	purged, kept := &Product{ID: uuid.New(), Title: "purged"}, &Product{ID: uuid.New(), Title: "kept"}
+	for _, product := range []*Product{purged, kept} {
+		require.NoError(t, products.Create(ctx, product))
+		review := &Review{ProductID: product.ID, UserID: "alice", Score: 5, Text: "great"}
+		require.NoError(t, reviews.CreateReview(ctx, review))
+		_, err := reviews.VoteHelpful(ctx, product.ID, review.ID, "bob")
+		require.NoError(t, err)
+	}
+
+	// The reviews of the purged product and their votes go with it, the others stay.
+	purgeProduct(t, ctx, purged.ID)
+	assert.Equal(t, int64(0), countRows(t, "reviews", "product_id = ?", purged.ID))
+	assert.Equal(t, int64(1), countRows(t, "reviews", "product_id = ?", kept.ID))
+	assert.Equal(t, int64(1), countRows(t, "review_votes", "1 = 1"))
+	assert.Equal(t, int64(1), countRows(t, "review_votes", "review_id IN (SELECT id FROM reviews WHERE product_id = ?)", kept.ID))
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// ProductRevision struct to describe a snapshot of a product at one version.
+type ProductRevision struct {
+	ID        uuid.UUID       `gorm:"column:id;type:char(36);not null;primaryKey" json:"id"`
+	TenantID  string          `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	ProductID uuid.UUID       `gorm:"column:product_id;type:char(36);not null;uniqueIndex:idx_product_revisions_revision,priority:1" json:"product_id"`
+	Revision  int64           `gorm:"column:revision;not null;uniqueIndex:idx_product_revisions_revision,priority:2" json:"revision"`
+	UserID    string          `gorm:"column:user_id" json:"user_id"`
+	Snapshot  ProductSnapshot `gorm:"column:snapshot;type:json" json:"snapshot"`
+	CreatedAt time.Time       `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+type ProductRevisionRepo struct {
+	Curd[ProductRevision]
+}
+
+func NewProductRevisionRepo() *ProductRevisionRepo {
+	return &ProductRevisionRepo{}
+}
+
+// ProductSnapshot struct to describe the editable fields of a product.
+type ProductSnapshot struct {
+	Title         string       `json:"title"`
+	Author        string       `json:"author"`
+	ProductStatus ProductState `json:"product_status"`
+	ProductAttrs  ProductAttrs `json:"product_attrs"`
+}
+
+// RevisionColumns 修订恢复时写回的列，包括零值；状态只能通过流转修改，不会被恢复
+var RevisionColumns = []string{"title", "author", "product_attrs"}
+
+// Value make the ProductSnapshot struct implement the driver.Valuer interface.
+// This method simply returns the JSON-encoded representation of the struct.
+func (b ProductSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan make the ProductSnapshot struct implement the sql.Scanner interface.
+// This method simply decodes a JSON-encoded value into the struct fields.
+func (b *ProductSnapshot) Scan(value interface{}) error {
+	switch j := value.(type) {
+	case []byte:
+		return json.Unmarshal(j, &b)
+	case string:
+		return json.Unmarshal([]byte(j), &b)
+	}
+	return errors.New("type assertion to []byte failed")
+}
+
+// NewProductRevision 商品当前版本的快照，userID 为修改人，未知时为空
+func NewProductRevision(product Product, userID string) *ProductRevision {
+	return &ProductRevision{
+		ID:        uuid.New(),
+		TenantID:  product.TenantID,
+		ProductID: product.ID,
+		Revision:  product.Version,
+		UserID:    userID,
+		Snapshot: ProductSnapshot{
+			Title:         product.Title,
+			Author:        product.Author,
+			ProductStatus: product.ProductStatus,
+			ProductAttrs:  product.ProductAttrs,
+		},
+	}
+}
+
+// Apply 将快照写入商品的可编辑字段，状态除外
+func (s ProductSnapshot) Apply(product *Product) {
+	product.Title = s.Title
+	product.Author = s.Author
+	product.ProductAttrs = s.ProductAttrs
+}
+
+// DiffSnapshots 两个快照的字段差异，嵌套字段以点分隔，如 product_attrs.description
+func DiffSnapshots(from, to ProductSnapshot) (LogRecordChanges, error) {
+	before, err := flattenJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := flattenJSON(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := LogRecordChanges{}
+	for name, value := range after {
+		old, ok := before[name]
+		if !ok {
+			old = json.RawMessage("null")
+		}
+		if !bytes.Equal(old, value) {
+			changes[name] = &FieldChange{Before: old, After: value}
+		}
+	}
+	for name, old := range before {
+		if _, ok := after[name]; !ok {
+			changes[name] = &FieldChange{Before: old, After: json.RawMessage("null")}
+		}
+	}
+	return changes, nil
+}
+
+// flattenJSON 对象的字段值（JSON），嵌套对象展开为 parent.child
+func flattenJSON(v interface{}) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]json.RawMessage{}
+	var flatten func(prefix string, raw json.RawMessage) error
+	flatten = func(prefix string, raw json.RawMessage) error {
+		var fields map[string]json.RawMessage
+		if len(raw) == 0 || raw[0] != '{' {
+			values[prefix] = raw
+			return nil
+		}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return err
+		}
+		for name, value := range fields {
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if err := flatten(name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return values, flatten("", raw)
+}
+
+// SaveRevisions 保存快照，同一商品已存在的版本被忽略
+func (r *ProductRevisionRepo) SaveRevisions(revisions []*ProductRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+	return r.conn().Clauses(clause.OnConflict{DoNothing: true}).Create(&revisions).Error
+}
+
+// revisionsOf 商品的修订，最新的在前
+func revisionsOf(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]ProductRevision, int64, error) {
+	repo := NewProductRevisionRepo().WithContext(ctx).Where("product_id = ?", productID)
+	return repo.List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder("revision desc"))
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"tuxiaocao/pkg/platform/database"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestProductRevisions(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+
+	for name, store := range map[string]ProductStore{"gorm": NewGormProductStore(), "memory": NewMemoryProductStore()} {
+		product := &Product{ID: uuid.New(), Title: "first", Author: "alice", ProductStatus: 1, ProductAttrs: ProductAttrs{Description: "old"}}
+		require.NoError(t, store.Create(ctx, product), name)
+		first := *product
+
+		// Update, then store the version before and after.
+		update := &Product{ID: product.ID, Title: "second", ProductAttrs: ProductAttrs{Description: "new"}}
+		update.Version = first.Version
+		require.NoError(t, store.Update(ctx, update), name)
+		second, err := store.Get(ctx, product.ID)
+		require.NoError(t, err, name)
+		require.NoError(t, store.SaveRevisions(ctx, NewProductRevision(first, ""), NewProductRevision(second, "1")), name)
+		// Revisions, which are already stored, are ignored.
+		require.NoError(t, store.SaveRevisions(ctx, NewProductRevision(second, "2")), name)
+
+		revisions, total, err := store.Revisions(ctx, product.ID, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(2), total, name)
+		require.Len(t, revisions, 2, name)
+		assert.Equal(t, second.Version, revisions[0].Revision, name)
+		assert.Equal(t, "1", revisions[0].UserID, name)
+		assert.Equal(t, "old", revisions[1].Snapshot.ProductAttrs.Description, name)
+
+		// Revisions of other tenants are not visible.
+		_, total, err = store.Revisions(WithTenant(context.Background(), "b"), product.ID, 1, 10)
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(0), total, name)
+		_, err = store.Revision(ctx, product.ID, 99)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound, name)
+
+		// Restore the first revision, zero values are written as well.
+		revision, err := store.Revision(ctx, product.ID, first.Version)
+		require.NoError(t, err, name)
+		revision.Snapshot.Author = ""
+		restored := &Product{ID: product.ID}
+		restored.Version = second.Version
+		revision.Snapshot.Apply(restored)
+		require.NoError(t, store.UpdateColumns(ctx, restored, RevisionColumns), name)
+		current, err := store.Get(ctx, product.ID)
+		require.NoError(t, err, name)
+		assert.Equal(t, "first", current.Title, name)
+		assert.Empty(t, current.Author, name)
+		assert.Equal(t, "old", current.ProductAttrs.Description, name)
+		assert.Equal(t, second.Version+1, current.Version, name)
+
+		// The version has changed since.
+		restored.Version = second.Version
+		assert.ErrorIs(t, store.UpdateColumns(ctx, restored, RevisionColumns), ErrVersionConflict, name)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	from := ProductSnapshot{Title: "a", Author: "alice", ProductStatus: 1, ProductAttrs: ProductAttrs{Description: "old", Picture: "a.png"}}
+	to := ProductSnapshot{Title: "b", Author: "alice", ProductStatus: 1, ProductAttrs: ProductAttrs{Description: "new", Picture: "a.png"}}
+
+	changes, err := DiffSnapshots(from, to)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, json.RawMessage(`"a"`), changes["title"].Before)
+	assert.Equal(t, json.RawMessage(`"b"`), changes["title"].After)
+	assert.Equal(t, json.RawMessage(`"new"`), changes["product_attrs.description"].After)
+
+	changes, err = DiffSnapshots(from, from)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestReviseProduct(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+
+	store := NewGormProductStore()
+	product := &Product{ID: uuid.New(), Title: "first", Author: "alice"}
+	require.NoError(t, store.Create(ctx, product))
+	first := *product
+
+	// The update and the snapshots before and after are written together.
+	update := &Product{ID: product.ID, Title: "second"}
+	update.Version = first.Version
+	require.NoError(t, store.Revise(ctx, first, update, nil, "1"))
+	revisions, total, err := store.Revisions(ctx, product.ID, 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, "second", revisions[0].Snapshot.Title)
+	assert.Equal(t, "1", revisions[0].UserID)
+	assert.Equal(t, "first", revisions[1].Snapshot.Title)
+	second, err := store.Get(ctx, product.ID)
+	require.NoError(t, err)
+
+	// A version conflict writes nothing.
+	stale := &Product{ID: product.ID, Title: "stale"}
+	stale.Version = first.Version
+	assert.ErrorIs(t, store.Revise(ctx, second, stale, []string{"title"}, "1"), ErrVersionConflict)
+
+	// The update is rolled back, if the snapshots can't be written.
+	require.NoError(t, database.DB.Exec("DROP TABLE product_revisions").Error)
+	third := &Product{ID: product.ID, Title: "third"}
+	third.Version = second.Version
+	assert.Error(t, store.Revise(ctx, second, third, []string{"title"}, "1"))
+	current, err := store.Get(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", current.Title)
+	assert.Equal(t, second.Version, current.Version)
+}
+
+func TestPurgeDeletedRevisions(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	store := NewGormProductStore()
+
+	purged, kept := &Product{ID: uuid.New(), Title: "purged"}, &Product{ID: uuid.New(), Title: "kept"}
+	for _, product := range []*Product{purged, kept} {
+		require.NoError(t, store.Create(ctx, product))
+		require.NoError(t, store.SaveRevisions(ctx, NewProductRevision(*product, "1")))
+	}
+
+	// The revisions of the purged product go with it, the others stay.
+	purgeProduct(t, ctx, purged.ID)
+	assert.Equal(t, int64(0), countRows(t, "product_revisions", "product_id = ?", purged.ID))
+	assert.Equal(t, int64(1), countRows(t, "product_revisions", "product_id = ?", kept.ID))
+}
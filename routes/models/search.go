@@ -0,0 +1,234 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+const (
+	searchVector      = "search_vector"      // Postgres tsvector 生成列，GIN 索引见 pkg/platform/migrations
+	searchDescription = "search_description" // MySQL 描述生成列，JSON 字段不能建 FULLTEXT 索引
+	searchConfig      = "simple"             // Postgres 分词配置，不做词干处理，与高亮保持一致
+	searchMaxTerms    = 10
+	snippetRunes      = 160
+)
+
+// ErrEmptySearch 检索词为空
+var ErrEmptySearch = errors.New("search query is empty")
+
+// 相关度权重，LIKE 检索和内存存储使用
+const (
+	titleWeight       = 3
+	authorWeight      = 2
+	descriptionWeight = 1
+)
+
+// ProductHit 检索结果，Snippets 为命中字段的高亮片段（HTML 转义，命中词用 <mark> 包裹）
+type ProductHit struct {
+	Product
+	Rank     float64           `gorm:"column:search_rank" json:"rank"`
+	Snippets map[string]string `gorm:"-" json:"snippets"`
+}
+
+// searchTerms 检索词，按空白分割、去重，最多 searchMaxTerms 个
+func searchTerms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		if !seen[term] && len(terms) < searchMaxTerms {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// descriptionColumn 各数据库读取 JSON 中描述的表达式
+func descriptionColumn(dialect string) string {
+	switch dialect {
+	case "postgres":
+		return "product_attrs->>'description'"
+	case "mysql":
+		return searchDescription
+	case "sqlite":
+		return "json_extract(product_attrs, '$.description')"
+	}
+	return "product_attrs"
+}
+
+// searchClauses 检索条件和相关度表达式
+func searchClauses(dialect, text string, terms []string) (where string, whereArgs []interface{}, rank string, rankArgs []interface{}) {
+	switch dialect {
+	case "postgres":
+		query := "websearch_to_tsquery('" + searchConfig + "', ?)"
+		return searchVector + " @@ " + query, []interface{}{text},
+			"ts_rank(" + searchVector + ", " + query + ")", []interface{}{text}
+	case "mysql":
+		match := "MATCH (title, author, " + searchDescription + ") AGAINST (? IN NATURAL LANGUAGE MODE)"
+		return match, []interface{}{text}, match, []interface{}{text}
+	}
+
+	// 每个词至少命中一个字段，相关度为命中字段的权重之和
+	description := descriptionColumn(dialect)
+	fields := []struct {
+		column string
+		weight int
+	}{{"title", titleWeight}, {"author", authorWeight}, {description, descriptionWeight}}
+	conditions := make([]string, 0, len(terms))
+	scores := make([]string, 0, len(terms)*len(fields))
+	for _, term := range terms {
+		var matches []string
+		for _, field := range fields {
+			like := "LOWER(" + field.column + ") LIKE ? ESCAPE '!'"
+			matches = append(matches, like)
+			whereArgs = append(whereArgs, likeContains(term))
+			scores = append(scores, "CASE WHEN "+like+" THEN "+strconv.Itoa(field.weight)+" ELSE 0 END")
+			rankArgs = append(rankArgs, likeContains(term))
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), whereArgs, "(" + strings.Join(scores, " + ") + ")", rankArgs
+}
+
+// Search 按标题、作者和描述检索商品，按相关度排序；q 的过滤条件和分页同样生效，排序被忽略
+func (r *ProductRepo) Search(text string, q *Query) ([]ProductHit, int64, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, 0, ErrEmptySearch
+	}
+	op, err := r.ApplyQuery(&Query{Filters: q.Filters, Taxonomy: q.Taxonomy, PageNo: q.PageNo, PageSize: q.PageSize})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	db := r.localDB
+	where, whereArgs, rank, rankArgs := searchClauses(db.Dialector.Name(), text, terms)
+	db = db.Where(where, whereArgs...)
+
+	var total int64
+	if err = db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []ProductHit
+	db = db.Select("*, "+rank+" AS search_rank", rankArgs...).Order("search_rank DESC").Order("created_at DESC")
+	if op.limit > 0 {
+		db = db.Limit(op.limit).Offset((max(op.offset, 1) - 1) * op.limit)
+	}
+	if err = db.Find(&hits).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range hits {
+		hits[i].Snippets = productSnippets(&hits[i].Product, terms)
+	}
+	return hits, total, nil
+}
+
+// productRank 内存中计算相关度，与 LIKE 检索一致；有词未命中时返回0
+func productRank(product *Product, terms []string) float64 {
+	title, author, description := strings.ToLower(product.Title), strings.ToLower(product.Author), strings.ToLower(product.ProductAttrs.Description)
+	rank := 0
+	for _, term := range terms {
+		score := 0
+		if strings.Contains(title, term) {
+			score += titleWeight
+		}
+		if strings.Contains(author, term) {
+			score += authorWeight
+		}
+		if strings.Contains(description, term) {
+			score += descriptionWeight
+		}
+		if score == 0 {
+			return 0
+		}
+		rank += score
+	}
+	return float64(rank)
+}
+
+// searchRows 内存中检索：过滤命中的记录，按相关度和创建时间倒序排序
+func searchRows(products []Product, terms []string) []ProductHit {
+	var hits []ProductHit
+	for _, product := range products {
+		if rank := productRank(&product, terms); rank > 0 {
+			hits = append(hits, ProductHit{Product: product, Rank: rank, Snippets: productSnippets(&product, terms)})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].CreatedAt.After(hits[j].CreatedAt)
+	})
+	return hits
+}
+
+// productSnippets 命中字段的高亮片段
+func productSnippets(product *Product, terms []string) map[string]string {
+	pattern := termPattern(terms)
+	snippets := map[string]string{}
+	for field, text := range map[string]string{
+		"title":       product.Title,
+		"author":      product.Author,
+		"description": product.ProductAttrs.Description,
+	} {
+		if snippet, ok := highlight(text, pattern); ok {
+			snippets[field] = snippet
+		}
+	}
+	return snippets
+}
+
+// termPattern 匹配任一检索词，忽略大小写
+func termPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	// 长词优先，避免短词截断长词的高亮
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// highlight 截取第一个命中词附近 snippetRunes 个字符，HTML 转义后用 <mark> 包裹命中词
+func highlight(text string, pattern *regexp.Regexp) (string, bool) {
+	first := pattern.FindStringIndex(text)
+	if first == nil {
+		return "", false
+	}
+
+	// 按字符截取，命中词前保留约三分之一
+	start, end := 0, len(text)
+	if utf8.RuneCountInString(text) > snippetRunes {
+		before := []rune(text[:first[0]])
+		from := max(len(before)-snippetRunes/3, 0)
+		start = len(string(before[:from]))
+		rest := []rune(text[start:])
+		end = start + len(string(rest[:min(snippetRunes, len(rest))]))
+	}
+	window := text[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(window, -1) {
+		b.WriteString(html.EscapeString(window[last:loc[0]]))
+		b.WriteString("<mark>" + html.EscapeString(window[loc[0]:loc[1]]) + "</mark>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var searchProducts = []Product{
+	{Title: "The Go Programming Language", Author: "Donovan", ProductAttrs: ProductAttrs{Description: "Go from the ground up"}},
+	{Title: "Concurrency in Go", Author: "Cox-Buday", ProductAttrs: ProductAttrs{Description: "Tools and techniques"}},
+	{Title: "Rust in Action", Author: "McNamara", ProductAttrs: ProductAttrs{Description: "Systems programming <b>without</b> go"}},
+	{Title: "Java", Author: "Gosling", ProductAttrs: ProductAttrs{Description: "Classic"}},
+	{Title: "100% Java!", Author: "Duke"},
+}
+
+func TestProductSearch(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+	memory := NewMemoryProductStore()
+	for _, product := range searchProducts {
+		product.ID = uuid.New()
+		product.ProductStatus = 1
+		require.NoError(t, NewProductRepo().WithContext(ctx).Create(&product))
+		require.NoError(t, memory.Create(ctx, &product))
+	}
+	// 其他租户的商品不会被检索到
+	require.NoError(t, NewProductRepo().WithContext(WithTenant(ctx, "b")).Create(&Product{ID: uuid.New(), Title: "Go"}))
+
+	// LIKE 检索和内存存储结果一致
+	for name, store := range map[string]ProductStore{"gorm": NewGormProductStore(), "memory": memory} {
+		hits, total, err := store.Search(ctx, "go programming", &Query{PageNo: 1, PageSize: 10})
+		require.NoError(t, err, name)
+		assert.Equal(t, int64(2), total, name)
+		require.Len(t, hits, 2, name)
+		assert.Equal(t, "The Go Programming Language", hits[0].Title, name)
+		assert.Equal(t, "The <mark>Go</mark> <mark>Programming</mark> Language", hits[0].Snippets["title"], name)
+		assert.Equal(t, "Systems <mark>programming</mark> &lt;b&gt;without&lt;/b&gt; <mark>go</mark>", hits[1].Snippets["description"], name)
+		assert.Greater(t, hits[0].Rank, hits[1].Rank, name)
+
+		// 通配符和转义字符按字面匹配
+		for text, want := range map[string]int64{"%": 1, "_": 0, "!": 1, "0%": 1} {
+			_, total, err = store.Search(ctx, text, &Query{PageNo: 1, PageSize: 10})
+			require.NoError(t, err, name)
+			assert.Equal(t, want, total, name+" "+text)
+		}
+
+		_, _, err = store.Search(ctx, "  ", &Query{})
+		assert.ErrorIs(t, err, ErrEmptySearch, name)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	text := "aaaa bbbb cccc dddd eeee ffff gggg hhhh iiii jjjj kkkk llll mmmm nnnn oooo pppp qqqq rrrr ssss tttt uuuu vvvv wwww xxxx yyyy zzzz " +
+		"aaaa bbbb cccc dddd eeee ffff gggg hhhh iiii jjjj kkkk llll mmmm nnnn oooo pppp qqqq rrrr ssss tttt target uuuu vvvv wwww xxxx"
+	snippet, ok := highlight(text, termPattern([]string{"target"}))
+	require.True(t, ok)
+	assert.Contains(t, snippet, "<mark>target</mark>")
+	assert.True(t, len([]rune(snippet)) < len([]rune(text)))
+	assert.Equal(t, "…", string([]rune(snippet)[0]))
+
+	_, ok = highlight("nothing", termPattern([]string{"target"}))
+	assert.False(t, ok)
+}
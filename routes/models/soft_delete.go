@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+	"tuxiaocao/pkg/logger"
+)
+
+// IncludeDeleted 查询时包含已软删除的记录
+func (c *Curd[T]) IncludeDeleted() *Curd[T] {
+	if c.localDB == nil {
+		var t T
+		c.localDB = c.conn().Model(&t)
+	}
+	c.localDB = c.localDB.Unscoped()
+	return c
+}
+
+// OnlyDeleted 只查询已软删除的记录（回收站）
+func (c *Curd[T]) OnlyDeleted() *Curd[T] {
+	c.IncludeDeleted()
+	c.localDB = c.localDB.Where("delete_at IS NOT NULL")
+	return c
+}
+
+// Restore 恢复满足条件的软删除记录，没有可恢复的记录时返回 gorm.ErrRecordNotFound
+func (c *Curd[T]) Restore() error {
+	var t T
+	if c.localDB == nil {
+		c.localDB = c.conn().Model(&t)
+	}
+	db := c.localDB.Unscoped().Model(&t).Where("delete_at IS NOT NULL").Update("delete_at", nil)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Purge 物理删除 before 之前软删除的记录，返回删除的行数
+func (c *Curd[T]) Purge(before time.Time) (int64, error) {
+	var t T
+	if c.localDB == nil {
+		c.localDB = c.conn().Model(&t)
+	}
+	db := c.localDB.Unscoped().Where("delete_at IS NOT NULL AND delete_at < ?", before).Delete(&t)
+	return db.RowsAffected, db.Error
+}
+
+// PurgeDeleted 物理删除超过保留期的软删除商品和用户，不限定租户；商品的修订、流转记录、分类和标签、评价和评论一起删除，
+// 在同一事务内执行，失败时全部回滚
+func PurgeDeleted(ctx context.Context, retention time.Duration) {
+	before := time.Now().Add(-retention)
+	ctx = WithSuperAdmin(ctx)
+
+	var products, users int64
+	err := Transaction(func(tx *Tx) error {
+		// 商品的关联行先于商品删除，关联条件引用待删除的商品
+		purged := purgeable[Product](ctx, tx, before)
+		if err := purgeRows[ProductRevision](ctx, tx, "product_id", purged); err != nil {
+			return err
+		}
+		if err := purgeRows[ProductTransition](ctx, tx, "product_id", purged); err != nil {
+			return err
+		}
+		if err := purgeRows[ProductCategory](ctx, tx, "product_id", purged); err != nil {
+			return err
+		}
+		if err := purgeRows[ProductTag](ctx, tx, "product_id", purged); err != nil {
+			return err
+		}
+		// 投票关联到评价，先于评价删除
+		reviews := Use[Review](tx).WithContext(ctx).AllTenants().conn().Unscoped().
+			Model(&Review{}).Select("id").Where("product_id IN (?)", purged)
+		if err := purgeRows[ReviewVote](ctx, tx, "review_id", reviews); err != nil {
+			return err
+		}
+		if err := purgeRows[Review](ctx, tx, "product_id", purged); err != nil {
+			return err
+		}
+		if err := purgeRows[Comment](ctx, tx, "product_id", purged); err != nil {
+			return err
+		}
+		var err error
+		if products, err = Use[Product](tx).WithContext(ctx).AllTenants().Purge(before); err != nil {
+			return err
+		}
+		users, err = Use[User](tx).WithContext(ctx).AllTenants().Purge(before)
+		return err
+	})
+	if err != nil {
+		logger.Log.Errorf("purge deleted products and users error %v", err)
+		return
+	}
+	logger.Log.Infof("purged %d products and %d users deleted before %s", products, users, before.Format(time.DateTime))
+}
+
+// purgeable before 之前软删除的 T 的 id 子查询，不限定租户
+func purgeable[T any](ctx context.Context, tx *Tx, before time.Time) *gorm.DB {
+	var t T
+	return Use[T](tx).WithContext(ctx).AllTenants().conn().Unscoped().Model(&t).Select("id").
+		Where("delete_at IS NOT NULL AND delete_at < ?", before)
+}
+
+// purgeRows 物理删除 T 中 column 在子查询 parents 结果内的行，不限定租户
+func purgeRows[T any](ctx context.Context, tx *Tx, column string, parents *gorm.DB) error {
+	var t T
+	return Use[T](tx).WithContext(ctx).AllTenants().conn().Unscoped().Where(column+" IN (?)", parents).Delete(&t).Error
+}
+
+// RunPurgeJob 每隔 interval 清理一次回收站，ctx 结束后退出
+func RunPurgeJob(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		PurgeDeleted(ctx, retention)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
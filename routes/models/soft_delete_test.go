@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+	"tuxiaocao/pkg/platform/database"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestSoftDeleteLifecycle(t *testing.T) {
+	openTenantDB(t)
+	a := WithTenant(context.Background(), "a")
+	b := WithTenant(context.Background(), "b")
+	store := NewGormProductStore()
+
+	product := &Product{ID: uuid.New(), Title: "go"}
+	other := &Product{ID: uuid.New(), Title: "other"}
+	require.NoError(t, store.Create(a, product))
+	require.NoError(t, store.Create(b, other))
+
+	// Deleted products are hidden, but kept in the trash.
+	require.NoError(t, store.Delete(a, product.ID))
+	assert.ErrorIs(t, store.Delete(a, product.ID), gorm.ErrRecordNotFound)
+	_, err := store.Get(a, product.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	trash, total, err := store.Trash(a, &Query{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, trash, 1)
+	assert.Equal(t, product.ID, trash[0].ID)
+	assert.True(t, trash[0].DeletedAt.Valid)
+	deleted, err := store.GetDeleted(a, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "go", deleted.Title)
+
+	// The trash of another tenant is empty, and its products can't be restored.
+	_, total, err = store.Trash(b, &Query{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.ErrorIs(t, store.Restore(b, product.ID), gorm.ErrRecordNotFound)
+
+	// Restore brings the product back, products which aren't deleted can't be restored.
+	require.NoError(t, store.Restore(a, product.ID))
+	assert.ErrorIs(t, store.Restore(a, product.ID), gorm.ErrRecordNotFound)
+	found, err := store.Get(a, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "go", found.Title)
+	_, total, err = store.Trash(a, &Query{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	openTenantDB(t)
+	a := WithTenant(context.Background(), "a")
+	b := WithTenant(context.Background(), "b")
+	store := NewGormProductStore()
+
+	old, recent, alive := &Product{ID: uuid.New(), Title: "old"}, &Product{ID: uuid.New(), Title: "recent"}, &Product{ID: uuid.New(), Title: "alive"}
+	other := &Product{ID: uuid.New(), Title: "other"}
+	require.NoError(t, store.Create(a, old))
+	require.NoError(t, store.Create(a, recent))
+	require.NoError(t, store.Create(a, alive))
+	require.NoError(t, store.Create(b, other))
+	user := &User{ID: 1, Username: "old"}
+	require.NoError(t, NewUserRepo().WithContext(a).Create(user))
+	for _, id := range []uuid.UUID{old.ID, recent.ID} {
+		require.NoError(t, store.Delete(a, id))
+	}
+	require.NoError(t, store.Delete(b, other.ID))
+	require.NoError(t, NewUserRepo().WithContext(a).Where("id = ?", user.ID).Delete(&User{}))
+
+	// Move the deletion of all but the recent product out of the retention.
+	past := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, database.DB.Exec("UPDATE products SET delete_at = ? WHERE id IN ?", past, []uuid.UUID{old.ID, other.ID}).Error)
+	require.NoError(t, database.DB.Exec("UPDATE users SET delete_at = ?", past).Error)
+
+	// Purge removes the old products of all tenants, the recent one stays in the trash.
+	PurgeDeleted(context.Background(), 24*time.Hour)
+	var ids []string
+	require.NoError(t, database.DB.Raw("SELECT id FROM products ORDER BY title").Scan(&ids).Error)
+	assert.Equal(t, []string{alive.ID.String(), recent.ID.String()}, ids)
+	var users int64
+	require.NoError(t, database.DB.Raw("SELECT COUNT(*) FROM users").Scan(&users).Error)
+	assert.Equal(t, int64(0), users)
+	assert.NoError(t, store.Restore(a, recent.ID))
+}
+
+// purgeProduct soft-deletes the product, moves the deletion out of the retention and purges it.
+func purgeProduct(t *testing.T, ctx context.Context, id uuid.UUID) {
+	require.NoError(t, NewGormProductStore().Delete(ctx, id))
+	require.NoError(t, database.DB.Exec("UPDATE products SET delete_at = ? WHERE id = ?", time.Now().Add(-48*time.Hour), id).Error)
+	PurgeDeleted(context.Background(), 24*time.Hour)
+}
+
+// countRows counts the rows of the table matching the condition, in all tenants.
+func countRows(t *testing.T, table, query string, args ...interface{}) int64 {
+	var count int64
+	require.NoError(t, database.DB.Raw("SELECT COUNT(*) FROM "+table+" WHERE "+query, args...).Scan(&count).Error)
+	return count
+}
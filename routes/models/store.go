@@ -0,0 +1,800 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ProductStore 商品存储，控制器只依赖该接口，便于替换为内存实现
+// 找不到记录时统一返回 gorm.ErrRecordNotFound，非法查询返回 *QueryError
+type ProductStore interface {
+	// List 按过滤条件、排序、页码分页查询
+	List(ctx context.Context, q *Query) ([]Product, int64, error)
+	// ListByCursor 按过滤条件、排序游标分页查询
+	ListByCursor(ctx context.Context, q *Query, cursor string, limit int) ([]Product, *CursorPage, error)
+	// Trash 查询已软删除的商品
+	Trash(ctx context.Context, q *Query) ([]Product, int64, error)
+	Get(ctx context.Context, id uuid.UUID) (Product, error)
+	GetDeleted(ctx context.Context, id uuid.UUID) (Product, error)
+	Create(ctx context.Context, product *Product) error
+	// Update 更新非零字段，版本号大于0时做比较交换，失败返回 ErrVersionConflict
+	Update(ctx context.Context, product *Product) error
+	// UpdateColumns 更新 columns 字段（包括零值），版本号大于0时做比较交换
+	UpdateColumns(ctx context.Context, product *Product, columns []string) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) error
+	CreateBatch(ctx context.Context, products []*Product, size int) []error
+	// CreateAll 在一个事务中分批插入，任一行失败时全部回滚，返回与 products 一一对应的错误
+	CreateAll(ctx context.Context, products []*Product, size int) []error
+	// UpsertBatch 按主键插入或更新 columns 字段
+	UpsertBatch(ctx context.Context, products []*Product, columns []string, size int) []error
+	DeleteByIDs(ctx context.Context, ids []uuid.UUID, size int) []error
+	// Search 按标题、作者和描述检索，按相关度排序，检索词为空时返回 ErrEmptySearch
+	Search(ctx context.Context, text string, q *Query) ([]ProductHit, int64, error)
+	// Stats 按过滤条件分组统计，排序和分页被忽略
+	Stats(ctx context.Context, q *Query, a *Aggregation) ([]AggregateRow, error)
+	// Revise 在一个事务中更新商品并保存修改前后的快照，columns 为空时更新非零字段，任一写入失败时全部回滚
+	Revise(ctx context.Context, before Product, product *Product, columns []string, userID string) error
+	// SaveRevisions 保存商品快照，同一商品已存在的版本被忽略
+	SaveRevisions(ctx context.Context, revisions ...*ProductRevision) error
+	// Revisions 商品的修订，最新的在前
+	Revisions(ctx context.Context, id uuid.UUID, pageNo, pageSize int) ([]ProductRevision, int64, error)
+	Revision(ctx context.Context, id uuid.UUID, revision int64) (ProductRevision, error)
+	// ChangeState 将商品状态改为 record.ToState 并保存流转记录，版本号大于0时做比较交换
+	ChangeState(ctx context.Context, product *Product, record *ProductTransition) error
+	// Transitions 商品的流转记录，最新的在前
+	Transitions(ctx context.Context, id uuid.UUID, pageNo, pageSize int) ([]ProductTransition, int64, error)
+}
+
+// UserStore 用户存储
+type UserStore interface {
+	Create(ctx context.Context, user *User) error
+	Get(ctx context.Context, id int) (User, error)
+	GetByUsername(ctx context.Context, username string) (User, error)
+}
+
+// CategoryStore 分类和标签存储，分类按 ID 或 slug 查询，找不到时返回 gorm.ErrRecordNotFound
+type CategoryStore interface {
+	// Categories 全部分类，按路径排序
+	Categories(ctx context.Context) ([]Category, error)
+	Category(ctx context.Context, idOrSlug string) (Category, error)
+	// CreateCategory 创建分类，ParentID 为空时为根分类，父分类不存在时返回 ErrCategoryNotFound
+	CreateCategory(ctx context.Context, category *Category) error
+	// UpdateCategory 修改名称和 slug，ParentID 变化时连同子孙分类一起移动
+	UpdateCategory(ctx context.Context, category *Category) error
+	// DeleteCategory 删除没有子分类的分类以及它与商品的关联
+	DeleteCategory(ctx context.Context, id uuid.UUID) error
+	// SetProductCategories 替换商品的分类，分类不存在时返回 ErrCategoryNotFound
+	SetProductCategories(ctx context.Context, productID uuid.UUID, categoryIDs []uuid.UUID) error
+	ProductCategories(ctx context.Context, productID uuid.UUID) ([]Category, error)
+	// SetProductTags 替换商品的标签，不存在的标签自动创建
+	SetProductTags(ctx context.Context, productID uuid.UUID, names []string) ([]string, error)
+	ProductTags(ctx context.Context, productID uuid.UUID) ([]string, error)
+	// Tags 以 prefix 开头的标签，按名称排序，用于自动补全
+	Tags(ctx context.Context, prefix string, limit int) ([]Tag, error)
+	// Taxonomy 属于分类（包括子孙分类）并带有全部标签的商品的条件，category 为 nil 时只按标签过滤
+	Taxonomy(ctx context.Context, category *Category, tags []string) (*Taxonomy, error)
+}
+
+// ReviewStore 商品评价存储，评价的写入和商品评分汇总（Product.Rating）的增量更新在同一事务中完成
+// 评价按商品和作者查询，找不到时返回 gorm.ErrRecordNotFound
+type ReviewStore interface {
+	// Reviews 商品的评价，by 为 ReviewSortHelpful 或 ReviewSortRecent
+	Reviews(ctx context.Context, productID uuid.UUID, by string, pageNo, pageSize int) ([]Review, int64, error)
+	Review(ctx context.Context, productID uuid.UUID, userID string) (Review, error)
+	// CreateReview 创建评价，作者已评价过该商品时返回 ErrReviewExists
+	CreateReview(ctx context.Context, review *Review) error
+	// UpdateReview 修改作者的评分和内容，版本号大于0时做比较交换，失败返回 ErrVersionConflict
+	UpdateReview(ctx context.Context, review *Review) error
+	// DeleteReview 删除作者的评价以及它的投票
+	DeleteReview(ctx context.Context, productID uuid.UUID, userID string) error
+	// VoteHelpful 用户认为商品的评价有用，每人一票，返回更新后的评价
+	VoteHelpful(ctx context.Context, productID, reviewID uuid.UUID, userID string) (Review, error)
+}
+
+// CommentStore 商品评论存储，评论按 RootID 组成讨论串，删除为软删除，删除和隐藏的评论保留在讨论串中
+// 评论找不到时返回 gorm.ErrRecordNotFound
+type CommentStore interface {
+	// Threads 商品的顶层评论，最新的在前，每个讨论串带 CommentReplyPreview 条最早的回复和回复总数
+	Threads(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]CommentThread, int64, error)
+	// Replies 讨论串的全部回复，按时间顺序
+	Replies(ctx context.Context, rootID uuid.UUID, pageNo, pageSize int) ([]Comment, int64, error)
+	Comment(ctx context.Context, id uuid.UUID) (Comment, error)
+	// CreateComment 创建评论或回复，回复的评论不存在时返回 ErrParentNotFound，已删除或隐藏时返回 ErrCommentRemoved
+	CreateComment(ctx context.Context, comment *Comment) error
+	// UpdateComment 作者在修改期限内修改评论，修改后的评论重新等待审核
+	UpdateComment(ctx context.Context, id uuid.UUID, userID, text string, window time.Duration) (Comment, error)
+	// DeleteComment 软删除评论，moderator 为假时只能删除自己的评论
+	DeleteComment(ctx context.Context, id uuid.UUID, userID string, moderator bool) error
+	// ModerationQueue 等待审核的评论，按时间顺序，productID 为 uuid.Nil 时不限定商品
+	ModerationQueue(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]Comment, int64, error)
+	// Moderate 审核通过（CommentApproved）或隐藏（CommentHidden）评论，返回更新后的评论
+	Moderate(ctx context.Context, id uuid.UUID, status CommentStatus, moderator string) (Comment, error)
+}
+
+// GormProductStore 基于 Curd[Product] 的商品存储
+type GormProductStore struct{}
+
+func NewGormProductStore() *GormProductStore {
+	return &GormProductStore{}
+}
+
+func (s *GormProductStore) repo(ctx context.Context) *Curd[Product] {
+	return NewProductRepo().WithContext(ctx)
+}
+
+func (s *GormProductStore) List(ctx context.Context, q *Query) ([]Product, int64, error) {
+	repo := s.repo(ctx)
+	op, err := repo.ApplyQuery(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	return repo.Cached().List(op)
+}
+
+func (s *GormProductStore) ListByCursor(ctx context.Context, q *Query, cursor string, limit int) ([]Product, *CursorPage, error) {
+	repo := s.repo(ctx)
+	op, err := repo.ApplyQuery(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo.ListByCursor(op.SetCursor(cursor).SetLimit(limit))
+}
+
+func (s *GormProductStore) Trash(ctx context.Context, q *Query) ([]Product, int64, error) {
+	repo := s.repo(ctx).OnlyDeleted()
+	op, err := repo.ApplyQuery(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	return repo.List(op)
+}
+
+func (s *GormProductStore) Get(ctx context.Context, id uuid.UUID) (Product, error) {
+	return s.repo(ctx).Cached().Where("id = ?", id).Take()
+}
+
+func (s *GormProductStore) GetDeleted(ctx context.Context, id uuid.UUID) (Product, error) {
+	return s.repo(ctx).OnlyDeleted().Where("id = ?", id).Take()
+}
+
+func (s *GormProductStore) Create(ctx context.Context, product *Product) error {
+	return s.repo(ctx).Create(product)
+}
+
+func (s *GormProductStore) Update(ctx context.Context, product *Product) error {
+	return s.repo(ctx).Where("id = ?", product.ID).Updates(product)
+}
+
+func (s *GormProductStore) UpdateColumns(ctx context.Context, product *Product, columns []string) error {
+	return updateColumns(s.repo(ctx), product, columns)
+}
+
+// updateColumns 更新 columns 以及 updated_at、version 字段
+func updateColumns(repo *Curd[Product], product *Product, columns []string) error {
+	columns = append(columns[:len(columns):len(columns)], "updated_at")
+	if product.Version > 0 {
+		columns = append(columns, "version")
+	}
+	return repo.Where("id = ?", product.ID).Select(columns).Updates(product)
+}
+
+func (s *GormProductStore) Delete(ctx context.Context, id uuid.UUID) error {
+	err := s.repo(ctx).Where("id = ?", id).Delete(&Product{})
+	if errors.Is(err, errNoAffectedRows) {
+		return gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+func (s *GormProductStore) Restore(ctx context.Context, id uuid.UUID) error {
+	return s.repo(ctx).Where("id = ?", id).Restore()
+}
+
+func (s *GormProductStore) CreateBatch(ctx context.Context, products []*Product, size int) []error {
+	return s.repo(ctx).CreateBatch(products, size)
+}
+
+func (s *GormProductStore) CreateAll(ctx context.Context, products []*Product, size int) []error {
+	return s.repo(ctx).CreateAll(products, size)
+}
+
+func (s *GormProductStore) UpsertBatch(ctx context.Context, products []*Product, columns []string, size int) []error {
+	return s.repo(ctx).UpsertBatch(products, []string{"id"}, columns, size)
+}
+
+func (s *GormProductStore) DeleteByIDs(ctx context.Context, ids []uuid.UUID, size int) []error {
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return s.repo(ctx).DeleteByIDs(values, size)
+}
+
+func (s *GormProductStore) Search(ctx context.Context, text string, q *Query) ([]ProductHit, int64, error) {
+	repo := NewProductRepo()
+	repo.WithContext(ctx)
+	return repo.Search(text, q)
+}
+
+func (s *GormProductStore) Stats(ctx context.Context, q *Query, a *Aggregation) ([]AggregateRow, error) {
+	repo := s.repo(ctx)
+	if _, err := repo.ApplyQuery(&Query{Filters: q.Filters, Taxonomy: q.Taxonomy}); err != nil {
+		return nil, err
+	}
+	return repo.Aggregate(a)
+}
+
+func (s *GormProductStore) Revise(ctx context.Context, before Product, product *Product, columns []string, userID string) error {
+	return Transaction(func(tx *Tx) error {
+		var err error
+		if len(columns) == 0 {
+			err = Use[Product](tx).WithContext(ctx).Where("id = ?", product.ID).Updates(product)
+		} else {
+			err = updateColumns(Use[Product](tx).WithContext(ctx), product, columns)
+		}
+		if err != nil {
+			return err
+		}
+		after, err := Use[Product](tx).WithContext(ctx).Where("id = ?", product.ID).Take()
+		if err != nil {
+			return err
+		}
+		repo := NewProductRevisionRepo()
+		repo.WithTx(tx).WithContext(ctx)
+		return repo.SaveRevisions([]*ProductRevision{NewProductRevision(before, ""), NewProductRevision(after, userID)})
+	})
+}
+
+func (s *GormProductStore) SaveRevisions(ctx context.Context, revisions ...*ProductRevision) error {
+	repo := NewProductRevisionRepo()
+	repo.WithContext(ctx)
+	return repo.SaveRevisions(revisions)
+}
+
+func (s *GormProductStore) Revisions(ctx context.Context, id uuid.UUID, pageNo, pageSize int) ([]ProductRevision, int64, error) {
+	return revisionsOf(ctx, id, pageNo, pageSize)
+}
+
+func (s *GormProductStore) Revision(ctx context.Context, id uuid.UUID, revision int64) (ProductRevision, error) {
+	return NewProductRevisionRepo().WithContext(ctx).Where("product_id = ? AND revision = ?", id, revision).Take()
+}
+
+func (s *GormProductStore) ChangeState(ctx context.Context, product *Product, record *ProductTransition) error {
+	product.ProductStatus = record.ToState
+	return Transaction(func(tx *Tx) error {
+		if err := updateColumns(Use[Product](tx).WithContext(ctx), product, []string{"product_status"}); err != nil {
+			return err
+		}
+		return Use[ProductTransition](tx).WithContext(ctx).Create(record)
+	})
+}
+
+func (s *GormProductStore) Transitions(ctx context.Context, id uuid.UUID, pageNo, pageSize int) ([]ProductTransition, int64, error) {
+	return transitionsOf(ctx, id, pageNo, pageSize)
+}
+
+// GormUserStore 基于 Curd[User] 的用户存储
+type GormUserStore struct{}
+
+func NewGormUserStore() *GormUserStore {
+	return &GormUserStore{}
+}
+
+func (s *GormUserStore) Create(ctx context.Context, user *User) error {
+	return NewUserRepo().WithContext(ctx).Create(user)
+}
+
+func (s *GormUserStore) Get(ctx context.Context, id int) (User, error) {
+	return NewUserRepo().WithContext(ctx).Where("id = ?", id).Take()
+}
+
+func (s *GormUserStore) GetByUsername(ctx context.Context, username string) (User, error) {
+	return NewUserRepo().WithContext(ctx).Where("username = ?", username).Take()
+}
+
+// GormCategoryStore 基于 Curd 的分类和标签存储
+type GormCategoryStore struct{}
+
+func NewGormCategoryStore() *GormCategoryStore {
+	return &GormCategoryStore{}
+}
+
+func (s *GormCategoryStore) Categories(ctx context.Context) ([]Category, error) {
+	categories, _, err := NewCategoryRepo().WithContext(ctx).List(NewOP().SetOrder("path"))
+	return categories, err
+}
+
+func (s *GormCategoryStore) Category(ctx context.Context, idOrSlug string) (Category, error) {
+	repo := NewCategoryRepo().WithContext(ctx)
+	if id, err := uuid.Parse(idOrSlug); err == nil {
+		return repo.Where("id = ?", id).Take()
+	}
+	return repo.Where("slug = ?", Slugify(idOrSlug)).Take()
+}
+
+// parentOf 查询父分类，不存在时返回 ErrCategoryNotFound
+func parentOf(ctx context.Context, tx *Tx, parentID *uuid.UUID) (*Category, error) {
+	if parentID == nil {
+		return nil, nil
+	}
+	parent, err := Use[Category](tx).WithContext(ctx).Where("id = ?", *parentID).Take()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCategoryNotFound
+	}
+	return &parent, err
+}
+
+// checkSlug slug 被其他分类使用时返回 ErrSlugTaken
+func checkSlug(ctx context.Context, tx *Tx, category *Category) error {
+	if Use[Category](tx).WithContext(ctx).Where("slug = ? AND id <> ?", category.Slug, category.ID).Count() > 0 {
+		return ErrSlugTaken
+	}
+	return nil
+}
+
+func (s *GormCategoryStore) CreateCategory(ctx context.Context, category *Category) error {
+	prepareCategory(category)
+	return Transaction(func(tx *Tx) error {
+		parent, err := parentOf(ctx, tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := category.place(parent); err != nil {
+			return err
+		}
+		if err := checkSlug(ctx, tx, category); err != nil {
+			return err
+		}
+		return Use[Category](tx).WithContext(ctx).Create(category)
+	})
+}
+
+// UpdateCategory 在一个事务中改写子孙分类的路径
+func (s *GormCategoryStore) UpdateCategory(ctx context.Context, category *Category) error {
+	prepareCategory(category)
+	return Transaction(func(tx *Tx) error {
+		current, err := Use[Category](tx).WithContext(ctx).Where("id = ?", category.ID).Take()
+		if err != nil {
+			return err
+		}
+		parent, err := parentOf(ctx, tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := checkSlug(ctx, tx, category); err != nil {
+			return err
+		}
+		subtree, _, err := Use[Category](tx).WithContext(ctx).Where("path LIKE ? ESCAPE '!'", likePrefix(current.Path)).List(NewOP().SetOrder("path"))
+		if err != nil {
+			return err
+		}
+		subtree[0].Name, subtree[0].Slug = category.Name, category.Slug
+		moved, err := moveCategory(subtree, parent)
+		if err != nil {
+			return err
+		}
+
+		columns := []string{"name", "slug", "parent_id", "path", "depth", "updated_at"}
+		for i := range moved {
+			if i > 0 && moved[i].Path == subtree[i].Path {
+				break
+			}
+			if err := Use[Category](tx).WithContext(ctx).Where("id = ?", moved[i].ID).Select(columns).Updates(&moved[i]); err != nil {
+				return err
+			}
+			columns = []string{"path", "depth", "updated_at"}
+		}
+		*category = moved[0]
+		return nil
+	})
+}
+
+func (s *GormCategoryStore) DeleteCategory(ctx context.Context, id uuid.UUID) error {
+	return Transaction(func(tx *Tx) error {
+		if _, err := Use[Category](tx).WithContext(ctx).Where("id = ?", id).Take(); err != nil {
+			return err
+		}
+		if Use[Category](tx).WithContext(ctx).Where("parent_id = ?", id).Count() > 0 {
+			return ErrCategoryNotEmpty
+		}
+		err := Use[ProductCategory](tx).WithContext(ctx).Where("category_id = ?", id).Delete(&ProductCategory{})
+		if err != nil && !errors.Is(err, errNoAffectedRows) {
+			return err
+		}
+		return Use[Category](tx).WithContext(ctx).Where("id = ?", id).Delete(&Category{})
+	})
+}
+
+func (s *GormCategoryStore) SetProductCategories(ctx context.Context, productID uuid.UUID, categoryIDs []uuid.UUID) error {
+	categoryIDs = uniqueIDs(categoryIDs)
+	return Transaction(func(tx *Tx) error {
+		if len(categoryIDs) > 0 && Use[Category](tx).WithContext(ctx).Where("id IN ?", categoryIDs).Count() != int64(len(categoryIDs)) {
+			return ErrCategoryNotFound
+		}
+		err := Use[ProductCategory](tx).WithContext(ctx).Where("product_id = ?", productID).Delete(&ProductCategory{})
+		if err != nil && !errors.Is(err, errNoAffectedRows) {
+			return err
+		}
+		for _, categoryID := range categoryIDs {
+			link := &ProductCategory{ID: uuid.New(), ProductID: productID, CategoryID: categoryID}
+			if err := Use[ProductCategory](tx).WithContext(ctx).Create(link); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormCategoryStore) ProductCategories(ctx context.Context, productID uuid.UUID) ([]Category, error) {
+	var ids []uuid.UUID
+	err := (&Curd[ProductCategory]{}).WithContext(ctx).Where("product_id = ?", productID).Select("category_id").Scan(&ids)
+	if err != nil || len(ids) == 0 {
+		return []Category{}, err
+	}
+	categories, _, err := NewCategoryRepo().WithContext(ctx).Where("id IN ?", ids).List(NewOP().SetOrder("path"))
+	return categories, err
+}
+
+func (s *GormCategoryStore) SetProductTags(ctx context.Context, productID uuid.UUID, names []string) ([]string, error) {
+	names, err := NormalizeTags(names)
+	if err != nil {
+		return nil, err
+	}
+	return names, Transaction(func(tx *Tx) error {
+		var tags []Tag
+		if len(names) > 0 {
+			if tags, _, err = Use[Tag](tx).WithContext(ctx).Where("name IN ?", names).List(nil); err != nil {
+				return err
+			}
+		}
+		known := map[string]bool{}
+		for _, tag := range tags {
+			known[tag.Name] = true
+		}
+		for _, name := range names {
+			if !known[name] {
+				tag := Tag{ID: uuid.New(), Name: name}
+				if err := Use[Tag](tx).WithContext(ctx).Create(&tag); err != nil {
+					return err
+				}
+				tags = append(tags, tag)
+			}
+		}
+
+		err := Use[ProductTag](tx).WithContext(ctx).Where("product_id = ?", productID).Delete(&ProductTag{})
+		if err != nil && !errors.Is(err, errNoAffectedRows) {
+			return err
+		}
+		for _, tag := range tags {
+			link := &ProductTag{ID: uuid.New(), ProductID: productID, TagID: tag.ID}
+			if err := Use[ProductTag](tx).WithContext(ctx).Create(link); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *GormCategoryStore) ProductTags(ctx context.Context, productID uuid.UUID) ([]string, error) {
+	var ids []uuid.UUID
+	err := (&Curd[ProductTag]{}).WithContext(ctx).Where("product_id = ?", productID).Select("tag_id").Scan(&ids)
+	if err != nil || len(ids) == 0 {
+		return []string{}, err
+	}
+	names := []string{}
+	err = (&Curd[Tag]{}).WithContext(ctx).Where("id IN ?", ids).Select("name").Scan(&names)
+	sort.Strings(names)
+	return names, err
+}
+
+func (s *GormCategoryStore) Tags(ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	tags, _, err := (&Curd[Tag]{}).WithContext(ctx).Where("name LIKE ? ESCAPE '!'", likePrefix(normalizeTagPrefix(prefix))).
+		List(NewOP().SetLimit(limit).SetOrder("name"))
+	return tags, err
+}
+
+func (s *GormCategoryStore) Taxonomy(ctx context.Context, category *Category, tags []string) (*Taxonomy, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	taxonomy := &Taxonomy{tags: tags}
+	if category != nil {
+		taxonomy.path = category.Path
+	}
+	return taxonomy, nil
+}
+
+// GormReviewStore 基于 Curd 的评价存储
+type GormReviewStore struct{}
+
+func NewGormReviewStore() *GormReviewStore {
+	return &GormReviewStore{}
+}
+
+// addReviewStats 按增量更新商品的评价数和总分，再由两者计算平均分，并发写入时由行锁保证一致
+// 分两条语句更新，因为 MySQL 的 SET 会使用同一语句中已更新的值；评分汇总不改变商品的版本号
+func addReviewStats(ctx context.Context, tx *Tx, productID uuid.UUID, count, score int64) error {
+	err := Use[Product](tx).WithContext(ctx).IncludeDeleted().Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+		"review_count": gorm.Expr("review_count + ?", count),
+		"rating_sum":   gorm.Expr("rating_sum + ?", score),
+	})
+	if errors.Is(err, errNoAffectedRows) {
+		return gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = Use[Product](tx).WithContext(ctx).IncludeDeleted().Where("id = ?", productID).UpdateColumns(map[string]interface{}{
+		"rating_avg": gorm.Expr("CASE WHEN review_count > 0 THEN rating_sum * 1.0 / review_count ELSE 0 END"),
+	})
+	if errors.Is(err, errNoAffectedRows) {
+		return nil
+	}
+	return err
+}
+
+func (s *GormReviewStore) Reviews(ctx context.Context, productID uuid.UUID, by string, pageNo, pageSize int) ([]Review, int64, error) {
+	order, ok := ReviewSorts[by]
+	if !ok {
+		return nil, 0, ErrInvalidReviewSort
+	}
+	repo := NewReviewRepo().WithContext(ctx).Where("product_id = ?", productID)
+	return repo.List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder(order))
+}
+
+func (s *GormReviewStore) Review(ctx context.Context, productID uuid.UUID, userID string) (Review, error) {
+	return NewReviewRepo().WithContext(ctx).Where("product_id = ? AND user_id = ?", productID, userID).Take()
+}
+
+func (s *GormReviewStore) CreateReview(ctx context.Context, review *Review) error {
+	if review.ID == uuid.Nil {
+		review.ID = uuid.New()
+	}
+	review.Helpful, review.Version = 0, 1
+	return Transaction(func(tx *Tx) error {
+		if Use[Review](tx).WithContext(ctx).Where("product_id = ? AND user_id = ?", review.ProductID, review.UserID).Count() > 0 {
+			return ErrReviewExists
+		}
+		// 并发创建时由唯一索引拦截
+		if err := Use[Review](tx).WithContext(ctx).Create(review); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrReviewExists
+			}
+			return err
+		}
+		return addReviewStats(ctx, tx, review.ProductID, 1, int64(review.Score))
+	})
+}
+
+func (s *GormReviewStore) UpdateReview(ctx context.Context, review *Review) error {
+	return Transaction(func(tx *Tx) error {
+		current, err := Use[Review](tx).WithContext(ctx).Where("product_id = ? AND user_id = ?", review.ProductID, review.UserID).Take()
+		if err != nil {
+			return err
+		}
+		if review.Version == 0 {
+			review.Version = current.Version
+		}
+		review.ID, review.TenantID, review.Helpful, review.CreatedAt = current.ID, current.TenantID, current.Helpful, current.CreatedAt
+		err = Use[Review](tx).WithContext(ctx).Where("id = ?", review.ID).Select("score", "text", "updated_at", "version").Updates(review)
+		if err != nil {
+			return err
+		}
+		if delta := review.Score - current.Score; delta != 0 {
+			return addReviewStats(ctx, tx, review.ProductID, 0, int64(delta))
+		}
+		return nil
+	})
+}
+
+func (s *GormReviewStore) DeleteReview(ctx context.Context, productID uuid.UUID, userID string) error {
+	return Transaction(func(tx *Tx) error {
+		current, err := Use[Review](tx).WithContext(ctx).Where("product_id = ? AND user_id = ?", productID, userID).Take()
+		if err != nil {
+			return err
+		}
+		// 读取之后被修改或删除时，评分汇总的增量不再正确
+		err = Use[Review](tx).WithContext(ctx).Where("id = ? AND version = ?", current.ID, current.Version).Delete(&Review{})
+		if errors.Is(err, errNoAffectedRows) {
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+		err = Use[ReviewVote](tx).WithContext(ctx).Where("review_id = ?", current.ID).Delete(&ReviewVote{})
+		if err != nil && !errors.Is(err, errNoAffectedRows) {
+			return err
+		}
+		return addReviewStats(ctx, tx, productID, -1, -int64(current.Score))
+	})
+}
+
+func (s *GormReviewStore) VoteHelpful(ctx context.Context, productID, reviewID uuid.UUID, userID string) (Review, error) {
+	var review Review
+	err := Transaction(func(tx *Tx) error {
+		current, err := Use[Review](tx).WithContext(ctx).Where("id = ? AND product_id = ?", reviewID, productID).Take()
+		if err != nil {
+			return err
+		}
+		if current.UserID == userID {
+			return ErrOwnReview
+		}
+		if Use[ReviewVote](tx).WithContext(ctx).Where("review_id = ? AND user_id = ?", reviewID, userID).Count() > 0 {
+			return ErrAlreadyVoted
+		}
+		if err := Use[ReviewVote](tx).WithContext(ctx).Create(&ReviewVote{ID: uuid.New(), ReviewID: reviewID, UserID: userID}); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrAlreadyVoted
+			}
+			return err
+		}
+		err = Use[Review](tx).WithContext(ctx).Where("id = ?", reviewID).UpdateColumns(map[string]interface{}{
+			"helpful": gorm.Expr("helpful + 1"),
+		})
+		if err != nil {
+			return err
+		}
+		review, err = Use[Review](tx).WithContext(ctx).Where("id = ?", reviewID).Take()
+		return err
+	})
+	return review, err
+}
+
+// GormCommentStore 基于 Curd 的评论存储
+type GormCommentStore struct{}
+
+func NewGormCommentStore() *GormCommentStore {
+	return &GormCommentStore{}
+}
+
+func (s *GormCommentStore) Threads(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]CommentThread, int64, error) {
+	roots, total, err := NewCommentRepo().WithContext(ctx).Where("product_id = ? AND parent_id IS NULL", productID).
+		List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder(commentThreadOrder))
+	if err != nil {
+		return nil, 0, err
+	}
+	threads := make([]CommentThread, len(roots))
+	for i, root := range roots {
+		replies, count, err := NewCommentRepo().WithContext(ctx).Where("root_id = ? AND parent_id IS NOT NULL", root.ID).
+			List(NewOP().SetLimit(CommentReplyPreview).SetOrder(commentReplyOrder))
+		if err != nil {
+			return nil, 0, err
+		}
+		threads[i] = CommentThread{Comment: root, Replies: replies, ReplyCount: count}
+	}
+	return threads, total, nil
+}
+
+func (s *GormCommentStore) Replies(ctx context.Context, rootID uuid.UUID, pageNo, pageSize int) ([]Comment, int64, error) {
+	repo := NewCommentRepo().WithContext(ctx).Where("root_id = ? AND parent_id IS NOT NULL", rootID)
+	return repo.List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder(commentReplyOrder))
+}
+
+func (s *GormCommentStore) Comment(ctx context.Context, id uuid.UUID) (Comment, error) {
+	return NewCommentRepo().WithContext(ctx).Where("id = ?", id).Take()
+}
+
+func (s *GormCommentStore) CreateComment(ctx context.Context, comment *Comment) error {
+	if comment.ID == uuid.Nil {
+		comment.ID = uuid.New()
+	}
+	comment.Status, comment.ModeratedBy, comment.ModeratedAt, comment.EditedAt, comment.DeletedAt = CommentPending, "", nil, nil, nil
+	return Transaction(func(tx *Tx) error {
+		if Use[Product](tx).WithContext(ctx).Where("id = ?", comment.ProductID).Count() == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		comment.RootID = comment.ID
+		if comment.ParentID != nil {
+			parent, err := Use[Comment](tx).WithContext(ctx).Where("id = ? AND product_id = ?", *comment.ParentID, comment.ProductID).Take()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrParentNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if parent.Removed() {
+				return ErrCommentRemoved
+			}
+			comment.RootID = parent.RootID
+		}
+		return Use[Comment](tx).WithContext(ctx).Create(comment)
+	})
+}
+
+func (s *GormCommentStore) UpdateComment(ctx context.Context, id uuid.UUID, userID, text string, window time.Duration) (Comment, error) {
+	var comment Comment
+	err := Transaction(func(tx *Tx) error {
+		current, err := Use[Comment](tx).WithContext(ctx).Where("id = ?", id).Take()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := current.Editable(userID, now, window); err != nil {
+			return err
+		}
+		// 删除或隐藏发生在读取之后时不再修改
+		err = Use[Comment](tx).WithContext(ctx).Where("id = ? AND deleted_at IS NULL AND status <> ?", id, CommentHidden).UpdateColumns(map[string]interface{}{
+			"text":       text,
+			"status":     CommentPending,
+			"edited_at":  now,
+			"updated_at": now,
+		})
+		if errors.Is(err, errNoAffectedRows) {
+			return ErrCommentRemoved
+		}
+		if err != nil {
+			return err
+		}
+		comment, err = Use[Comment](tx).WithContext(ctx).Where("id = ?", id).Take()
+		return err
+	})
+	return comment, err
+}
+
+func (s *GormCommentStore) DeleteComment(ctx context.Context, id uuid.UUID, userID string, moderator bool) error {
+	return Transaction(func(tx *Tx) error {
+		current, err := Use[Comment](tx).WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).Take()
+		if err != nil {
+			return err
+		}
+		if !moderator && current.UserID != userID {
+			return ErrNotCommentAuthor
+		}
+		now := time.Now()
+		err = Use[Comment](tx).WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).UpdateColumns(map[string]interface{}{
+			"deleted_at": now,
+			"updated_at": now,
+		})
+		if errors.Is(err, errNoAffectedRows) {
+			return gorm.ErrRecordNotFound
+		}
+		return err
+	})
+}
+
+func (s *GormCommentStore) ModerationQueue(ctx context.Context, productID uuid.UUID, pageNo, pageSize int) ([]Comment, int64, error) {
+	repo := NewCommentRepo().WithContext(ctx).Where("status = ? AND deleted_at IS NULL", CommentPending)
+	if productID != uuid.Nil {
+		repo.Where("product_id = ?", productID)
+	}
+	return repo.List(NewOP().SetOffset(pageNo).SetLimit(pageSize).SetOrder(commentReplyOrder))
+}
+
+func (s *GormCommentStore) Moderate(ctx context.Context, id uuid.UUID, status CommentStatus, moderator string) (Comment, error) {
+	if status != CommentApproved && status != CommentHidden {
+		return Comment{}, ErrInvalidCommentStatus
+	}
+	var comment Comment
+	err := Transaction(func(tx *Tx) error {
+		current, err := Use[Comment](tx).WithContext(ctx).Where("id = ?", id).Take()
+		if err != nil {
+			return err
+		}
+		if current.DeletedAt != nil {
+			return ErrCommentRemoved
+		}
+		now := time.Now()
+		err = Use[Comment](tx).WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).UpdateColumns(map[string]interface{}{
+			"status":       status,
+			"moderated_by": moderator,
+			"moderated_at": now,
+			"updated_at":   now,
+		})
+		if errors.Is(err, errNoAffectedRows) {
+			return ErrCommentRemoved
+		}
+		if err != nil {
+			return err
+		}
+		comment, err = Use[Comment](tx).WithContext(ctx).Where("id = ?", id).Take()
+		return err
+	})
+	return comment, err
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func seedProducts(t *testing.T, store ProductStore, titles ...string) []Product {
+	products := make([]Product, len(titles))
+	for i, title := range titles {
+		products[i] = Product{ID: uuid.New(), UserID: "1", Title: title, Author: "author " + title, ProductStatus: 1}
+		assert.NoError(t, store.Create(WithTenant(context.Background(), "t1"), &products[i]))
+	}
+	return products
+}
+
+// eachProductStore 共享的存储测试，在数据库和内存实现上各运行一次
+func eachProductStore(t *testing.T, test func(t *testing.T, store ProductStore)) {
+	t.Run("gorm", func(t *testing.T) {
+		openTenantDB(t)
+		test(t, NewGormProductStore())
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryProductStore())
+	})
+}
+
+func TestProductStoreList(t *testing.T) {
+	eachProductStore(t, testProductStoreList)
+}
+
+func testProductStoreList(t *testing.T, store ProductStore) {
+	seedProducts(t, store, "go", "rust", "golang", "java")
+
+	// Define a structure for specifying input and output data of a single test case.
+	tests := []struct {
+		description string
+		query       string
+		total       int64
+		titles      []string
+	}{
+		{
+			description: "like filter with sorting",
+			query:       "filter[title][like]=GO&sort=title",
+			total:       2,
+			titles:      []string{"go", "golang"},
+		},
+		{
+			description: "in filter with descending sorting",
+			query:       "filter[author][in]=author go,author java&sort=-title",
+			total:       2,
+			titles:      []string{"java", "go"},
+		},
+		{
+			description: "offset pagination",
+			query:       "sort=title&page_no=2&page_size=3",
+			total:       4,
+			titles:      []string{"rust"},
+		},
+	}
+
+	for _, test := range tests {
+		values, _ := url.ParseQuery(test.query)
+		query, err := ParseQuery(values)
+		assert.NoErrorf(t, err, test.description)
+
+		products, total, err := store.List(WithTenant(context.Background(), "t1"), query)
+
+		assert.NoErrorf(t, err, test.description)
+		assert.Equalf(t, test.total, total, test.description)
+		titles := make([]string, len(products))
+		for i, product := range products {
+			titles[i] = product.Title
+		}
+		assert.Equalf(t, test.titles, titles, test.description)
+	}
+
+	_, _, err := store.List(WithTenant(context.Background(), "t1"), &Query{Sorting: []*SortParam{{SortBy: "user_id"}}})
+	assert.IsType(t, &QueryError{}, err)
+}
+
+func TestProductStoreCursor(t *testing.T) {
+	eachProductStore(t, testProductStoreCursor)
+}
+
+func testProductStoreCursor(t *testing.T, store ProductStore) {
+	seedProducts(t, store, "a", "b", "c", "d", "e")
+	query := &Query{Sorting: []*SortParam{{SortBy: "title"}}}
+
+	first, page, err := store.ListByCursor(WithTenant(context.Background(), "t1"), query, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", first[0].Title)
+	assert.Empty(t, page.PrevCursor)
+
+	second, page, err := store.ListByCursor(WithTenant(context.Background(), "t1"), query, page.NextCursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, []string{second[0].Title, second[1].Title})
+
+	back, _, err := store.ListByCursor(WithTenant(context.Background(), "t1"), query, page.PrevCursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, first, back)
+
+	_, _, err = store.ListByCursor(WithTenant(context.Background(), "t1"), query, "bogus", 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestProductStoreLifecycle(t *testing.T) {
+	eachProductStore(t, testProductStoreLifecycle)
+}
+
+func testProductStoreLifecycle(t *testing.T, store ProductStore) {
+	ctx := WithTenant(context.Background(), "t1")
+	product := seedProducts(t, store, "go")[0]
+
+	assert.ErrorIs(t, store.Create(ctx, &product), gorm.ErrDuplicatedKey)
+	_, err := store.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// 版本号不一致时拒绝更新
+	stale := Product{ID: product.ID, Title: "stale", BaseDbTime: BaseDbTime{Version: product.Version + 1}}
+	assert.ErrorIs(t, store.Update(ctx, &stale), ErrVersionConflict)
+
+	update := Product{ID: product.ID, Title: "golang", BaseDbTime: BaseDbTime{Version: product.Version}}
+	assert.NoError(t, store.Update(ctx, &update))
+	found, err := store.Get(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "golang", found.Title)
+	assert.Equal(t, product.Author, found.Author)
+	assert.Equal(t, product.Version+1, found.Version)
+
+	assert.NoError(t, store.Delete(ctx, product.ID))
+	assert.ErrorIs(t, store.Delete(ctx, product.ID), gorm.ErrRecordNotFound)
+	_, err = store.Get(ctx, product.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	trash, total, err := store.Trash(ctx, &Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, product.ID, trash[0].ID)
+
+	assert.NoError(t, store.Restore(ctx, product.ID))
+	found, err = store.Get(ctx, product.ID)
+	assert.NoError(t, err)
+
+	// 更新和修改前后的快照一起写入
+	revised := Product{ID: product.ID, Title: "rust", BaseDbTime: BaseDbTime{Version: found.Version}}
+	assert.NoError(t, store.Revise(ctx, found, &revised, []string{"title"}, "2"))
+	revisions, total, err := store.Revisions(ctx, product.ID, 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"rust", "golang"}, []string{revisions[0].Snapshot.Title, revisions[1].Snapshot.Title})
+	assert.Equal(t, "2", revisions[0].UserID)
+	assert.ErrorIs(t, store.Revise(ctx, found, &Product{ID: product.ID, Title: "stale", BaseDbTime: BaseDbTime{Version: found.Version}}, []string{"title"}, "2"), ErrVersionConflict)
+}
+
+func TestUserStore(t *testing.T) {
+	t.Run("gorm", func(t *testing.T) {
+		openTenantDB(t)
+		testUserStore(t, NewGormUserStore())
+	})
+	t.Run("memory", func(t *testing.T) {
+		testUserStore(t, NewMemoryUserStore())
+	})
+}
+
+func testUserStore(t *testing.T, store UserStore) {
+	ctx := WithTenant(context.Background(), "t1")
+	user := &User{Username: "tuxiaocao"}
+
+	assert.NoError(t, store.Create(ctx, user))
+	assert.Equal(t, 1, user.ID)
+	found, err := store.GetByUsername(ctx, "tuxiaocao")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	_, err = store.Get(ctx, 2)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
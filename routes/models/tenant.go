@@ -0,0 +1,309 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"tuxiaocao/pkg/platform/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// tenantColumn 带该列的模型按租户隔离
+const tenantColumn = "tenant_id"
+
+const (
+	tenantAllKey    = "tenant:all"    // AllTenants 声明跨租户
+	tenantSkipKey   = "tenant:skip"   // 插件内部查询，条件已限定租户
+	tenantScopedKey = "tenant:scoped" // 语句已加租户条件
+)
+
+var (
+	// ErrTenantRequired 租户模型的查询或写入没有租户
+	ErrTenantRequired = errors.New("tenant is required")
+	// ErrCrossTenant 写入的记录属于其他租户
+	ErrCrossTenant = errors.New("record belongs to another tenant")
+	// ErrNotSuperAdmin 非超级管理员不能跨租户
+	ErrNotSuperAdmin = errors.New("only super-admin can access all tenants")
+)
+
+type tenantKey struct{}
+
+type superAdminKey struct{}
+
+// WithTenant 标记 ctx 的租户，租户模型的查询和写入都限定在该租户内
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFrom ctx 的租户
+func TenantFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// WithSuperAdmin 标记 ctx 为超级管理员（或系统任务），可以通过 AllTenants 跨租户
+func WithSuperAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, superAdminKey{}, true)
+}
+
+func isSuperAdmin(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	admin, _ := ctx.Value(superAdminKey{}).(bool)
+	return admin
+}
+
+// DefaultTenant 没有指定租户的请求和启用租户前的数据所属的租户，来自 DEFAULT_TENANT_ID
+func DefaultTenant() string {
+	if tenant := os.Getenv("DEFAULT_TENANT_ID"); tenant != "" {
+		return tenant
+	}
+	return "default"
+}
+
+// TenantOfHost 未登录请求的租户，按主机名从 TENANT_HOSTS（如 "a.example.com=a,b.example.com=b"）查找，找不到时为 DefaultTenant
+func TenantOfHost(host string) string {
+	for _, pair := range strings.Split(os.Getenv("TENANT_HOSTS"), ",") {
+		name, tenant, ok := strings.Cut(pair, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), host) && strings.TrimSpace(tenant) != "" {
+			return strings.TrimSpace(tenant)
+		}
+	}
+	return DefaultTenant()
+}
+
+// BackfillTenant 将没有租户的商品、用户和审计记录归入 tenant，用原生 SQL，不记录审计，写入后使这些表的缓存失效
+func BackfillTenant(ctx context.Context, tenant string) error {
+	for _, table := range []string{ColumnsOf[Product]().schema.Table, ColumnsOf[User]().schema.Table, ColumnsOf[LogRecord]().schema.Table} {
+		err := database.DB.WithContext(ctx).
+			Exec("UPDATE ? SET tenant_id = ? WHERE tenant_id IS NULL OR tenant_id = ''", clause.Table{Name: table}, tenant).Error
+		if err != nil {
+			return err
+		}
+		invalidateCache(ctx, table)
+	}
+	return nil
+}
+
+// tenantOf 查询的租户范围，all 为 true 时不限定租户
+func tenantOf(ctx context.Context, allTenants bool) (tenant string, all bool, err error) {
+	if allTenants {
+		if !isSuperAdmin(ctx) {
+			return "", false, ErrNotSuperAdmin
+		}
+		return "", true, nil
+	}
+	if tenant = TenantFrom(ctx); tenant == "" {
+		return "", false, ErrTenantRequired
+	}
+	return tenant, false, nil
+}
+
+// AllTenants 不限定租户，ctx 必须是超级管理员（WithSuperAdmin），否则查询和写入返回 ErrNotSuperAdmin
+func (c *Curd[T]) AllTenants() *Curd[T] {
+	c.baseDB = c.conn().Set(tenantAllKey, true)
+	if c.localDB != nil {
+		c.localDB = c.localDB.Set(tenantAllKey, true)
+	}
+	return c
+}
+
+// TenantPlugin GORM 插件，带 tenant_id 列的模型：
+// 查询、更新、删除自动加上 ctx 租户的条件，更新不能修改 tenant_id；
+// 插入时填充 ctx 租户，写入其他租户的记录（包括 upsert 覆盖）返回 ErrCrossTenant。
+// 原生 SQL 不受限制
+type TenantPlugin struct{}
+
+func (TenantPlugin) Name() string {
+	return "tenant"
+}
+
+func (p TenantPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	// 先于其他回调执行，审计等插件读取的条件已限定租户
+	for _, err := range []error{
+		callbacks.Query().Before("*").Register("tenant:scope", p.scope),
+		callbacks.Row().Before("*").Register("tenant:scope", p.scope),
+		callbacks.Update().Before("*").Register("tenant:scope", p.scopeUpdate),
+		callbacks.Delete().Before("*").Register("tenant:scope", p.scopeDelete),
+		callbacks.Create().Before("*").Register("tenant:assign", p.assign),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tenantField 模型的租户字段，非租户模型为 nil
+func tenantField(db *gorm.DB) *schema.Field {
+	if db.Statement.Schema == nil {
+		return nil
+	}
+	return db.Statement.Schema.LookUpField(tenantColumn)
+}
+
+// statementTenant 语句的租户范围
+func statementTenant(db *gorm.DB) (string, bool, error) {
+	if skip, ok := db.Get(tenantSkipKey); ok && skip == true {
+		return "", true, nil
+	}
+	allTenants, ok := db.Get(tenantAllKey)
+	return tenantOf(db.Statement.Context, ok && allTenants == true)
+}
+
+func (p TenantPlugin) scope(db *gorm.DB) {
+	if db.Error != nil || tenantField(db) == nil || db.Statement.SQL.Len() > 0 {
+		return
+	}
+	tenant, all, err := statementTenant(db)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	if all {
+		return
+	}
+	if _, scoped := db.Statement.Settings.LoadOrStore(tenantScopedKey, true); !scoped {
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantColumn}, Value: tenant},
+		}})
+	}
+}
+
+// scopeUpdate 限定租户，且租户只在插入时确定，更新时忽略 tenant_id
+func (p TenantPlugin) scopeUpdate(db *gorm.DB) {
+	p.scopeDelete(db)
+	if db.Error != nil || tenantField(db) == nil {
+		return
+	}
+	if _, all, _ := statementTenant(db); !all {
+		db.Statement.Omits = append(db.Statement.Omits, tenantColumn)
+	}
+}
+
+// scopeDelete 限定租户；租户条件不能让没有条件的全表更新、删除绕过 gorm 的检查
+func (p TenantPlugin) scopeDelete(db *gorm.DB) {
+	if db.Error != nil || tenantField(db) == nil || db.Statement.SQL.Len() > 0 {
+		return
+	}
+	if _, ok := db.Statement.Clauses["WHERE"]; !ok && !db.AllowGlobalUpdate && !hasPrimaryKey(db) {
+		db.AddError(gorm.ErrMissingWhereClause)
+		return
+	}
+	p.scope(db)
+}
+
+// hasPrimaryKey 模型是否带主键，gorm 会将其加入条件
+func hasPrimaryKey(db *gorm.DB) bool {
+	pk := db.Statement.Schema.PrioritizedPrimaryField
+	found := false
+	if pk != nil {
+		eachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+			if _, zero := pk.ValueOf(db.Statement.Context, row); !zero {
+				found = true
+			}
+		})
+	}
+	return found
+}
+
+func (p TenantPlugin) assign(db *gorm.DB) {
+	field := tenantField(db)
+	if db.Error != nil || field == nil {
+		return
+	}
+	tenant, all, err := statementTenant(db)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	if all {
+		return
+	}
+
+	ctx := db.Statement.Context
+	eachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+		value, zero := field.ValueOf(ctx, row)
+		if zero {
+			if err := field.Set(ctx, row, tenant); err != nil {
+				db.AddError(err)
+			}
+		} else if value != tenant {
+			db.AddError(ErrCrossTenant)
+		}
+	})
+	if _, ok := db.Statement.Clauses["ON CONFLICT"]; ok && db.Error == nil {
+		p.checkConflicts(db, tenant)
+	}
+}
+
+// checkConflicts upsert 前检查冲突的记录是否已属于其他租户，避免覆盖其他租户的记录；
+// 检查主键和 ON CONFLICT 的列（MySQL 中任一唯一索引冲突都会更新），无法检查的列返回 ErrCrossTenant
+func (p TenantPlugin) checkConflicts(db *gorm.DB, tenant string) {
+	s := db.Statement.Schema
+	var targets [][]*schema.Field
+	if s.PrioritizedPrimaryField != nil {
+		targets = append(targets, []*schema.Field{s.PrioritizedPrimaryField})
+	}
+	onConflict, _ := db.Statement.Clauses["ON CONFLICT"].Expression.(clause.OnConflict)
+	if len(onConflict.Columns) > 0 {
+		fields := make([]*schema.Field, 0, len(onConflict.Columns))
+		for _, column := range onConflict.Columns {
+			field := s.LookUpField(column.Name)
+			if field == nil {
+				db.AddError(ErrCrossTenant)
+				return
+			}
+			fields = append(fields, field)
+		}
+		if len(fields) > 1 || fields[0] != s.PrioritizedPrimaryField {
+			targets = append(targets, fields)
+		}
+	}
+
+	ctx := db.Statement.Context
+	for _, fields := range targets {
+		// 每行一个条件，主键为零值的行由数据库生成主键，不会冲突
+		var conflicts []clause.Expression
+		eachRow(db.Statement.ReflectValue, func(row reflect.Value) {
+			matches := make([]clause.Expression, 0, len(fields))
+			for _, field := range fields {
+				value, zero := field.ValueOf(ctx, row)
+				if zero && field.PrimaryKey {
+					return
+				}
+				matches = append(matches, clause.Eq{Column: clause.Column{Name: field.DBName}, Value: value})
+			}
+			conflicts = append(conflicts, clause.And(matches...))
+		})
+		if len(conflicts) == 0 {
+			continue
+		}
+
+		var count int64
+		err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Set(tenantSkipKey, true).
+			Model(reflect.New(s.ModelType).Interface()).Unscoped().
+			Where(clause.Or(conflicts...)).
+			Where(fmt.Sprintf("%s IS NULL OR %s <> ?", tenantColumn, tenantColumn), tenant).
+			Count(&count).Error
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		if count > 0 {
+			db.AddError(ErrCrossTenant)
+			return
+		}
+	}
+}
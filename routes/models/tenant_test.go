@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"tuxiaocao/pkg/platform/database"
+	"tuxiaocao/pkg/platform/migrations"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func openTenantDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tenant.db")+"?"+database.SqliteParams), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(TenantPlugin{}))
+	require.NoError(t, db.Use(AuditPlugin{}))
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background(), 0)
+	require.NoError(t, err)
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+}
+
+func TestTenantIsolation(t *testing.T) {
+	openTenantDB(t)
+	a := WithTenant(context.Background(), "a")
+	b := WithTenant(context.Background(), "b")
+
+	alice := &User{ID: 1, Username: "alice", UserRole: "user"}
+	bob := &User{ID: 2, Username: "bob", UserRole: "user"}
+	require.NoError(t, NewUserRepo().WithContext(a).Create(alice))
+	require.NoError(t, NewUserRepo().WithContext(b).Create(bob))
+	assert.Equal(t, "a", alice.TenantID)
+	assert.Equal(t, "b", bob.TenantID)
+
+	// 读取
+	_, err := NewUserRepo().WithContext(b).Where("id = ?", alice.ID).Take()
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	users, total, err := NewUserRepo().WithContext(b).List(NewOP())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "bob", users[0].Username)
+	assert.Equal(t, int64(1), NewUserRepo().WithContext(b).Where("username IN ?", []string{"alice", "bob"}).Count())
+
+	// 写入
+	assert.NoError(t, NewUserRepo().WithContext(b).Where("id = ?", alice.ID).Updates(&User{Username: "mallory"}))
+	assert.ErrorIs(t, NewUserRepo().WithContext(b).Updates(&User{Username: "mallory"}), gorm.ErrMissingWhereClause)
+	assert.ErrorIs(t, NewUserRepo().WithContext(b).Delete(&User{ID: alice.ID}), errNoAffectedRows)
+	assert.ErrorIs(t, NewUserRepo().WithContext(b).Create(&User{ID: 3, TenantID: "a", Username: "eve"}), ErrCrossTenant)
+	errs := NewUserRepo().WithContext(b).UpsertBatch([]*User{{ID: alice.ID, Username: "mallory"}}, []string{"id"}, []string{"username"}, 10)
+	assert.ErrorIs(t, errs[0], ErrCrossTenant)
+	// 租户不能被修改
+	assert.NoError(t, NewUserRepo().WithContext(a).Where("id = ?", alice.ID).Updates(&User{TenantID: "b", UserRole: "admin"}))
+
+	found, err := NewUserRepo().WithContext(a).Where("id = ?", alice.ID).Take()
+	require.NoError(t, err)
+	assert.Equal(t, "alice", found.Username)
+	assert.Equal(t, "a", found.TenantID)
+
+	// 审计记录同样按租户隔离
+	records, _, err := AuditTrail(b, "user", "", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "2", records[0].EntityID)
+}
+
+func TestTenantRequired(t *testing.T) {
+	openTenantDB(t)
+	ctx := context.Background()
+
+	_, err := NewUserRepo().WithContext(ctx).Where("id = ?", 1).Take()
+	assert.ErrorIs(t, err, ErrTenantRequired)
+	assert.ErrorIs(t, NewUserRepo().WithContext(ctx).Create(&User{ID: 1, Username: "alice"}), ErrTenantRequired)
+}
+
+func TestAllTenants(t *testing.T) {
+	openTenantDB(t)
+	require.NoError(t, NewUserRepo().WithContext(WithTenant(context.Background(), "a")).Create(&User{ID: 1, Username: "alice"}))
+	require.NoError(t, NewUserRepo().WithContext(WithTenant(context.Background(), "b")).Create(&User{ID: 2, Username: "bob"}))
+
+	_, _, err := NewUserRepo().WithContext(WithTenant(context.Background(), "a")).AllTenants().List(NewOP())
+	assert.ErrorIs(t, err, ErrNotSuperAdmin)
+
+	_, total, err := NewUserRepo().WithContext(WithSuperAdmin(context.Background())).AllTenants().List(NewOP())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestUpsertConflictColumns(t *testing.T) {
+	openTenantDB(t)
+	a := WithTenant(context.Background(), "a")
+	b := WithTenant(context.Background(), "b")
+	productID := uuid.New()
+	revision := func(title string) *ProductRevision {
+		return &ProductRevision{ID: uuid.New(), ProductID: productID, Revision: 1, Snapshot: ProductSnapshot{Title: title}}
+	}
+	conflict, columns := []string{"product_id", "revision"}, []string{"snapshot"}
+	errs := NewProductRevisionRepo().WithContext(a).UpsertBatch([]*ProductRevision{revision("go")}, conflict, columns, 10)
+	require.Equal(t, []error{nil}, errs)
+
+	// The unique key, not the primary key, conflicts with the row of the other tenant.
+	errs = NewProductRevisionRepo().WithContext(b).UpsertBatch([]*ProductRevision{revision("mallory")}, conflict, columns, 10)
+	assert.ErrorIs(t, errs[0], ErrCrossTenant)
+	// Conflict columns, which are not in the model, can't be checked.
+	errs = NewProductRevisionRepo().WithContext(b).UpsertBatch([]*ProductRevision{revision("mallory")}, []string{"unknown"}, columns, 10)
+	assert.ErrorIs(t, errs[0], ErrCrossTenant)
+	// The own row is updated.
+	errs = NewProductRevisionRepo().WithContext(a).UpsertBatch([]*ProductRevision{revision("golang")}, conflict, columns, 10)
+	require.Equal(t, []error{nil}, errs)
+
+	found, err := NewProductRevisionRepo().WithContext(a).Where("product_id = ?", productID).Take()
+	require.NoError(t, err)
+	assert.Equal(t, "golang", found.Snapshot.Title)
+}
+
+func TestProductStoreTenantIsolation(t *testing.T) {
+	eachProductStore(t, testProductStoreTenantIsolation)
+}
+
+func testProductStoreTenantIsolation(t *testing.T, store ProductStore) {
+	// seedProducts 写入租户 t1
+	a := WithTenant(context.Background(), "t1")
+	b := WithTenant(context.Background(), "b")
+	product := seedProducts(t, store, "go")[0]
+
+	_, err := store.Get(b, product.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, total, err := store.List(b, &Query{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	assert.ErrorIs(t, store.Delete(b, product.ID), gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, store.UpsertBatch(b, []*Product{{ID: product.ID, Title: "mallory"}}, []string{"title"}, 10)[0], ErrCrossTenant)
+	assert.NoError(t, store.Update(b, &Product{ID: product.ID, Title: "mallory"}))
+
+	found, err := store.Get(a, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "go", found.Title)
+	_, err = store.Get(context.Background(), product.ID)
+	assert.ErrorIs(t, err, ErrTenantRequired)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"gorm.io/gorm"
+	"tuxiaocao/pkg/platform/database"
+)
+
+// Tx 事务句柄，通过 Use 或 Curd.WithTx 获取绑定到该事务的仓库
+type Tx struct {
+	db *gorm.DB
+}
+
+// Transaction 在事务中执行 fc，fc 返回错误或发生 panic 时整体回滚（panic 会继续向上抛出）
+//
+//	err := models.Transaction(func(tx *models.Tx) error {
+//		if err := models.Use[models.Product](tx).Create(product); err != nil {
+//			return err
+//		}
+//		return models.NewLogRecordRepo().WithTx(tx).Create(record)
+//	})
+func Transaction(fc func(tx *Tx) error, opts ...*sql.TxOptions) error {
+	return database.DB.Transaction(func(db *gorm.DB) error {
+		return fc(&Tx{db: db})
+	}, opts...)
+}
+
+// Transaction 嵌套事务，基于 SAVEPOINT 实现，内层失败只回滚到保存点，外层可以继续提交
+func (tx *Tx) Transaction(fc func(tx *Tx) error) error {
+	return tx.db.Transaction(func(db *gorm.DB) error {
+		return fc(&Tx{db: db})
+	})
+}
+
+// Use 返回绑定到事务的泛型仓库
+func Use[T any](tx *Tx) *Curd[T] {
+	return &Curd[T]{baseDB: tx.db}
+}
+
+// WithTx 将仓库绑定到事务，需在 Where/Select 等条件之前调用
+func (c *Curd[T]) WithTx(tx *Tx) *Curd[T] {
+	c.baseDB = tx.db
+	c.localDB = nil
+	return c
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countProducts(ctx context.Context) int64 {
+	return NewProductRepo().WithContext(ctx).Count()
+}
+
+func TestTransaction(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+
+	// Commit, both writes are visible.
+	err := Transaction(func(tx *Tx) error {
+		if err := Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "one"}); err != nil {
+			return err
+		}
+		return NewProductRepo().WithTx(tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "two"})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), countProducts(ctx))
+
+	// Rollback on error, the error is returned unchanged.
+	failed := errors.New("failed")
+	err = Transaction(func(tx *Tx) error {
+		require.NoError(t, Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "three"}))
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, int64(2), countProducts(ctx))
+
+	// Rollback on panic, the panic is passed on.
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = Transaction(func(tx *Tx) error {
+			require.NoError(t, Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "four"}))
+			panic("boom")
+		})
+	})
+	assert.Equal(t, int64(2), countProducts(ctx))
+}
+
+func TestNestedTransaction(t *testing.T) {
+	openTenantDB(t)
+	ctx := WithTenant(context.Background(), "a")
+
+	// The failed savepoint is rolled back, the outer transaction commits.
+	failed := errors.New("failed")
+	err := Transaction(func(tx *Tx) error {
+		if err := Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "outer"}); err != nil {
+			return err
+		}
+		err := tx.Transaction(func(tx *Tx) error {
+			require.NoError(t, Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "inner"}))
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		return tx.Transaction(func(tx *Tx) error {
+			return Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "second"})
+		})
+	})
+	require.NoError(t, err)
+	titles := []string{}
+	products, _, err := NewProductRepo().WithContext(ctx).List(NewOP().SetOrder("title"))
+	require.NoError(t, err)
+	for _, product := range products {
+		titles = append(titles, product.Title)
+	}
+	assert.Equal(t, []string{"outer", "second"}, titles)
+
+	// The outer rollback discards the committed savepoint as well.
+	err = Transaction(func(tx *Tx) error {
+		require.NoError(t, tx.Transaction(func(tx *Tx) error {
+			return Use[Product](tx).WithContext(ctx).Create(&Product{ID: uuid.New(), Title: "inner"})
+		}))
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.Equal(t, int64(2), countProducts(ctx))
+}
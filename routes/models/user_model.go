@@ -2,11 +2,12 @@ package models
 
 // User struct to describe User object.
 type User struct {
-	ID           int    `gorm:"column:id;type:bigint;not null;primaryKey;auto_increment" json:"id" `
-	Username     string `gorm:"column:username" json:"username" validate:"required,lte=255"`
-	PasswordHash string `gorm:"column:password_hash" json:"password_hash,omitempty" validate:"required,lte=255"`
-	UserStatus   int    `gorm:"column:user_status" json:"user_status" validate:"required,len=1"`
-	UserRole     string `gorm:"column:user_role" json:"user_role" validate:"required,lte=25"`
+	ID           int    `gorm:"column:id;primaryKey;autoIncrement" json:"id" filter:"eq,in" sort:"true"`
+	TenantID     string `gorm:"column:tenant_id;size:64;index" json:"tenant_id"`
+	Username     string `gorm:"column:username" json:"username" validate:"required,lte=255" filter:"eq,like" sort:"true"`
+	PasswordHash string `gorm:"column:password_hash" json:"password_hash,omitempty" validate:"required,lte=255" audit:"-"`
+	UserStatus   int    `gorm:"column:user_status" json:"user_status" validate:"required,len=1" filter:"eq,in" group:"true"`
+	UserRole     string `gorm:"column:user_role" json:"user_role" validate:"required,lte=25" filter:"eq,in" group:"true"`
 	BaseDbTime
 }
 type UserRepo struct {
@@ -0,0 +1,68 @@
+package queries
+
+import (
+	"tuxiaocao/routes/models"
+
+	"github.com/google/uuid"
+)
+
+// ProductBulk struct to describe bulk operation on products.
+type ProductBulk struct {
+	Action string           `json:"action" validate:"required,oneof=create upsert delete"`
+	Items  []models.Product `json:"items" validate:"max=10000"`
+	IDs    []uuid.UUID      `json:"ids" validate:"max=10000"`
+}
+
+// BulkResult struct to describe result of one item of a bulk operation.
+type BulkResult struct {
+	Index int         `json:"index"`
+	ID    string      `json:"id,omitempty"`
+	Error bool        `json:"error"`
+	Msg   interface{} `json:"msg"`
+}
+
+// ImportError struct to describe a failed line of a product import.
+type ImportError struct {
+	Line int         `json:"line"`
+	ID   string      `json:"id,omitempty"`
+	Msg  interface{} `json:"msg"`
+}
+
+// UploadedImage struct to describe a stored product image.
+type UploadedImage struct {
+	Picture     string `json:"picture"`
+	Thumbnail   string `json:"thumbnail"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// CategoryBody struct to describe a created or updated category.
+type CategoryBody struct {
+	Name     string     `json:"name" validate:"required,lte=255"`
+	Slug     string     `json:"slug" validate:"lte=255"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// ProductCategories struct to describe the categories of a product.
+type ProductCategories struct {
+	CategoryIDs []uuid.UUID `json:"category_ids" validate:"max=50"`
+}
+
+// ProductTags struct to describe the tags of a product.
+type ProductTags struct {
+	Tags []string `json:"tags"`
+}
+
+// ReviewBody struct to describe a created or updated review.
+type ReviewBody struct {
+	Score int    `json:"score" validate:"required,min=1,max=5"`
+	Text  string `json:"text" validate:"lte=5000"`
+}
+
+// CommentBody struct to describe a created comment or reply, or the new text of an edited comment.
+type CommentBody struct {
+	ParentID *uuid.UUID `json:"parent_id"`
+	Text     string     `json:"text" validate:"required,lte=5000"`
+}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"tuxiaocao/middleware"
 	"tuxiaocao/pkg/logger"
 	controllers2 "tuxiaocao/routes/controllers"
 )
@@ -27,23 +28,70 @@ func PublicRoutes(app *fiber.App) {
 		ctx.Send([]byte(str))
 		return nil
 	})
+	// Request deadline for database work, zero means REQUEST_TIMEOUT_SECONDS.
+	timeout := middleware.RequestTimeout(0)
+
 	// Routes for GET method:
-	pubRoute.Get("/products", controllers2.Getproducts)   // get list of all products
-	pubRoute.Get("/product/:id", controllers2.Getproduct) // get one product by ID
+	pubRoute.Get("/products", middleware.RequestTimeout(30*time.Second), controllers2.Getproducts) // get list of all products
+	pubRoute.Get("/products/search", timeout, controllers2.Searchproducts)                         // full-text search of products
+	pubRoute.Get("/products/stats", timeout, controllers2.Getproductstats)                         // grouped statistics of products
+	pubRoute.Get("/products/export", controllers2.Exportproducts)                                  // stream products as file, no deadline
+	pubRoute.Get("/product/:id", timeout, controllers2.Getproduct)                                 // get one product by ID
+	pubRoute.Get("/files/*", controllers2.Getfile)                                                 // get stored file, e.g. product image
+	pubRoute.Get("/categories", timeout, controllers2.Getcategories)                               // get category tree
+	pubRoute.Get("/category/:id", timeout, controllers2.Getcategory)                               // get one category by ID or slug
+	pubRoute.Get("/tags", timeout, controllers2.Gettags)                                           // autocomplete tags
+	pubRoute.Get("/product/:id/reviews", timeout, controllers2.Getproductreviews)                  // get reviews of one product
+	pubRoute.Get("/product/:id/comments", timeout, controllers2.Getproductcomments)                // get comment threads of one product
+	pubRoute.Get("/comment/:id/replies", timeout, controllers2.Getcommentreplies)                  // get replies of one comment thread
 	// Routes for POST method:
-	pubRoute.Post("/user/sign/up", controllers2.UserSignUp) // register app new user
-	pubRoute.Post("/user/sign/in", controllers2.UserSignIn) // auth, return Access & Refresh tokens
+	pubRoute.Post("/user/sign/up", timeout, controllers2.UserSignUp) // register app new user
+	pubRoute.Post("/user/sign/in", timeout, controllers2.UserSignIn) // auth, return Access & Refresh tokens
 
 	// Create routes group.
 	route := app.Group("/api/v1")
 	// Routes for POST method:
-	route.Post("/product", controllers2.Createproduct)     // create app new product
-	route.Post("/user/sign/out", controllers2.UserSignOut) // de-authorization user
-	route.Post("/token/renew", controllers2.RenewTokens)   // renew Access & Refresh tokens
+	route.Post("/product", timeout, controllers2.Createproduct)                                           // create app new product
+	route.Post("/category", timeout, controllers2.Createcategory)                                         // create a new category
+	route.Post("/user/sign/out", controllers2.UserSignOut)                                                // de-authorization user
+	route.Post("/token/renew", timeout, controllers2.RenewTokens)                                         // renew Access & Refresh tokens
+	route.Post("/product/:id/restore", timeout, controllers2.Restoreproduct)                              // restore one deleted product by ID
+	route.Post("/product/:id/revisions/:rev/restore", timeout, controllers2.Restoreproductrevision)       // restore one product to a revision
+	route.Post("/product/:id/images", timeout, controllers2.Uploadproductimage)                           // upload image of one product
+	route.Post("/product/:id/transitions/:name", timeout, controllers2.Transitionproduct)                 // change state of one product
+	route.Post("/product/:id/reviews", timeout, controllers2.Createproductreview)                         // review one product
+	route.Post("/product/:id/reviews/:review/helpful", timeout, controllers2.Voteproductreview)           // vote for one review as helpful
+	route.Post("/product/:id/comments", timeout, controllers2.Createproductcomment)                       // comment on one product or reply to a comment
+	route.Post("/comment/:id/approve", timeout, controllers2.Approvecomment)                              // approve one comment (moderators)
+	route.Post("/comment/:id/hide", timeout, controllers2.Hidecomment)                                    // hide one comment (moderators)
+	route.Post("/products/bulk", middleware.RequestTimeout(5*time.Minute), controllers2.Bulkproducts)     // create, upsert or delete many products
+	route.Post("/products/export", timeout, controllers2.Startproductexport)                              // export products to file in background
+	route.Post("/products/import", middleware.RequestTimeout(5*time.Minute), controllers2.Importproducts) // import products from csv or ndjson file
+	// Routes for GET method:
+	route.Get("/products/trash", timeout, controllers2.Gettrashproducts)                      // get list of deleted products
+	route.Get("/audit", timeout, controllers2.GetAuditRecords)                                // get audit trail of an entity
+	route.Get("/product/:id/revisions", timeout, controllers2.Getproductrevisions)            // get revisions of one product
+	route.Get("/product/:id/revisions/:rev", timeout, controllers2.Getproductrevision)        // get one revision of a product
+	route.Get("/product/:id/revisions/:rev/diff", timeout, controllers2.Diffproductrevisions) // compare two revisions of a product
+	route.Get("/product/:id/transitions", timeout, controllers2.Getproducttransitions)        // get state changes of one product
+	route.Get("/products/export/:id", controllers2.Getproductexport)                          // get status of background export
+	route.Get("/products/export/:id/download", controllers2.Downloadproductexport)            // download file of background export
+	route.Get("/comments/moderation", timeout, controllers2.Getcommentmoderation)             // get comments waiting for moderation
 	// Routes for PUT method:
-	route.Put("/product", controllers2.Updateproduct) // update one product by ID
+	route.Put("/product", timeout, controllers2.Updateproduct)                       // update one product by ID
+	route.Put("/product/:id/categories", timeout, controllers2.Setproductcategories) // set categories of one product
+	route.Put("/product/:id/tags", timeout, controllers2.Setproducttags)             // set tags of one product
+	route.Put("/category/:id", timeout, controllers2.Updatecategory)                 // rename or move one category
+	route.Put("/product/:id/reviews", timeout, controllers2.Updateproductreview)     // update own review of one product
+	route.Put("/comment/:id", timeout, controllers2.Updatecomment)                   // edit own comment within the edit window
 	// Routes for DELETE method:
-	route.Delete("/product", controllers2.Deleteproduct) // delete one product by ID
+	route.Delete("/product", timeout, controllers2.Deleteproduct)                   // delete one product by ID
+	route.Delete("/category/:id", timeout, controllers2.Deletecategory)             // delete one category without subcategories
+	route.Delete("/product/:id/reviews", timeout, controllers2.Deleteproductreview) // delete own (or, as moderator, any) review of one product
+	route.Delete("/comment/:id", timeout, controllers2.Deletecomment)               // delete own (or, as moderator, any) comment
+	// Body limits of uploads, larger bodies are answered with 413 before they are read.
+	middleware.RouteBodyLimit(app, fiber.MethodPost, "/api/v1/product/:id/images", controllers2.ImageBodyLimit())
+	middleware.RouteBodyLimit(app, fiber.MethodPost, "/api/v1/products/import", controllers2.ImportBodyLimit())
 
 	route.Get("/kafka", func(ctx *fiber.Ctx) error {
 		topic := "my-topic"
@@ -106,6 +154,10 @@ func PublicRoutes(app *fiber.App) {
 		return nil
 	}) // delete one product by ID
 
+	// Probes of the orchestrator, outside of the API group.
+	app.Get("/healthz", controllers2.Healthz) // process is alive
+	app.Get("/readyz", controllers2.Readyz)   // dependencies are up and server is not shutting down
+
 	// Create routes group.
 	swagRoute := app.Group("/swagger")
 	// Routes for GET method:
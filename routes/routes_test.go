@@ -1,19 +1,60 @@
 package routes
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"tuxiaocao/middleware"
+	"tuxiaocao/pkg/platform/database"
+	"tuxiaocao/pkg/platform/migrations"
+	"tuxiaocao/routes/controllers"
+	"tuxiaocao/routes/models"
+	"tuxiaocao/routes/queries"
+	"tuxiaocao/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"gorm.io/gorm"
 )
 
+// openDatabase opens an in-memory SQLite database like the server does:
+// with the migrations applied and the tenant and audit plugins registered.
+func openDatabase(t *testing.T) {
+	t.Setenv("DB_TYPE", "sqlite")
+	t.Setenv("DB_NAME", ":memory:")
+	previous := database.DB
+	t.Cleanup(func() { database.DB = previous })
+
+	db, err := database.OpenDBConnection()
+	require.NoError(t, err)
+	migrator, err := migrations.New(db)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background(), 0)
+	require.NoError(t, err)
+	require.NoError(t, db.Use(models.TenantPlugin{}))
+	require.NoError(t, db.Use(models.AuditPlugin{}))
+}
+
 func TestPublicRoutes(t *testing.T) {
-	// Load .env file from the root folder
-	if err := godotenv.Load("../../.env"); err != nil {
+	// Use an in-memory SQLite database with the schema of the migrations.
+	openDatabase(t)
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	// Seed one product of the default tenant.
+	product := &models.Product{ID: uuid.New(), UserID: "1", Title: "title", Author: "author", ProductStatus: 1}
+	if err := products.Create(models.WithTenant(context.Background(), models.DefaultTenant()), product); err != nil {
 		panic(err)
 	}
 
@@ -21,9 +62,71 @@ func TestPublicRoutes(t *testing.T) {
 	tests := []struct {
 		description   string
 		route         string // input route
+		host          string // host of the request, mapped to a tenant by TENANT_HOSTS
 		expectedError bool
 		expectedCode  int
 	}{
+		{
+			description:   "get existing product by ID",
+			route:         "/api/v1/product/" + product.ID.String(),
+			expectedError: false,
+			expectedCode:  200,
+		},
+		{
+			description:   "get products filtered by title",
+			route:         "/api/v1/products?filter[title][eq]=title&sort=-created_at",
+			expectedError: false,
+			expectedCode:  200,
+		},
+		{
+			description:   "get products by not whitelisted field",
+			route:         "/api/v1/products?filter[user_id][like]=1",
+			expectedError: false,
+			expectedCode:  400,
+		},
+		{
+			description:   "search products",
+			route:         "/api/v1/products/search?q=title",
+			expectedError: false,
+			expectedCode:  200,
+		},
+		{
+			description:   "search products without search text",
+			route:         "/api/v1/products/search",
+			expectedError: false,
+			expectedCode:  400,
+		},
+		{
+			description:   "count products per author",
+			route:         "/api/v1/products/stats?group_by=author&metric=count",
+			expectedError: false,
+			expectedCode:  200,
+		},
+		{
+			description:   "group products by not whitelisted field",
+			route:         "/api/v1/products/stats?group_by=title",
+			expectedError: false,
+			expectedCode:  400,
+		},
+		{
+			description:   "export products as csv",
+			route:         "/api/v1/products/export?format=csv&filter[author][eq]=author",
+			expectedError: false,
+			expectedCode:  200,
+		},
+		{
+			description:   "export products in unsupported format",
+			route:         "/api/v1/products/export?format=xml",
+			expectedError: false,
+			expectedCode:  400,
+		},
+		{
+			description:   "get product of another tenant",
+			route:         "/api/v1/product/" + product.ID.String(),
+			host:          "other.example.com",
+			expectedError: false,
+			expectedCode:  404,
+		},
 		{
 			description:   "get product by ID",
 			route:         "/api/v1/product/" + uuid.New().String(),
@@ -36,12 +139,26 @@ func TestPublicRoutes(t *testing.T) {
 			expectedError: false,
 			expectedCode:  500,
 		},
+		{
+			description:   "process is alive",
+			route:         "/healthz",
+			expectedError: false,
+			expectedCode:  200,
+		},
+		{
+			description:   "service is ready without registered checks",
+			route:         "/readyz",
+			expectedError: false,
+			expectedCode:  200,
+		},
 	}
 
 	// Define Fiber service.
+	t.Setenv("TENANT_HOSTS", "other.example.com=other")
 	app := fiber.New()
 
-	// Define routes.
+	// Define routes, scoped to the tenant of the request.
+	app.Use(middleware.UserContext)
 	PublicRoutes(app)
 
 	// Iterate through test single test cases
@@ -49,6 +166,9 @@ func TestPublicRoutes(t *testing.T) {
 		// Create a new http request with the route from the test case.
 		req := httptest.NewRequest("GET", test.route, http.NoBody)
 		req.Header.Set("Content-Type", "application/json")
+		if test.host != "" {
+			req.Host = test.host
+		}
 
 		// Perform the request plain with the service.
 		resp, err := app.Test(req, -1) // the -1 disables request latency
@@ -66,3 +186,773 @@ func TestPublicRoutes(t *testing.T) {
 		assert.Equalf(t, test.expectedCode, resp.StatusCode, test.description)
 	}
 }
+
+func TestProductsCursor(t *testing.T) {
+	openDatabase(t)
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	ctx := models.WithTenant(context.Background(), models.DefaultTenant())
+	for _, title := range []string{"c", "a", "e", "b", "d"} {
+		require.NoError(t, products.Create(ctx, &models.Product{ID: uuid.New(), Title: title, Author: "author"}))
+	}
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	type page struct {
+		Products   []models.Product `json:"products"`
+		NextCursor string           `json:"next_cursor"`
+		PrevCursor string           `json:"prev_cursor"`
+	}
+	list := func(query string) (int, page) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/products?"+query, http.NoBody), -1)
+		require.NoError(t, err)
+		var result page
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
+	titles := func(p page) (titles []string) {
+		for _, product := range p.Products {
+			titles = append(titles, product.Title)
+		}
+		return titles
+	}
+
+	// The first page has no previous page.
+	code, first := list("cursor=&limit=2&sort=title")
+	require.Equal(t, 200, code)
+	assert.Equal(t, []string{"a", "b"}, titles(first))
+	assert.Empty(t, first.PrevCursor)
+	require.NotEmpty(t, first.NextCursor)
+
+	code, second := list("limit=2&sort=title&cursor=" + first.NextCursor)
+	require.Equal(t, 200, code)
+	assert.Equal(t, []string{"c", "d"}, titles(second))
+	require.NotEmpty(t, second.PrevCursor)
+
+	code, last := list("limit=2&sort=title&cursor=" + second.NextCursor)
+	require.Equal(t, 200, code)
+	assert.Equal(t, []string{"e"}, titles(last))
+	assert.Empty(t, last.NextCursor)
+
+	// Going back returns the same page in the same order.
+	code, back := list("limit=2&sort=title&cursor=" + second.PrevCursor)
+	require.Equal(t, 200, code)
+	assert.Equal(t, []string{"a", "b"}, titles(back))
+	assert.Empty(t, back.PrevCursor)
+	assert.Equal(t, first.NextCursor, back.NextCursor)
+
+	// Filters are applied to every page.
+	code, filtered := list("cursor=&limit=2&sort=-title&filter[author][eq]=author")
+	require.Equal(t, 200, code)
+	assert.Equal(t, []string{"e", "d"}, titles(filtered))
+
+	// A tampered cursor or a cursor of another sorting is rejected.
+	for _, query := range []string{
+		"limit=2&sort=title&cursor=" + first.NextCursor[:len(first.NextCursor)-2],
+		"limit=2&sort=title&cursor=not-a-cursor",
+		"limit=2&sort=-title&cursor=" + first.NextCursor,
+	} {
+		code, _ := list(query)
+		assert.Equal(t, 400, code, query)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	openDatabase(t)
+	controllers.UseStores(models.NewGormProductStore(), models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	// Every query takes longer than the deadline of the request.
+	err := database.DB.Callback().Query().Before("gorm:query").Register("test:slow", func(db *gorm.DB) {
+		select {
+		case <-db.Statement.Context.Done():
+			_ = db.AddError(db.Statement.Context.Err())
+		case <-time.After(5 * time.Second):
+		}
+	})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	app.Get("/api/v1/product/:id", middleware.RequestTimeout(50*time.Millisecond), controllers.Getproduct)
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/product/"+uuid.NewString(), http.NoBody), -1)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, fiber.StatusGatewayTimeout, resp.StatusCode)
+
+	var result struct {
+		Error bool   `json:"error"`
+		Msg   string `json:"msg"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.True(t, result.Error)
+	assert.Equal(t, "query timeout, the database did not answer in time", result.Msg)
+}
+
+// accessToken returns an access token of the user with the credentials of the role in the default tenant.
+func accessToken(t *testing.T, userID, role string) string {
+	t.Setenv("JWT_SECRET_KEY", "secret")
+	t.Setenv("JWT_SECRET_KEY_EXPIRE_MINUTES_COUNT", "15")
+	credentials, err := utils.GetCredentialsByRole(role)
+	require.NoError(t, err)
+	token, err := utils.GenerateNewTokens(userID, models.DefaultTenant(), credentials)
+	require.NoError(t, err)
+	return token.Access
+}
+
+func TestProductETag(t *testing.T) {
+	openDatabase(t)
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	ctx := models.WithTenant(context.Background(), models.DefaultTenant())
+	product := &models.Product{ID: uuid.New(), UserID: "1", Title: "title", Author: "author"}
+	require.NoError(t, products.Create(ctx, product))
+	token := accessToken(t, "1", "moderator")
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	update := func(ifMatch, body string) *http.Response {
+		req := httptest.NewRequest("PUT", "/api/v1/product", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp
+	}
+	body := func(title string, version int) string {
+		return `{"id":"` + product.ID.String() + `","title":"` + title + `","version":` + strconv.Itoa(version) + `}`
+	}
+
+	// GET returns the row version as ETag.
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/product/"+product.ID.String(), http.NoBody), -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	// The If-Match header is required.
+	resp = update("", body("second", 0))
+	assert.Equal(t, fiber.StatusPreconditionRequired, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	// PUT with the current ETag returns the ETag of the new version.
+	resp = update(`"1"`, body("second", 0))
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	// A stale If-Match fails the precondition, the product is not changed.
+	resp = update(`"1"`, body("stale", 0))
+	assert.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	// Any version matches "*", the compare-and-swap on the stale version of the body fails.
+	resp = update("*", body("stale", 1))
+	assert.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	var conflict struct {
+		Version int64 `json:"version"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&conflict))
+	assert.Equal(t, int64(2), conflict.Version)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/product/"+product.ID.String(), http.NoBody), -1)
+	require.NoError(t, err)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+	var detail struct {
+		Product models.Product `json:"product"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&detail))
+	assert.Equal(t, "second", detail.Product.Title)
+
+	// Only title, author and attributes are written, the owner, state and deletion are kept.
+	resp = update(`"2"`, `{"id":"`+product.ID.String()+`","title":"third","author":"rob","user_id":"2","product_status":"active","deleted_at":"2020-01-01T00:00:00Z"}`)
+	require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	updated, err := products.Get(ctx, product.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "third", updated.Title)
+	assert.Equal(t, "rob", updated.Author)
+	assert.Equal(t, "1", updated.UserID)
+	assert.Equal(t, models.StateDraft, updated.ProductStatus)
+}
+
+func TestBulkProducts(t *testing.T) {
+	openDatabase(t)
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	ctx := models.WithTenant(context.Background(), models.DefaultTenant())
+	foreign := &models.Product{ID: uuid.New(), UserID: "2", Title: "foreign", Author: "author"}
+	require.NoError(t, products.Create(ctx, foreign))
+	user, admin := accessToken(t, "1", "user"), accessToken(t, "1", "admin")
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	type result struct {
+		Succeeded int                   `json:"succeeded"`
+		Failed    int                   `json:"failed"`
+		Results   []*queries.BulkResult `json:"results"`
+	}
+	bulk := func(token, body string) (int, result) {
+		req := httptest.NewRequest("POST", "/api/v1/products/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		var r result
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+		return resp.StatusCode, r
+	}
+	failed := func(r result) (indexes []int) {
+		for _, item := range r.Results {
+			if item.Error {
+				indexes = append(indexes, item.Index)
+			}
+		}
+		return indexes
+	}
+
+	// Invalid items and duplicate IDs fail on their own, the other items are created.
+	a, b := uuid.New(), uuid.New()
+	code, r := bulk(user, `{"action":"create","items":[`+
+		`{"id":"`+a.String()+`","title":"a"},`+
+		`{"title":""},`+
+		`{"id":"`+a.String()+`","title":"duplicate"},`+
+		`{"id":"`+b.String()+`","title":"b"}]}`)
+	require.Equal(t, 200, code)
+	assert.Equal(t, 2, r.Succeeded)
+	assert.Equal(t, []int{1, 2}, failed(r))
+	created, err := products.Get(ctx, a)
+	require.NoError(t, err)
+	assert.Equal(t, "a", created.Title)
+	assert.Equal(t, "1", created.UserID)
+
+	// Upsert updates own products, products of other users are not touched.
+	code, r = bulk(user, `{"action":"upsert","items":[`+
+		`{"id":"`+a.String()+`","title":"a2"},`+
+		`{"id":"`+foreign.ID.String()+`","title":"taken"},`+
+		`{"title":"new"}]}`)
+	require.Equal(t, 200, code)
+	assert.Equal(t, 2, r.Succeeded)
+	assert.Equal(t, []int{1}, failed(r))
+	assert.Contains(t, r.Results[1].Msg, "permission denied")
+	updated, err := products.Get(ctx, a)
+	require.NoError(t, err)
+	assert.Equal(t, "a2", updated.Title)
+	assert.Equal(t, created.Version+1, updated.Version)
+	unchanged, err := products.Get(ctx, foreign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "foreign", unchanged.Title)
+
+	// Deleting needs the product:delete credential.
+	code, _ = bulk(user, `{"action":"delete","ids":["`+a.String()+`"]}`)
+	assert.Equal(t, fiber.StatusForbidden, code)
+
+	// Only own products are deleted, unknown IDs are reported.
+	code, r = bulk(admin, `{"action":"delete","ids":["`+a.String()+`","`+foreign.ID.String()+`","`+uuid.NewString()+`"]}`)
+	require.Equal(t, 200, code)
+	assert.Equal(t, 1, r.Succeeded)
+	assert.Equal(t, []int{1, 2}, failed(r))
+	assert.Contains(t, r.Results[1].Msg, "permission denied")
+	assert.Equal(t, "product with this ID not found", r.Results[2].Msg)
+	_, err = products.Get(ctx, a)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = products.Get(ctx, foreign.ID)
+	assert.NoError(t, err)
+}
+
+func TestRouteBodyLimit(t *testing.T) {
+	openDatabase(t)
+	controllers.UseStores(models.NewGormProductStore(), models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+	t.Setenv("IMAGE_MAX_BYTES", "1024")
+	t.Setenv("IMPORT_MAX_BYTES", "1024")
+	token := accessToken(t, "1", "user")
+
+	// The limit is checked by the server while it reads the request, app.Test
+	// returns the error instead of the response, so the app is served on a listener.
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+	listener := fasthttputil.NewInmemoryListener()
+	go func() { _ = app.Listener(listener) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+	do := func(req *http.Request) int {
+		req.Header.Set("Authorization", "Bearer "+token)
+		conn, err := listener.Dial()
+		require.NoError(t, err)
+		defer conn.Close()
+		// The server may answer before the body is written.
+		go func() { _ = req.Write(conn) }()
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	upload := func(size int) int {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("image", "image.png")
+		require.NoError(t, err)
+		_, err = part.Write(bytes.Repeat([]byte{0}, size))
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+		req := httptest.NewRequest("POST", "http://test/api/v1/product/"+uuid.NewString()+"/images", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return do(req)
+	}
+
+	// Bodies over the limit of the route are rejected before they are read,
+	// the 10GB BodyLimit of the server doesn't apply.
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, upload(1<<20))
+	assert.NotEqual(t, fiber.StatusRequestEntityTooLarge, upload(512))
+
+	// Imports, the file may also be sent as the raw body.
+	importFile := func(body string) int {
+		req := httptest.NewRequest("POST", "http://test/api/v1/products/import?dry_run=true", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		return do(req)
+	}
+	assert.Equal(t, fiber.StatusRequestEntityTooLarge, importFile("title\n"+strings.Repeat("product\n", 1<<17)))
+	assert.Equal(t, fiber.StatusOK, importFile("title\nproduct\n"))
+
+	// Other routes keep the BodyLimit of the server.
+	req := httptest.NewRequest("POST", "http://test/api/v1/products/bulk", strings.NewReader(`{"action":"create","items":[{"title":"`+strings.Repeat("a", 1<<20)+`"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	assert.NotEqual(t, fiber.StatusRequestEntityTooLarge, do(req))
+}
+
+func TestUserSignUp(t *testing.T) {
+	openDatabase(t)
+	users := models.NewGormUserStore()
+	controllers.UseStores(models.NewGormProductStore(), users, models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	// Users get increasing IDs from the database.
+	for i, username := range []string{"alice", "bob"} {
+		body := `{"username":"` + username + `","password":"password"}`
+		req := httptest.NewRequest("POST", "/api/v1/user/sign/up", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode, username)
+
+		var result struct {
+			User models.User `json:"user"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		assert.Equal(t, i+1, result.User.ID, username)
+	}
+
+	// Users are stored in the default tenant.
+	user, err := users.GetByUsername(models.WithTenant(context.Background(), models.DefaultTenant()), "bob")
+	require.NoError(t, err)
+	assert.Equal(t, 2, user.ID)
+}
+
+func TestRequestTenant(t *testing.T) {
+	openDatabase(t)
+	users := models.NewGormUserStore()
+	controllers.UseStores(models.NewGormProductStore(), users, models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+	t.Setenv("TENANT_HOSTS", "shop.example.com=shop")
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	signUp := func(username, host string, header map[string]string) int {
+		req := httptest.NewRequest("POST", "/api/v1/user/sign/up", strings.NewReader(`{"username":"`+username+`","password":"password"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Host = host
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+	tenantOf := func(username string) string {
+		tenant := ""
+		require.NoError(t, database.DB.Raw("SELECT tenant_id FROM users WHERE username = ?", username).Scan(&tenant).Error)
+		return tenant
+	}
+
+	// The header doesn't choose the tenant, the host does.
+	require.Equal(t, 200, signUp("alice", "example.com", map[string]string{"X-Tenant-ID": "shop"}))
+	assert.Equal(t, models.DefaultTenant(), tenantOf("alice"))
+	require.Equal(t, 200, signUp("bob", "shop.example.com:5000", nil))
+	assert.Equal(t, "shop", tenantOf("bob"))
+	require.Equal(t, 200, signUp("carol", "example.com", map[string]string{"X-Forwarded-Host": "shop.example.com"}))
+	assert.Equal(t, models.DefaultTenant(), tenantOf("carol"))
+
+	// A request with an invalid token is rejected.
+	assert.Equal(t, fiber.StatusUnauthorized, signUp("dave", "shop.example.com", map[string]string{"Authorization": "Bearer invalid"}))
+	assert.Equal(t, "", tenantOf("dave"))
+
+	// The tenant of a valid token wins over the host.
+	token := accessToken(t, "1", "user")
+	req := httptest.NewRequest("GET", "/api/v1/products", http.NoBody)
+	req.Host = "shop.example.com"
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestProductTaxonomy(t *testing.T) {
+	openDatabase(t)
+	products, categories := models.NewGormProductStore(), models.NewGormCategoryStore()
+	controllers.UseStores(products, models.NewGormUserStore(), categories, models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	// Seed books → fiction and two products, one of them in fiction with tags.
+	ctx := models.WithTenant(context.Background(), models.DefaultTenant())
+	books := &models.Category{Name: "Books"}
+	require.NoError(t, categories.CreateCategory(ctx, books))
+	fiction := &models.Category{Name: "Fiction", ParentID: &books.ID}
+	require.NoError(t, categories.CreateCategory(ctx, fiction))
+	novel := &models.Product{ID: uuid.New(), UserID: "1", Title: "novel", Author: "author"}
+	require.NoError(t, products.Create(ctx, novel))
+	require.NoError(t, products.Create(ctx, &models.Product{ID: uuid.New(), UserID: "1", Title: "other", Author: "author"}))
+	require.NoError(t, categories.SetProductCategories(ctx, novel.ID, []uuid.UUID{fiction.ID}))
+	_, err := categories.SetProductTags(ctx, novel.ID, []string{"Go", "web"})
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	tests := []struct {
+		description  string
+		route        string
+		expectedCode int
+		count        int
+	}{
+		{"products of a category include subcategories", "/api/v1/products?category=books", 200, 1},
+		{"products by category ID", "/api/v1/products?category=" + fiction.ID.String(), 200, 1},
+		{"products with all tags", "/api/v1/products?category=books&tag=go&tag=WEB", 200, 1},
+		{"products with comma separated tags", "/api/v1/products?tag=go,unknown", 200, 0},
+		{"products without filter", "/api/v1/products", 200, 2},
+		{"products of unknown category", "/api/v1/products?category=music", 400, 0},
+		{"search in a category", "/api/v1/products/search?q=author&category=books", 200, 1},
+		{"search with tags", "/api/v1/products/search?q=author&tag=go,unknown", 200, 0},
+		{"search in unknown category", "/api/v1/products/search?q=author&category=music", 400, 0},
+	}
+	for _, test := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", test.route, http.NoBody), -1)
+		require.NoError(t, err, test.description)
+		require.Equal(t, test.expectedCode, resp.StatusCode, test.description)
+		var result struct {
+			Count int `json:"count"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), test.description)
+		assert.Equal(t, test.count, result.Count, test.description)
+	}
+
+	// The export is restricted the same way.
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/products/export?format=json&category=books&tag=go", http.NoBody), -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	var exported []models.Product
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&exported))
+	require.Len(t, exported, 1)
+	assert.Equal(t, novel.ID, exported[0].ID)
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/products/export?category=music", http.NoBody), -1)
+	require.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	// Category tree, product details and tag autocomplete.
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/categories", http.NoBody), -1)
+	require.NoError(t, err)
+	var tree struct {
+		Categories []models.CategoryNode `json:"categories"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tree))
+	require.Len(t, tree.Categories, 1)
+	require.Len(t, tree.Categories[0].Children, 1)
+	assert.Equal(t, "fiction", tree.Categories[0].Children[0].Slug)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/product/"+novel.ID.String(), http.NoBody), -1)
+	require.NoError(t, err)
+	var detail struct {
+		Categories []models.Category `json:"categories"`
+		Tags       []string          `json:"tags"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&detail))
+	require.Len(t, detail.Categories, 1)
+	assert.Equal(t, fiction.ID, detail.Categories[0].ID)
+	assert.Equal(t, []string{"go", "web"}, detail.Tags)
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/api/v1/tags?q=G", http.NoBody), -1)
+	require.NoError(t, err)
+	var completions struct {
+		Tags []string `json:"tags"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completions))
+	assert.Equal(t, []string{"go"}, completions.Tags)
+}
+
+func TestProductReviews(t *testing.T) {
+	openDatabase(t)
+	t.Setenv("JWT_SECRET_KEY", "secret")
+	t.Setenv("JWT_SECRET_KEY_EXPIRE_MINUTES_COUNT", "15")
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	ctx := models.WithTenant(context.Background(), models.DefaultTenant())
+	product := &models.Product{ID: uuid.New(), UserID: "1", Title: "title", Author: "author"}
+	require.NoError(t, products.Create(ctx, product))
+
+	tokens := map[string]string{}
+	for id, role := range map[string]string{"1": "user", "2": "user", "3": "user", "4": "moderator"} {
+		credentials, err := utils.GetCredentialsByRole(role)
+		require.NoError(t, err)
+		token, err := utils.GenerateNewTokens(id, models.DefaultTenant(), credentials)
+		require.NoError(t, err)
+		tokens[id] = token.Access
+	}
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	reviews := "/api/v1/product/" + product.ID.String() + "/reviews"
+	var reviewID string
+	tests := []struct {
+		description  string
+		method       string
+		route        func() string
+		user         string
+		body         string
+		expectedCode int
+	}{
+		{"creator can't review own product", "POST", func() string { return reviews }, "1", `{"score":5}`, 403},
+		{"score out of range", "POST", func() string { return reviews }, "2", `{"score":6}`, 400},
+		{"review product", "POST", func() string { return reviews }, "2", `{"score":5,"text":"great"}`, 201},
+		{"review product twice", "POST", func() string { return reviews }, "2", `{"score":1}`, 409},
+		{"review product by another user", "POST", func() string { return reviews }, "3", `{"score":2}`, 201},
+		{"update own review", "PUT", func() string { return reviews }, "3", `{"score":3,"text":"ok"}`, 200},
+		{"update missing review", "PUT", func() string { return reviews }, "4", `{"score":3}`, 404},
+		{"vote for own review", "POST", func() string { return reviews + "/" + reviewID + "/helpful" }, "2", ``, 403},
+		{"vote for review", "POST", func() string { return reviews + "/" + reviewID + "/helpful" }, "3", ``, 200},
+		{"vote for review twice", "POST", func() string { return reviews + "/" + reviewID + "/helpful" }, "3", ``, 409},
+		{"delete review of another user", "DELETE", func() string { return reviews + "?user_id=3" }, "2", ``, 403},
+		{"moderator deletes review of another user", "DELETE", func() string { return reviews + "?user_id=3" }, "4", ``, 204},
+		{"list reviews by unknown order", "GET", func() string { return reviews + "?sort=score" }, "", ``, 400},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.route(), strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		if test.user != "" {
+			req.Header.Set("Authorization", "Bearer "+tokens[test.user])
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err, test.description)
+		require.Equal(t, test.expectedCode, resp.StatusCode, test.description)
+		if test.expectedCode == 201 && reviewID == "" {
+			var result struct {
+				Review models.Review `json:"review"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), test.description)
+			reviewID = result.Review.ID.String()
+		}
+	}
+
+	// The product carries the rating of the remaining review.
+	resp, err := app.Test(httptest.NewRequest("GET", reviews+"?sort=recent", http.NoBody), -1)
+	require.NoError(t, err)
+	var result struct {
+		Count   int                `json:"count"`
+		Rating  models.ReviewStats `json:"rating"`
+		Reviews []models.Review    `json:"reviews"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, 1, result.Count)
+	assert.Equal(t, models.ReviewStats{Average: 5, Count: 1}, result.Rating)
+	require.Len(t, result.Reviews, 1)
+	assert.Equal(t, int64(1), result.Reviews[0].Helpful)
+}
+
+func TestProductComments(t *testing.T) {
+	openDatabase(t)
+	t.Setenv("JWT_SECRET_KEY", "secret")
+	t.Setenv("JWT_SECRET_KEY_EXPIRE_MINUTES_COUNT", "15")
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	ctx := models.WithTenant(context.Background(), models.DefaultTenant())
+	product := &models.Product{ID: uuid.New(), UserID: "1", Title: "title", Author: "author"}
+	require.NoError(t, products.Create(ctx, product))
+
+	tokens := map[string]string{}
+	for id, role := range map[string]string{"1": "user", "2": "user", "4": "moderator"} {
+		credentials, err := utils.GetCredentialsByRole(role)
+		require.NoError(t, err)
+		token, err := utils.GenerateNewTokens(id, models.DefaultTenant(), credentials)
+		require.NoError(t, err)
+		tokens[id] = token.Access
+	}
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	comments := "/api/v1/product/" + product.ID.String() + "/comments"
+	var commentID, replyID string
+	comment := func(path string) func() string {
+		return func() string { return "/api/v1/comment/" + commentID + path }
+	}
+	tests := []struct {
+		description  string
+		method       string
+		route        func() string
+		user         string
+		body         string
+		expectedCode int
+	}{
+		{"empty comment", "POST", func() string { return comments }, "2", `{"text":""}`, 400},
+		{"comment on product", "POST", func() string { return comments }, "2", `{"text":"overpriced"}`, 201},
+		{"reply to comment", "POST", func() string { return comments }, "1", `{"text":"it is not","parent_id":":comment"}`, 201},
+		{"reply to unknown comment", "POST", func() string { return comments }, "1", `{"text":"?","parent_id":"` + product.ID.String() + `"}`, 400},
+		{"edit comment of another user", "PUT", comment(""), "1", `{"text":"cheap"}`, 403},
+		{"edit own comment", "PUT", comment(""), "2", `{"text":"way overpriced"}`, 200},
+		{"queue without credential", "GET", func() string { return "/api/v1/comments/moderation" }, "2", ``, 403},
+		{"queue of unknown product", "GET", func() string { return "/api/v1/comments/moderation?product_id=1" }, "4", ``, 400},
+		{"approve without credential", "POST", comment("/approve"), "2", ``, 403},
+		{"approve comment", "POST", comment("/approve"), "4", ``, 200},
+		{"delete comment of another user", "DELETE", comment(""), "1", ``, 403},
+		{"delete own comment", "DELETE", comment(""), "2", ``, 204},
+		{"delete comment twice", "DELETE", comment(""), "2", ``, 404},
+		{"reply to deleted comment", "POST", func() string { return comments }, "1", `{"text":"gone","parent_id":":comment"}`, 409},
+		{"get replies", "GET", comment("/replies"), "", ``, 200},
+	}
+	for _, test := range tests {
+		body := strings.ReplaceAll(test.body, ":comment", commentID)
+		req := httptest.NewRequest(test.method, test.route(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if test.user != "" {
+			req.Header.Set("Authorization", "Bearer "+tokens[test.user])
+		}
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err, test.description)
+		require.Equal(t, test.expectedCode, resp.StatusCode, test.description)
+		if test.expectedCode == 201 {
+			var result struct {
+				Comment models.Comment `json:"comment"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&result), test.description)
+			if commentID == "" {
+				commentID = result.Comment.ID.String()
+			} else {
+				replyID = result.Comment.ID.String()
+			}
+		}
+	}
+
+	// The deleted comment stays in the thread as placeholder, the reply is still pending.
+	resp, err := app.Test(httptest.NewRequest("GET", comments, http.NoBody), -1)
+	require.NoError(t, err)
+	var result struct {
+		Count    int                    `json:"count"`
+		Comments []models.CommentThread `json:"comments"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, 1, result.Count)
+	require.Len(t, result.Comments, 1)
+	assert.Equal(t, models.CommentDeletedText, result.Comments[0].Text)
+	assert.Empty(t, result.Comments[0].UserID)
+	assert.Equal(t, int64(1), result.Comments[0].ReplyCount)
+	require.Len(t, result.Comments[0].Replies, 1)
+	assert.Equal(t, replyID, result.Comments[0].Replies[0].ID.String())
+
+	req := httptest.NewRequest("GET", "/api/v1/comments/moderation", http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+tokens["4"])
+	resp, err = app.Test(req, -1)
+	require.NoError(t, err)
+	var queue struct {
+		Count    int              `json:"count"`
+		Comments []models.Comment `json:"comments"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&queue))
+	assert.Equal(t, 1, queue.Count)
+	require.Len(t, queue.Comments, 1)
+	assert.Equal(t, replyID, queue.Comments[0].ID.String())
+}
+
+func TestAuditRecords(t *testing.T) {
+	openDatabase(t)
+	products := models.NewGormProductStore()
+	controllers.UseStores(products, models.NewGormUserStore(), models.NewGormCategoryStore(), models.NewGormReviewStore(), models.NewGormCommentStore())
+
+	ctx := models.WithActor(models.WithTenant(context.Background(), models.DefaultTenant()), "1")
+	first := &models.Product{ID: uuid.New(), UserID: "1", Title: "first", Author: "author"}
+	second := &models.Product{ID: uuid.New(), UserID: "1", Title: "second", Author: "author"}
+	require.NoError(t, products.Create(ctx, first))
+	require.NoError(t, products.Create(ctx, second))
+	first.Title = "first 2"
+	require.NoError(t, products.Update(ctx, first))
+	require.NoError(t, models.NewUserRepo().WithContext(ctx).Create(&models.User{ID: 1, Username: "alice"}))
+	user, admin := accessToken(t, "1", "user"), accessToken(t, "1", "admin")
+
+	app := fiber.New()
+	app.Use(middleware.UserContext)
+	PublicRoutes(app)
+
+	type result struct {
+		Count   int64              `json:"count"`
+		Records []models.LogRecord `json:"records"`
+	}
+	audit := func(token, query string) (int, result) {
+		req := httptest.NewRequest("GET", "/api/v1/audit?"+query, http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		require.NoError(t, err)
+		var r result
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+		return resp.StatusCode, r
+	}
+
+	// The audit trail needs the audit:read credential and an entity type.
+	code, _ := audit(user, "entity=product")
+	assert.Equal(t, fiber.StatusForbidden, code)
+	code, _ = audit(admin, "")
+	assert.Equal(t, fiber.StatusBadRequest, code)
+
+	// All records of the entity type, newest first.
+	code, r := audit(admin, "entity=product")
+	require.Equal(t, 200, code)
+	assert.Equal(t, int64(3), r.Count)
+	require.Len(t, r.Records, 3)
+	assert.Equal(t, models.AuditUpdate, r.Records[0].Action)
+	assert.Equal(t, first.ID.String(), r.Records[0].EntityID)
+	assert.Equal(t, "1", r.Records[0].UserID)
+
+	// Records of one entity.
+	code, r = audit(admin, "entity=product&id="+second.ID.String())
+	require.Equal(t, 200, code)
+	assert.Equal(t, int64(1), r.Count)
+	require.Len(t, r.Records, 1)
+	assert.Equal(t, models.AuditCreate, r.Records[0].Action)
+	assert.Equal(t, second.ID.String(), r.Records[0].EntityID)
+
+	// Records of another entity type, paged.
+	code, r = audit(admin, "entity=user")
+	require.Equal(t, 200, code)
+	assert.Equal(t, int64(1), r.Count)
+	code, r = audit(admin, "entity=product&page_no=2&page_size=2")
+	require.Equal(t, 200, code)
+	assert.Equal(t, int64(3), r.Count)
+	require.Len(t, r.Records, 1)
+	assert.Equal(t, models.AuditCreate, r.Records[0].Action)
+}
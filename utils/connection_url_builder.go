@@ -12,27 +12,9 @@ func ConnectionURLBuilder(n string) (string, error) {
 
 	// Switch given names.
 	switch n {
-	case "postgres":
-		// URL for PostgreSQL connection.
-		url = fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_PORT"),
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			os.Getenv("DB_NAME"),
-			os.Getenv("DB_SSL_MODE"),
-		)
-	case "mysql":
-		// URL for Mysql connection.
-		url = fmt.Sprintf(
-			"%s:%s@tcp(%s:%s)/%s",
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_PORT"),
-			os.Getenv("DB_NAME"),
-		)
+	case "postgres", "mysql":
+		// URL for the primary database.
+		return DatabaseURLBuilder(n, os.Getenv("DB_HOST"), os.Getenv("DB_PORT"))
 	case "redis":
 		// URL for Redis connection.
 		url = fmt.Sprintf(
@@ -55,3 +37,33 @@ func ConnectionURLBuilder(n string) (string, error) {
 	// Return connection URL.
 	return url, nil
 }
+
+// DatabaseURLBuilder func for building URL connection to the database on given host, e.g. a read replica.
+func DatabaseURLBuilder(n, host, port string) (string, error) {
+	switch n {
+	case "postgres":
+		// URL for PostgreSQL connection.
+		return fmt.Sprintf(
+			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+			host,
+			port,
+			os.Getenv("DB_USER"),
+			os.Getenv("DB_PASSWORD"),
+			os.Getenv("DB_NAME"),
+			os.Getenv("DB_SSL_MODE"),
+		), nil
+	case "mysql":
+		// URL for Mysql connection.
+		return fmt.Sprintf(
+			"%s:%s@tcp(%s:%s)/%s",
+			os.Getenv("DB_USER"),
+			os.Getenv("DB_PASSWORD"),
+			host,
+			port,
+			os.Getenv("DB_NAME"),
+		), nil
+	}
+
+	// Return error message.
+	return "", fmt.Errorf("database name '%v' is not supported", n)
+}
@@ -13,18 +13,42 @@ func GetCredentialsByRole(role string) ([]string, error) {
 
 	// Switch given role.
 	switch role {
+	case repository.SuperAdminRoleName:
+		// Super-admin credentials (all access in all tenants).
+		credentials = []string{
+			repository.ProductCreateCredential,
+			repository.ProductUpdateCredential,
+			repository.ProductDeleteCredential,
+			repository.ProductApproveCredential,
+			repository.ProductRestoreCredential,
+			repository.CategoryManageCredential,
+			repository.ReviewModerateCredential,
+			repository.CommentModerateCredential,
+			repository.AuditReadCredential,
+			repository.TenantAllCredential,
+		}
 	case repository.AdminRoleName:
 		// Admin credentials (all access).
 		credentials = []string{
 			repository.ProductCreateCredential,
 			repository.ProductUpdateCredential,
 			repository.ProductDeleteCredential,
+			repository.ProductApproveCredential,
+			repository.ProductRestoreCredential,
+			repository.CategoryManageCredential,
+			repository.ReviewModerateCredential,
+			repository.CommentModerateCredential,
+			repository.AuditReadCredential,
 		}
 	case repository.ModeratorRoleName:
-		// Moderator credentials (only Product creation and update).
+		// Moderator credentials (only Product creation, update and review).
 		credentials = []string{
 			repository.ProductCreateCredential,
 			repository.ProductUpdateCredential,
+			repository.ProductApproveCredential,
+			repository.CategoryManageCredential,
+			repository.ReviewModerateCredential,
+			repository.CommentModerateCredential,
 		}
 	case repository.UserRoleName:
 		// Simple user credentials (only Product creation).
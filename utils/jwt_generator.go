@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"tuxiaocao/pkg/repository"
 )
 
 // Tokens struct to describe tokens object.
@@ -19,9 +21,10 @@ type Tokens struct {
 }
 
 // GenerateNewTokens func for generate a new Access & Refresh tokens.
-func GenerateNewTokens(id string, credentials []string) (*Tokens, error) {
+// The access token carries the tenant of the user, which scopes all queries of the user.
+func GenerateNewTokens(id, tenant string, credentials []string) (*Tokens, error) {
 	// Generate JWT Access token.
-	accessToken, err := generateNewAccessToken(id, credentials)
+	accessToken, err := generateNewAccessToken(id, tenant, credentials)
 	if err != nil {
 		// Return token generation error.
 		return nil, err
@@ -40,7 +43,7 @@ func GenerateNewTokens(id string, credentials []string) (*Tokens, error) {
 	}, nil
 }
 
-func generateNewAccessToken(id string, credentials []string) (string, error) {
+func generateNewAccessToken(id, tenant string, credentials []string) (string, error) {
 	// Set secret key from .env file.
 	secret := os.Getenv("JWT_SECRET_KEY")
 
@@ -52,10 +55,11 @@ func generateNewAccessToken(id string, credentials []string) (string, error) {
 
 	// Set public claims:
 	claims["id"] = id
+	claims["tenant"] = tenant
 	claims["expires"] = time.Now().Add(time.Minute * time.Duration(minutesCount)).Unix()
-	claims["product:create"] = false
-	claims["product:update"] = false
-	claims["product:delete"] = false
+	for _, credential := range repository.Credentials {
+		claims[credential] = false
+	}
 
 	// Set private token credentials:
 	for _, credential := range credentials {
@@ -6,11 +6,14 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+
+	"tuxiaocao/pkg/repository"
 )
 
 // TokenMetadata struct to describe metadata in JWT.
 type TokenMetadata struct {
 	UserID      string
+	TenantID    string
 	Credentials map[string]bool
 	Expires     int64
 }
@@ -27,18 +30,20 @@ func ExtractTokenMetadata(c *fiber.Ctx) (*TokenMetadata, error) {
 	if ok && token.Valid {
 		// User ID.
 		userID := claims["id"].(string)
+		// Tenant ID, empty for tokens issued before tenants.
+		tenantID, _ := claims["tenant"].(string)
 		// Expires time.
 		expires := int64(claims["expires"].(float64))
 
 		// User credentials.
-		credentials := map[string]bool{
-			"product:create": claims["product:create"].(bool),
-			"product:update": claims["product:update"].(bool),
-			"product:delete": claims["product:delete"].(bool),
+		credentials := map[string]bool{}
+		for _, credential := range repository.Credentials {
+			credentials[credential], _ = claims[credential].(bool)
 		}
 
 		return &TokenMetadata{
 			UserID:      userID,
+			TenantID:    tenantID,
 			Credentials: credentials,
 			Expires:     expires,
 		}, nil
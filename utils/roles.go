@@ -10,6 +10,8 @@ import (
 func VerifyRole(role string) (string, error) {
 	// Switch given role.
 	switch role {
+	case repository.SuperAdminRoleName:
+		// Nothing to do, verified successfully.
 	case repository.AdminRoleName:
 		// Nothing to do, verified successfully.
 	case repository.ModeratorRoleName:
@@ -4,6 +4,10 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+	"tuxiaocao/pkg/platform/health"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,9 +19,13 @@ func StartServerWithGracefulShutdown(a *fiber.App) {
 
 	go func() {
 		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, os.Interrupt) // Catch OS signals.
+		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM) // Catch OS signals.
 		<-sigint
 
+		// Fail readiness first, so the orchestrator stops routing traffic before listeners are closed.
+		health.SetShuttingDown()
+		time.Sleep(shutdownDrain())
+
 		// Received an interrupt signal, shutdown.
 		if err := a.Shutdown(); err != nil {
 			// Error from closing listeners, or context timeout:
@@ -48,3 +56,12 @@ func StartServer(a *fiber.App) {
 		log.Printf("Oops... Server is not running! Reason: %v", err)
 	}
 }
+
+// shutdownDrain func for getting SHUTDOWN_DRAIN_SECONDS from .env file (no wait if not set).
+func shutdownDrain() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("SHUTDOWN_DRAIN_SECONDS"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}